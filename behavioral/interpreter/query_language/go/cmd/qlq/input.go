@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is a single input row, keyed by field name
+type Record = map[string]interface{}
+
+// RecordReader yields records one at a time. Next returns io.EOF when the
+// input is exhausted and a *MalformedError for a record that can be skipped;
+// any other error ends the stream.
+type RecordReader interface {
+	Next() (Record, error)
+}
+
+// MalformedError reports a record that could not be decoded
+type MalformedError struct {
+	Line int
+	Err  error
+}
+
+// Error formats the problem with its position in the input
+func (e *MalformedError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying decoding error
+func (e *MalformedError) Unwrap() error {
+	return e.Err
+}
+
+// detectFormat picks an input format from the file name, falling back to
+// sniffing the first non-space byte: '[' means a JSON array, '{' JSON Lines
+func detectFormat(name string, r *bufio.Reader) (string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jsonl", ".ndjson":
+		return "jsonl", nil
+	case ".csv":
+		return "csv", nil
+	}
+
+	for i := 1; ; i++ {
+		peeked, err := r.Peek(i)
+		if len(peeked) < i {
+			if err == io.EOF {
+				return "jsonl", nil // Empty input
+			}
+			return "", err
+		}
+		switch c := peeked[i-1]; c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return "json", nil
+		case '{':
+			return "jsonl", nil
+		default:
+			return "csv", nil
+		}
+	}
+}
+
+// newRecordReader creates a reader for the given format over r
+func newRecordReader(format string, r io.Reader, loc *time.Location) (RecordReader, error) {
+	switch format {
+	case "jsonl":
+		return &jsonLinesReader{r: bufio.NewReader(r)}, nil
+	case "json":
+		return &jsonArrayReader{r: bufio.NewReader(r)}, nil
+	case "csv":
+		return newCSVReader(r, loc), nil
+	}
+	return nil, fmt.Errorf("unknown input format %q", format)
+}
+
+// jsonLinesReader decodes one JSON object per line
+type jsonLinesReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// Next decodes the next non-blank line
+func (jr *jsonLinesReader) Next() (Record, error) {
+	for {
+		data, err := jr.r.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return nil, err
+		}
+		jr.line++
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+		record, decodeErr := decodeObject(data)
+		if decodeErr != nil {
+			return nil, &MalformedError{Line: jr.line, Err: decodeErr}
+		}
+		return record, nil
+	}
+}
+
+// jsonArrayReader decodes the elements of a top-level JSON array one by one.
+// Elements are split on the commas between them before they are decoded, so
+// a syntax error in one element is reported as malformed and reading resumes
+// at the next. An unterminated string or unbalanced brackets leave no comma
+// to resume from: they run to the end of the input, which ends the stream.
+type jsonArrayReader struct {
+	r       *bufio.Reader
+	started bool
+	done    bool
+	index   int
+}
+
+// Next decodes the next array element
+func (ar *jsonArrayReader) Next() (Record, error) {
+	if !ar.started {
+		c, err := ar.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+		if c != '[' {
+			return nil, fmt.Errorf("expected a JSON array, got %q", c)
+		}
+		ar.started = true
+	}
+	if ar.done {
+		return nil, io.EOF
+	}
+
+	raw, end, err := ar.element()
+	if err != nil {
+		return nil, fmt.Errorf("element %d: %w", ar.index+1, err)
+	}
+	ar.done = end == ']'
+	if len(raw) == 0 && ar.done && ar.index == 0 {
+		return nil, io.EOF // An empty array
+	}
+	ar.index++
+	if len(raw) == 0 {
+		return nil, &MalformedError{Line: ar.index, Err: errors.New("missing array element")}
+	}
+	record, err := decodeObject(raw)
+	if err != nil {
+		return nil, &MalformedError{Line: ar.index, Err: err}
+	}
+	return record, nil
+}
+
+// skipSpace returns the first byte that is not white space
+func (ar *jsonArrayReader) skipSpace() (byte, error) {
+	for {
+		c, err := ar.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return c, nil
+	}
+}
+
+// element reads up to the comma or bracket ending the next element, outside
+// strings and nested values, and returns the element and that delimiter
+func (ar *jsonArrayReader) element() ([]byte, byte, error) {
+	var raw []byte
+	depth, inString, escaped := 0, false, false
+	for {
+		c, err := ar.r.ReadByte()
+		if err == io.EOF {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case (c == '}' || c == ']') && depth > 0:
+			depth--
+		case (c == ',' || c == ']') && depth == 0:
+			return bytes.TrimSpace(raw), c, nil
+		}
+		raw = append(raw, c)
+	}
+}
+
+// decodeObject decodes a JSON object, keeping integral numbers as ints so
+// they compare like the literals in a query
+func decodeObject(data []byte) (Record, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON object")
+	}
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a JSON object, got %T", value)
+	}
+	return normalizeNumbers(record).(Record), nil
+}
+
+// normalizeNumbers replaces json.Number values with int or float64
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 0); err == nil {
+			return int(i)
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+	}
+	return value
+}
+
+// csvColumn is a CSV header entry, optionally typed as `name:type`
+type csvColumn struct {
+	name string
+	kind string // "", "string", "int", "float", "bool" or "time"
+}
+
+// csvReader decodes CSV rows using the header row as field names
+type csvReader struct {
+	r       *csv.Reader
+	loc     *time.Location
+	columns []csvColumn
+}
+
+// newCSVReader creates a CSV reader; the header is read on the first Next
+func newCSVReader(r io.Reader, loc *time.Location) *csvReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Rows of the wrong width are reported as malformed
+	reader.ReuseRecord = true
+	return &csvReader{r: reader, loc: loc}
+}
+
+// Next decodes the next CSV row
+func (cr *csvReader) Next() (Record, error) {
+	if cr.columns == nil {
+		header, err := cr.r.Read()
+		if err != nil {
+			return nil, err
+		}
+		columns, err := parseHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		cr.columns = columns
+	}
+
+	row, err := cr.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &MalformedError{Line: parseErr.Line, Err: parseErr.Err}
+		}
+		return nil, err
+	}
+	line, _ := cr.r.FieldPos(0)
+	if len(row) != len(cr.columns) {
+		return nil, &MalformedError{Line: line, Err: fmt.Errorf("expected %d fields, got %d", len(cr.columns), len(row))}
+	}
+
+	record := make(Record, len(row))
+	for i, cell := range row {
+		if cell == "" {
+			continue // Empty cells are missing fields
+		}
+		value, err := cr.convert(cr.columns[i], cell)
+		if err != nil {
+			return nil, &MalformedError{Line: line, Err: fmt.Errorf("column %q: %w", cr.columns[i].name, err)}
+		}
+		record[cr.columns[i].name] = value
+	}
+	return record, nil
+}
+
+// parseHeader splits `name:type` header entries
+func parseHeader(header []string) ([]csvColumn, error) {
+	columns := make([]csvColumn, len(header))
+	for i, entry := range header {
+		name, kind, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		switch kind {
+		case "", "string", "int", "float", "bool", "time":
+		default:
+			return nil, fmt.Errorf("column %q: unknown type %q", name, kind)
+		}
+		columns[i] = csvColumn{name: name, kind: kind}
+	}
+	return columns, nil
+}
+
+// convert turns a cell into a value of the column's type. Untyped columns
+// become ints, floats or bools when the cell looks like one.
+func (cr *csvReader) convert(column csvColumn, cell string) (interface{}, error) {
+	switch column.kind {
+	case "string":
+		return cell, nil
+	case "int":
+		return strconv.Atoi(cell)
+	case "float":
+		return strconv.ParseFloat(cell, 64)
+	case "bool":
+		return strconv.ParseBool(cell)
+	case "time":
+		return parseTime(cell, cr.loc)
+	}
+
+	if i, err := strconv.Atoi(cell); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(cell, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f, nil
+	}
+	if cell == "true" || cell == "false" {
+		return cell == "true", nil
+	}
+	return cell, nil
+}
+
+// parseTime parses an RFC3339 timestamp or a date in loc
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
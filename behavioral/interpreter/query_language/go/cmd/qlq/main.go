@@ -0,0 +1,191 @@
+// qlq filters JSON Lines, JSON arrays and CSV files with the query language.
+// Records are decoded and evaluated one at a time, so inputs don't have to
+// fit in memory.
+//
+// Usage:
+//
+//	qlq [flags] QUERY [FILE ...]
+//
+// For example:
+//
+//	qlq --input events.jsonl 'level = "error" AND duration > 200'
+//	cat users.csv | qlq --output table 'age > 30'
+//
+// CSV headers may declare column types as `name:type`, where type is one of
+// string, int, float, bool or time; untyped cells are converted when they
+// look like numbers or booleans. Malformed records are skipped, counted and
+// reported on stderr.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"interpreter_query_language/query_language"
+)
+
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// runStats counts what happened to the records of a run
+type runStats struct {
+	read      int
+	matched   int
+	malformed int
+}
+
+// discardWriter drops records, for runs that only count matches
+type discardWriter struct{}
+
+func (discardWriter) Write(Record) error { return nil }
+func (discardWriter) Flush() error       { return nil }
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes qlq and returns its exit code: 0 on success, 1 when an input
+// could not be read and 2 for usage errors
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("qlq", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var inputs stringList
+	flags.Var(&inputs, "input", "input file, - for stdin (repeatable)")
+	inputFormat := flags.String("format", "auto", "input format: auto, jsonl, json or csv")
+	outputFormat := flags.String("output", "jsonl", "output format: jsonl, csv or table")
+	fieldList := flags.String("fields", "", "comma separated fields to output (default: all)")
+	tz := flags.String("tz", "UTC", "timezone for date-only literals and CSV dates")
+	countOnly := flags.Bool("count", false, "print the number of matching records instead of the records")
+	maxErrors := flags.Int("max-errors", 10, "number of malformed records to describe on stderr")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: qlq [flags] QUERY [FILE ...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+	inputs = append(inputs, flags.Args()[1:]...)
+	if len(inputs) == 0 {
+		inputs = stringList{"-"}
+	}
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		fmt.Fprintf(stderr, "qlq: invalid timezone: %v\n", err)
+		return 2
+	}
+	var fields []string
+	if *fieldList != "" {
+		fields = strings.Split(*fieldList, ",")
+	}
+	var writer RecordWriter = discardWriter{}
+	if !*countOnly {
+		writer, err = newRecordWriter(*outputFormat, stdout, fields)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "qlq: %v\n", err)
+		return 2
+	}
+
+	engine := query_language.NewQueryEngine()
+	engine.SetTimezone(loc)
+	query := engine.Compile(flags.Arg(0))
+
+	stats := &runStats{}
+	exitCode := 0
+	for _, name := range inputs {
+		err := processInput(name, *inputFormat, stdin, loc, query, writer, stats, func(err *MalformedError) {
+			if stats.malformed <= *maxErrors {
+				fmt.Fprintf(stderr, "qlq: %s: skipping malformed record at %v\n", name, err)
+			}
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "qlq: %s: %v\n", name, err)
+			exitCode = 1
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		fmt.Fprintf(stderr, "qlq: writing output: %v\n", err)
+		exitCode = 1
+	}
+
+	if *countOnly {
+		fmt.Fprintln(stdout, stats.matched)
+	}
+	if stats.malformed > 0 {
+		fmt.Fprintf(stderr, "qlq: %d of %d records were malformed and skipped\n", stats.malformed, stats.read)
+	}
+	return exitCode
+}
+
+// processInput streams one input through the query into the writer
+func processInput(name, format string, stdin io.Reader, loc *time.Location, query *query_language.Query,
+	writer RecordWriter, stats *runStats, onMalformed func(*MalformedError)) error {
+	var in io.Reader = stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	buffered := bufio.NewReaderSize(in, 64*1024)
+	if format == "auto" {
+		detected, err := detectFormat(name, buffered)
+		if err != nil {
+			return err
+		}
+		format = detected
+	}
+	reader, err := newRecordReader(format, buffered, loc)
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		var malformed *MalformedError
+		if errors.As(err, &malformed) {
+			stats.read++
+			stats.malformed++
+			onMalformed(malformed)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		stats.read++
+		if query.Match(record) {
+			stats.matched++
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// RecordWriter writes matching records. Flush must be called once all
+// records have been written.
+type RecordWriter interface {
+	Write(record Record) error
+	Flush() error
+}
+
+// newRecordWriter creates a writer for the given output format. fields fixes
+// the columns of csv and table output; when empty, the keys of the first
+// record are used.
+func newRecordWriter(format string, w io.Writer, fields []string) (RecordWriter, error) {
+	switch format {
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return &jsonLinesWriter{enc: enc, fields: fields}, nil
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w), fields: fields}, nil
+	case "table":
+		return &tableWriter{w: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0), fields: fields}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// jsonLinesWriter writes one JSON object per line
+type jsonLinesWriter struct {
+	enc    *json.Encoder
+	fields []string
+}
+
+// Write encodes the record, restricted to the selected fields if any
+func (jw *jsonLinesWriter) Write(record Record) error {
+	if len(jw.fields) > 0 {
+		selected := make(Record, len(jw.fields))
+		for _, field := range jw.fields {
+			if value, ok := record[field]; ok {
+				selected[field] = value
+			}
+		}
+		record = selected
+	}
+	return jw.enc.Encode(record)
+}
+
+// Flush is a no-op; every record is written as soon as it is encoded
+func (jw *jsonLinesWriter) Flush() error {
+	return nil
+}
+
+// csvWriter writes records as CSV rows under a header row
+type csvWriter struct {
+	w      *csv.Writer
+	fields []string
+	header bool
+}
+
+// Write writes the header before the first record, then the record's row
+func (cw *csvWriter) Write(record Record) error {
+	if !cw.header {
+		if len(cw.fields) == 0 {
+			cw.fields = sortedKeys(record)
+		}
+		if err := cw.w.Write(cw.fields); err != nil {
+			return err
+		}
+		cw.header = true
+	}
+	return cw.w.Write(row(record, cw.fields))
+}
+
+// Flush writes any buffered rows
+func (cw *csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// tableWriter aligns records into columns. Column widths are only known once
+// every row has been seen, so matching rows are buffered until Flush.
+type tableWriter struct {
+	w      *tabwriter.Writer
+	fields []string
+	header bool
+}
+
+// Write adds the record as a table row
+func (tw *tableWriter) Write(record Record) error {
+	if !tw.header {
+		if len(tw.fields) == 0 {
+			tw.fields = sortedKeys(record)
+		}
+		if err := tw.writeLine(tw.fields); err != nil {
+			return err
+		}
+		rule := make([]string, len(tw.fields))
+		for i, field := range tw.fields {
+			rule[i] = strings.Repeat("-", len(field))
+		}
+		if err := tw.writeLine(rule); err != nil {
+			return err
+		}
+		tw.header = true
+	}
+	return tw.writeLine(row(record, tw.fields))
+}
+
+// writeLine writes tab separated cells
+func (tw *tableWriter) writeLine(cells []string) error {
+	// Escape a copy; the header cells are the fields rows are looked up by
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		// Tabs and newlines inside a cell would break the alignment
+		escaped[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(cell)
+	}
+	_, err := fmt.Fprintln(tw.w, strings.Join(escaped, "\t"))
+	return err
+}
+
+// Flush writes the aligned table
+func (tw *tableWriter) Flush() error {
+	return tw.w.Flush()
+}
+
+// row formats the given fields of a record as strings
+func row(record Record, fields []string) []string {
+	cells := make([]string, len(fields))
+	for i, field := range fields {
+		cells[i] = formatCell(record[field])
+	}
+	return cells
+}
+
+// formatCell formats a record value for csv and table output
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// sortedKeys returns the keys of a record in sorted order
+func sortedKeys(record Record) []string {
+	keys := make([]string, 0, len(record))
+	for key := range record {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runQLQ runs the CLI and returns its exit code, stdout and stderr
+func runQLQ(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeFile creates a file with the given content in a temp directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const eventsJSONL = `{"level":"error","duration":250,"service":"api"}
+{"level":"info","duration":300,"service":"api"}
+
+{"level":"error","duration":120,"service":"db"}
+{"level":"error","duration":
+["not", "an", "object"]
+{"level":"error","duration":900,"service":"db","created_at":"2026-03-01T10:00:00Z"}
+`
+
+func TestJSONLinesInput(t *testing.T) {
+	path := writeFile(t, "events.jsonl", eventsJSONL)
+	code, stdout, stderr := runQLQ(t, "", "--input", path, `level = "error" AND duration > 200`)
+
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	expected := `{"duration":250,"level":"error","service":"api"}
+{"created_at":"2026-03-01T10:00:00Z","duration":900,"level":"error","service":"db"}
+`
+	if stdout != expected {
+		t.Errorf("Expected output:\n%s\ngot:\n%s", expected, stdout)
+	}
+	for _, want := range []string{"line 5", "line 6", "2 of 6 records were malformed"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("Expected stderr to mention %q, got:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestJSONArrayInput(t *testing.T) {
+	input := `[
+		{"name": "John", "age": 34},
+		{"name": "Sarah", "age": 29},
+		42,
+		{"name": "Michael", "age": 41.5}
+	]`
+	code, stdout, stderr := runQLQ(t, input, "--fields", "name", "age > 30 OR age > 30.0")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if stdout != "{\"name\":\"John\"}\n{\"name\":\"Michael\"}\n" {
+		t.Errorf("Unexpected output:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "1 of 4 records were malformed") {
+		t.Errorf("Expected the non-object element to be reported, got:\n%s", stderr)
+	}
+}
+
+func TestJSONArraySyntaxErrors(t *testing.T) {
+	// A syntax error spoils one element; reading resumes after its comma
+	input := `[
+		{"name": "John", "age": 34},
+		{"name": "Sarah", "age": },
+		{"name": "Bob" "age": 50},
+		{"name": "Michael", "age": 41, "note": "a, [b] or {c}"},
+	]`
+	code, stdout, stderr := runQLQ(t, input, "--fields", "name", "age > 30")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if stdout != "{\"name\":\"John\"}\n{\"name\":\"Michael\"}\n" {
+		t.Errorf("Unexpected output:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "3 of 5 records were malformed") {
+		t.Errorf("Expected the broken and missing elements to be reported, got:\n%s", stderr)
+	}
+
+	// An unterminated string leaves nothing to resume from
+	input = `[{"name": "John", "age": 34}, {"name": "Sarah, "age": 29}]`
+	code, stdout, stderr = runQLQ(t, input, "age > 30")
+	if code == 0 || !strings.Contains(stderr, "element 2") {
+		t.Errorf("Expected an error for element 2, got exit code %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stdout, "John") {
+		t.Errorf("Expected the records before the error to be written, got:\n%s", stdout)
+	}
+}
+
+func TestCSVInput(t *testing.T) {
+	input := "name,age:int,salary,joined:time,remote\n" +
+		"John,34,85000,2024-01-15,true\n" +
+		"Sarah,thirty,72000,2024-02-01,false\n" +
+		"Michael,41,110000.5,2023-11-30T08:00:00Z,false\n" +
+		"Emma,27,,2025-01-02,true\n" +
+		"Robert,36,95000\n"
+	path := writeFile(t, "people.csv", input)
+
+	t.Run("TypedColumns", func(t *testing.T) {
+		code, stdout, stderr := runQLQ(t, "", "--output", "csv", "--fields", "name,joined",
+			"age > 30 AND joined < @2024-06-01", path)
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+		}
+		expected := "name,joined\nJohn,2024-01-15T00:00:00Z\nMichael,2023-11-30T08:00:00Z\n"
+		if stdout != expected {
+			t.Errorf("Expected output:\n%s\ngot:\n%s", expected, stdout)
+		}
+		if !strings.Contains(stderr, "2 of 5 records were malformed") {
+			t.Errorf("Expected two malformed rows, got:\n%s", stderr)
+		}
+	})
+
+	t.Run("UntypedColumns", func(t *testing.T) {
+		_, stdout, _ := runQLQ(t, "", "--count", "remote = true AND NOT salary > 80000", path)
+		if stdout != "1\n" {
+			t.Errorf("Expected 1 match, got %q", stdout)
+		}
+	})
+
+	t.Run("Timezone", func(t *testing.T) {
+		query := "joined < @2024-01-15T04:00:00Z"
+		if _, stdout, _ := runQLQ(t, "", "--count", query, path); stdout != "2\n" {
+			t.Errorf("Expected 2 matches in UTC, got %q", stdout)
+		}
+		// Midnight in New York is 05:00Z, which moves John's date past the cutoff
+		if _, stdout, _ := runQLQ(t, "", "--count", "--tz", "America/New_York", query, path); stdout != "1\n" {
+			t.Errorf("Expected 1 match in New York time, got %q", stdout)
+		}
+	})
+}
+
+func TestTableOutput(t *testing.T) {
+	input := "{\"name\":\"John\",\"dept\":\"Engineering\"}\n{\"name\":\"Emma\",\"dept\":\"HR\"}\n"
+	_, stdout, _ := runQLQ(t, input, "--output", "table", "name = John OR dept = HR")
+	expected := "dept         name\n" +
+		"----         ----\n" +
+		"Engineering  John\n" +
+		"HR           Emma\n"
+	if stdout != expected {
+		t.Errorf("Expected table:\n%s\ngot:\n%s", expected, stdout)
+	}
+}
+
+func TestTableOutputEscapedHeader(t *testing.T) {
+	// Escaping the header must not change the field rows are looked up by
+	input := "{\"first\\tname\":\"John\"}\n{\"first\\tname\":\"Emma\"}\n"
+	_, stdout, _ := runQLQ(t, input, "--output", "table", "true")
+	expected := "first name\n" +
+		"----------\n" +
+		"John\n" +
+		"Emma\n"
+	if stdout != expected {
+		t.Errorf("Expected table:\n%s\ngot:\n%s", expected, stdout)
+	}
+}
+
+func TestReaderStreamsRecords(t *testing.T) {
+	// The reader must hand records to the query as they are decoded rather
+	// than after reading everything; a pipe that is never closed would block
+	// a reader that waits for EOF before evaluating.
+	pr, pw := io.Pipe()
+	reader, err := newRecordReader("jsonl", pr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go pw.Write([]byte("{\"n\":1}\n"))
+	record, err := reader.Next()
+	if err != nil || record["n"] != 1 {
+		t.Errorf("Expected first record before EOF, got %v, %v", record, err)
+	}
+	pw.Close()
+}
+
+func TestUsageErrors(t *testing.T) {
+	if code, _, _ := runQLQ(t, ""); code != 2 {
+		t.Errorf("Expected exit code 2 without a query, got %d", code)
+	}
+	if code, _, _ := runQLQ(t, "", "--output", "xml", "a = 1"); code != 2 {
+		t.Errorf("Expected exit code 2 for an unknown output format, got %d", code)
+	}
+	if code, _, stderr := runQLQ(t, "", "a = 1", filepath.Join(t.TempDir(), "missing.jsonl")); code != 1 || stderr == "" {
+		t.Errorf("Expected exit code 1 for a missing file, got %d", code)
+	}
+}
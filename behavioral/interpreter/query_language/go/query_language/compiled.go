@@ -0,0 +1,133 @@
+package query_language
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultCacheSize is the number of compiled queries a QueryEngine keeps
+const defaultCacheSize = 128
+
+// Query is a parsed, reusable query. The expression tree is never modified
+// after compilation, so a Query is safe for concurrent use.
+type Query struct {
+	source     string
+	expression Expression
+}
+
+// String returns the query source the Query was compiled from
+func (q *Query) String() string {
+	return q.source
+}
+
+// Expression returns the root of the compiled expression tree
+func (q *Query) Expression() Expression {
+	return q.expression
+}
+
+// Match reports whether the context satisfies the query
+func (q *Query) Match(context Context) bool {
+	return q.expression.Interpret(context)
+}
+
+// Filter returns the items that satisfy the query, scanning every row
+func (q *Query) Filter(data []map[string]interface{}) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0)
+	for _, item := range data {
+		if q.expression.Interpret(Context(item)) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// queryCache is a concurrency-safe LRU cache of values keyed by query
+// source, such as compiled queries
+type queryCache[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // most recently used at the front
+	entries  map[string]*list.Element
+}
+
+// cacheEntry is a value in a queryCache with the source it is stored under
+type cacheEntry[V any] struct {
+	source string
+	value  V
+}
+
+// newQueryCache creates a cache holding at most capacity values
+func newQueryCache[V any](capacity int) *queryCache[V] {
+	return &queryCache[V]{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached value for source and marks it as recently used
+func (c *queryCache[V]) get(source string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[source]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry[V]).value, true
+}
+
+// put stores a value, evicting the least recently used one when full
+func (c *queryCache[V]) put(source string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capacity <= 0 {
+		return
+	}
+	if elem, ok := c.entries[source]; ok {
+		elem.Value.(*cacheEntry[V]).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[source] = c.order.PushFront(&cacheEntry[V]{source: source, value: value})
+	c.evict()
+}
+
+// resize changes the capacity, evicting entries that no longer fit
+func (c *queryCache[V]) resize(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = capacity
+	c.evict()
+}
+
+// purge drops every cached value
+func (c *queryCache[V]) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+// len returns the number of cached values
+func (c *queryCache[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// evict removes least recently used entries until the cache fits. Callers
+// must hold the lock.
+func (c *queryCache[V]) evict() {
+	for c.order.Len() > max(c.capacity, 0) {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry[V]).source)
+	}
+}
@@ -0,0 +1,117 @@
+package query_language
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCompile(t *testing.T) {
+	engine := NewQueryEngine()
+
+	t.Run("ReusesCachedQuery", func(t *testing.T) {
+		first := engine.Compile("department = Engineering AND age > 30")
+		second := engine.Compile("department = Engineering AND age > 30")
+		if first != second {
+			t.Errorf("Expected the second Compile to return the cached query")
+		}
+		if first.String() != "department = Engineering AND age > 30" {
+			t.Errorf("Expected query source to be preserved, got '%s'", first.String())
+		}
+	})
+
+	t.Run("MatchAndFilter", func(t *testing.T) {
+		query := engine.Compile("department = Engineering AND age > 30")
+		if query.Match(testContext) {
+			t.Errorf("Expected testContext not to match")
+		}
+		result := query.Filter(testData)
+		if len(result) != 1 || result[0]["name"] != "Bob" {
+			t.Errorf("Expected only Bob, got %v", result)
+		}
+	})
+
+	t.Run("ConcurrentUse", func(t *testing.T) {
+		query := engine.Compile("department = Engineering OR age < 26")
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					if got := len(query.Filter(testData)); got != 3 {
+						t.Errorf("Expected 3 matches, got %d", got)
+						return
+					}
+					engine.Compile("age > 25")
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("ConfigureWhileCompiling", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				engine.SetClock(func() time.Time { return fixedNow })
+				engine.SetTimezone(time.UTC)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				engine.Compile("created_at < 2024-01-01")
+				engine.Compile("created_at < now() - 1h")
+			}
+		}()
+		wg.Wait()
+	})
+
+	t.Run("ConfigurationPurgesCache", func(t *testing.T) {
+		before := engine.Compile("created_at < now()")
+		engine.SetClock(func() time.Time { return fixedNow })
+		after := engine.Compile("created_at < now()")
+		if before == after {
+			t.Errorf("Expected SetClock to invalidate compiled queries")
+		}
+	})
+}
+
+func TestQueryCache(t *testing.T) {
+	cache := newQueryCache[*Query](2)
+	a := &Query{source: "a"}
+	b := &Query{source: "b"}
+	c := &Query{source: "c"}
+
+	cache.put(a.source, a)
+	cache.put(b.source, b)
+	cache.get("a") // "b" is now least recently used
+	cache.put(c.source, c)
+
+	if _, ok := cache.get("b"); ok {
+		t.Errorf("Expected 'b' to be evicted")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Errorf("Expected 'a' to be cached")
+	}
+	if _, ok := cache.get("c"); !ok {
+		t.Errorf("Expected 'c' to be cached")
+	}
+
+	cache.resize(1)
+	if cache.len() != 1 {
+		t.Errorf("Expected 1 entry after resize, got %d", cache.len())
+	}
+	if _, ok := cache.get("c"); !ok {
+		t.Errorf("Expected most recently used 'c' to survive resize")
+	}
+
+	cache.resize(0)
+	cache.put(a.source, a)
+	if cache.len() != 0 {
+		t.Errorf("Expected a zero-sized cache to stay empty, got %d entries", cache.len())
+	}
+}
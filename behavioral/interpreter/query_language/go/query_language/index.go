@@ -0,0 +1,343 @@
+package query_language
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hashIndex maps canonical equality keys of a field to the rows holding them
+type hashIndex struct {
+	buckets map[string][]int
+	unkeyed []int // Rows whose value has no canonical key; always candidates
+}
+
+// numberEntry is a numeric value of a field in a sorted index
+type numberEntry struct {
+	value float64
+	row   int
+}
+
+// timeEntry is a timestamp value of a field in a sorted index
+type timeEntry struct {
+	value time.Time
+	row   int
+}
+
+// sortedIndex orders the numeric and timestamp values of a field
+type sortedIndex struct {
+	numbers []numberEntry
+	times   []timeEntry
+	unkeyed []int // Zone-less timestamps, ordered only once a location is known
+}
+
+// IndexedDataset holds records together with hash indexes for equality
+// predicates and sorted indexes for range predicates
+type IndexedDataset struct {
+	mu     sync.RWMutex
+	data   []map[string]interface{}
+	hash   map[string]*hashIndex
+	sorted map[string]*sortedIndex
+}
+
+// NewIndexedDataset creates a dataset over the given records with no indexes
+func NewIndexedDataset(data []map[string]interface{}) *IndexedDataset {
+	return &IndexedDataset{
+		data:   data,
+		hash:   make(map[string]*hashIndex),
+		sorted: make(map[string]*sortedIndex),
+	}
+}
+
+// Len returns the number of records in the dataset
+func (d *IndexedDataset) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.data)
+}
+
+// AddHashIndex builds a hash index on field for `field = value` predicates
+func (d *IndexedDataset) AddHashIndex(field string) {
+	index := &hashIndex{buckets: make(map[string][]int)}
+	for row, item := range d.data {
+		value, exists := item[field]
+		if !exists {
+			continue // A missing field never satisfies an equality
+		}
+		if key, ok := hashKey(value); ok {
+			index.buckets[key] = append(index.buckets[key], row)
+		} else {
+			index.unkeyed = append(index.unkeyed, row)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hash[field] = index
+}
+
+// AddSortedIndex builds a sorted index on field for `>` and `<` predicates
+func (d *IndexedDataset) AddSortedIndex(field string) {
+	index := &sortedIndex{}
+	for row, item := range d.data {
+		switch value := item[field].(type) {
+		case int:
+			index.numbers = append(index.numbers, numberEntry{float64(value), row})
+		case float64:
+			if !math.IsNaN(value) { // NaN is neither above nor below anything
+				index.numbers = append(index.numbers, numberEntry{value, row})
+			}
+		case time.Time, *time.Time:
+			if t, ok := toTime(value, nil); ok {
+				index.times = append(index.times, timeEntry{t, row})
+			}
+		case string:
+			// Only timestamps in a string field can satisfy a range predicate
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				index.times = append(index.times, timeEntry{t, row})
+			} else if _, ok := parseTimestamp(value, time.UTC); ok {
+				index.unkeyed = append(index.unkeyed, row)
+			}
+		}
+	}
+	sort.SliceStable(index.numbers, func(i, j int) bool {
+		return index.numbers[i].value < index.numbers[j].value
+	})
+	sort.SliceStable(index.times, func(i, j int) bool {
+		return index.times[i].value.Before(index.times[j].value)
+	})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sorted[field] = index
+}
+
+// Filter returns the records matching the query, in dataset order. Indexes
+// narrow down the candidate rows; the full query is still evaluated on each
+// candidate, so results are identical to a full scan.
+func (d *IndexedDataset) Filter(q *Query) []map[string]interface{} {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	path := d.plan(q.expression)
+	if path.lookup == nil {
+		return q.Filter(d.data)
+	}
+
+	result := make([]map[string]interface{}, 0)
+	for _, row := range path.lookup() {
+		if q.Match(Context(d.data[row])) {
+			result = append(result, d.data[row])
+		}
+	}
+	return result
+}
+
+// Explain describes how Filter would execute the query: which indexes it
+// would use, or that it falls back to a full scan
+func (d *IndexedDataset) Explain(q *Query) string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	path := d.plan(q.expression)
+	if path.lookup == nil {
+		return fmt.Sprintf("FULL SCAN (%d rows): %s", len(d.data), describe(q.expression))
+	}
+	var b strings.Builder
+	path.write(&b, 0)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// accessPath describes how the rows for part of a query are located
+type accessPath struct {
+	label    string
+	children []*accessPath
+	lookup   func() []int // Sorted candidate rows; nil means a scan is needed
+}
+
+// write renders the access path as an indented tree
+func (p *accessPath) write(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(p.label)
+	b.WriteString("\n")
+	for _, child := range p.children {
+		child.write(b, depth+1)
+	}
+}
+
+// plan picks an access path for the expression. Callers must hold the lock.
+func (d *IndexedDataset) plan(expr Expression) *accessPath {
+	switch e := expr.(type) {
+	case *EqualsExpression:
+		if index, ok := d.hash[e.Variable]; ok {
+			if key, ok := hashKey(e.Value); ok && !isTemporal(e.Value) {
+				return &accessPath{
+					label:  "HASH INDEX " + describe(e),
+					lookup: func() []int { return unionRows(index.buckets[key], index.unkeyed) },
+				}
+			}
+		}
+	case *GreaterThanExpression:
+		if index, ok := d.sorted[e.Variable]; ok {
+			if lookup := index.rangeLookup(e.Value, true); lookup != nil {
+				return &accessPath{label: "SORTED INDEX " + describe(e), lookup: lookup}
+			}
+		}
+	case *LessThanExpression:
+		if index, ok := d.sorted[e.Variable]; ok {
+			if lookup := index.rangeLookup(e.Value, false); lookup != nil {
+				return &accessPath{label: "SORTED INDEX " + describe(e), lookup: lookup}
+			}
+		}
+	case *AndExpression:
+		left, right := d.plan(e.Left), d.plan(e.Right)
+		switch {
+		case left.lookup != nil && right.lookup != nil:
+			return &accessPath{
+				label:    "INTERSECT",
+				children: []*accessPath{left, right},
+				lookup:   func() []int { return intersectRows(left.lookup(), right.lookup()) },
+			}
+		case left.lookup != nil:
+			return &accessPath{label: "AND", children: []*accessPath{left, residual(e.Right)}, lookup: left.lookup}
+		case right.lookup != nil:
+			return &accessPath{label: "AND", children: []*accessPath{right, residual(e.Left)}, lookup: right.lookup}
+		}
+	case *OrExpression:
+		left, right := d.plan(e.Left), d.plan(e.Right)
+		if left.lookup != nil && right.lookup != nil {
+			return &accessPath{
+				label:    "UNION",
+				children: []*accessPath{left, right},
+				lookup:   func() []int { return unionRows(left.lookup(), right.lookup()) },
+			}
+		}
+	}
+	return &accessPath{label: "SCAN " + describe(expr)}
+}
+
+// residual is the access path for a conjunct evaluated only on candidate rows
+func residual(expr Expression) *accessPath {
+	return &accessPath{label: "FILTER " + describe(expr)}
+}
+
+// rangeLookup returns a lookup for rows strictly above (or below) value, or
+// nil when the value can't be ordered against the index
+func (index *sortedIndex) rangeLookup(value interface{}, greater bool) func() []int {
+	if isTemporal(value) {
+		return func() []int {
+			target, _ := toTime(value, nil)
+			n := len(index.times)
+			var rows []int
+			if greater {
+				start := sort.Search(n, func(i int) bool { return index.times[i].value.After(target) })
+				for _, entry := range index.times[start:] {
+					rows = append(rows, entry.row)
+				}
+			} else {
+				end := sort.Search(n, func(i int) bool { return !index.times[i].value.Before(target) })
+				for _, entry := range index.times[:end] {
+					rows = append(rows, entry.row)
+				}
+			}
+			sort.Ints(rows)
+			return unionRows(rows, index.unkeyed)
+		}
+	}
+
+	var target float64
+	switch v := value.(type) {
+	case int:
+		target = float64(v)
+	case float64:
+		target = v
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil
+		}
+		target = f
+	default:
+		return nil
+	}
+	return func() []int {
+		n := len(index.numbers)
+		var rows []int
+		if greater {
+			start := sort.Search(n, func(i int) bool { return index.numbers[i].value > target })
+			for _, entry := range index.numbers[start:] {
+				rows = append(rows, entry.row)
+			}
+		} else {
+			end := sort.Search(n, func(i int) bool { return index.numbers[i].value >= target })
+			for _, entry := range index.numbers[:end] {
+				rows = append(rows, entry.row)
+			}
+		}
+		sort.Ints(rows)
+		return rows
+	}
+}
+
+// hashKey returns a canonical key under which values that may compare equal
+// collide. Numbers and numeric strings share one form and strings are
+// case-folded, so a bucket is a superset of the rows that actually match.
+func hashKey(v interface{}) (string, bool) {
+	switch value := v.(type) {
+	case int:
+		return strconv.FormatFloat(float64(value), 'g', -1, 64), true
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	case string:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 64), true
+		}
+		return strings.ToLower(value), true
+	}
+	return "", false
+}
+
+// intersectRows returns the rows present in both sorted slices
+func intersectRows(a, b []int) []int {
+	var result []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// unionRows merges two sorted slices into one sorted slice without duplicates
+func unionRows(a, b []int) []int {
+	result := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i] < b[j]):
+			result = append(result, a[i])
+			i++
+		case i == len(a) || b[j] < a[i]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package query_language
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Shared data for index tests, mixing value types within each field
+var indexData = []map[string]interface{}{
+	{"name": "John", "age": 30, "department": "Engineering", "joined": "2024-03-01T09:00:00Z"},
+	{"name": "Jane", "age": 25, "department": "Marketing", "joined": time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+	{"name": "Bob", "age": 35.5, "department": "engineering", "joined": "2022-01-15"},
+	{"name": "Alice", "age": "28", "department": "HR"},
+	{"name": "Eve", "age": 41, "department": "Engineering", "joined": "2025-11-20T17:30:00+02:00"},
+	{"name": "Mallory", "department": 7, "joined": "unknown"},
+}
+
+func newIndexedTestDataset() *IndexedDataset {
+	dataset := NewIndexedDataset(indexData)
+	dataset.AddHashIndex("department")
+	dataset.AddHashIndex("name")
+	dataset.AddSortedIndex("age")
+	dataset.AddSortedIndex("joined")
+	return dataset
+}
+
+func TestIndexedDatasetMatchesFullScan(t *testing.T) {
+	engine := NewQueryEngine()
+	engine.SetClock(func() time.Time { return fixedNow })
+	dataset := newIndexedTestDataset()
+
+	queries := []string{
+		"department = Engineering",
+		"department = engineering",
+		"department = 7",
+		"age > 28",
+		"age < 30",
+		"age > \"29.5\"",
+		"age > 30.0",
+		"department = Engineering AND age > 30",
+		"department = HR OR age < 26",
+		"name = Bob OR department = Marketing",
+		"department = Engineering AND NOT age > 40",
+		"joined > @2023-01-01",
+		"joined < now() - 365d",
+		"joined > @2022-01-15T00:00:00Z AND department = Engineering",
+		"NOT department = Engineering",
+		"age > abc",
+	}
+
+	for _, q := range queries {
+		t.Run(q, func(t *testing.T) {
+			query := engine.Compile(q)
+			expected := query.Filter(indexData)
+			got := dataset.Filter(query)
+			if !reflect.DeepEqual(names(got), names(expected)) {
+				t.Errorf("Query '%s': indexed %v, full scan %v\n%s", q, names(got), names(expected), dataset.Explain(query))
+			}
+		})
+	}
+}
+
+func TestIndexedDatasetExplain(t *testing.T) {
+	engine := NewQueryEngine()
+	dataset := newIndexedTestDataset()
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{
+			"department = Engineering",
+			`HASH INDEX department = "Engineering"`,
+		},
+		{
+			"department = Engineering AND age > 30",
+			"INTERSECT\n  HASH INDEX department = \"Engineering\"\n  SORTED INDEX age > 30",
+		},
+		{
+			"age < 30 AND active = true",
+			"AND\n  SORTED INDEX age < 30\n  FILTER active = true",
+		},
+		{
+			"name = Bob OR age > 40",
+			// The optimizer tries the likelier predicate of an OR first
+			"UNION\n  SORTED INDEX age > 40\n  HASH INDEX name = \"Bob\"",
+		},
+		{
+			"name = Bob OR active = true",
+			`FULL SCAN (6 rows): (name = "Bob" OR active = true)`,
+		},
+		{
+			"NOT department = HR",
+			`FULL SCAN (6 rows): NOT department = "HR"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := dataset.Explain(engine.Compile(tt.query))
+			if got != tt.expected {
+				t.Errorf("Explain '%s':\nexpected:\n%s\ngot:\n%s", tt.query, tt.expected, got)
+			}
+		})
+	}
+}
+
+func BenchmarkIndexedFilter(b *testing.B) {
+	departments := []string{"Engineering", "Marketing", "HR", "Finance", "Sales"}
+	data := make([]map[string]interface{}, 100000)
+	for i := range data {
+		data[i] = map[string]interface{}{
+			"name":       fmt.Sprintf("user%d", i),
+			"age":        20 + i%50,
+			"department": departments[i%len(departments)],
+		}
+	}
+	engine := NewQueryEngine()
+	query := engine.Compile("name = user4242 OR (department = HR AND age > 68)")
+
+	b.Run("FullScan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			query.Filter(data)
+		}
+	})
+
+	b.Run("Indexed", func(b *testing.B) {
+		dataset := NewIndexedDataset(data)
+		dataset.AddHashIndex("name")
+		dataset.AddHashIndex("department")
+		dataset.AddSortedIndex("age")
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			dataset.Filter(query)
+		}
+	})
+}
+
+// names extracts the name field of each record
+func names(records []map[string]interface{}) []string {
+	result := make([]string, len(records))
+	for i, record := range records {
+		result[i] = fmt.Sprint(record["name"])
+	}
+	return result
+}
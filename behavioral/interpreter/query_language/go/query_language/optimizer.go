@@ -0,0 +1,271 @@
+package query_language
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Default estimates used when no statistics are known for a field
+const (
+	defaultEqualsSelectivity = 0.1
+	defaultRangeSelectivity  = 1.0 / 3.0
+	defaultTruthSelectivity  = 0.5
+)
+
+// Relative evaluation costs of the leaf expressions
+const (
+	variableCost   = 0.5
+	comparisonCost = 1.0
+	temporalCost   = 5.0 // Timestamps in the data are parsed on every comparison
+)
+
+// FieldStatistics summarises the values of one field across a dataset
+type FieldStatistics struct {
+	Rows     int     // Rows the statistics were gathered from
+	Present  int     // Rows in which the field is set
+	Distinct int     // Distinct values of the field
+	Numeric  bool    // Whether every present value is a number
+	Min, Max float64 // Numeric range, valid when Numeric is set
+}
+
+// Statistics maps field names to their statistics
+type Statistics map[string]FieldStatistics
+
+// CollectStatistics gathers per-field statistics from sample records
+func CollectStatistics(data []map[string]interface{}) Statistics {
+	stats := make(Statistics)
+	distinct := make(map[string]map[string]struct{})
+
+	for _, item := range data {
+		for field, value := range item {
+			fs, seen := stats[field]
+			if !seen {
+				fs = FieldStatistics{Numeric: true, Min: math.Inf(1), Max: math.Inf(-1)}
+				distinct[field] = make(map[string]struct{})
+			}
+			fs.Present++
+
+			key, ok := hashKey(value)
+			if !ok {
+				key = formatValue(value)
+			}
+			distinct[field][key] = struct{}{}
+
+			switch v := value.(type) {
+			case int:
+				fs.Min, fs.Max = math.Min(fs.Min, float64(v)), math.Max(fs.Max, float64(v))
+			case float64:
+				fs.Min, fs.Max = math.Min(fs.Min, v), math.Max(fs.Max, v)
+			default:
+				fs.Numeric = false
+			}
+			stats[field] = fs
+		}
+	}
+
+	for field, fs := range stats {
+		fs.Rows = len(data)
+		fs.Distinct = len(distinct[field])
+		stats[field] = fs
+	}
+	return stats
+}
+
+// Optimizer rewrites expression trees into cheaper equivalent ones. It folds
+// constants, removes double negation, pushes NOT down to the leaves using De
+// Morgan's laws and orders AND/OR operands so the cheapest and most decisive
+// predicates are evaluated first.
+type Optimizer struct {
+	Stats Statistics // Optional; defaults are used for unknown fields
+}
+
+// NewOptimizer creates an Optimizer using the given statistics, which may be nil
+func NewOptimizer(stats Statistics) *Optimizer {
+	return &Optimizer{Stats: stats}
+}
+
+// Optimize returns an optimized copy of the expression. The original tree is
+// left untouched and evaluates to the same result for every context.
+func (o *Optimizer) Optimize(expr Expression) Expression {
+	return o.rewrite(expr, false)
+}
+
+// rewrite optimizes expr, negating it when negate is set
+func (o *Optimizer) rewrite(expr Expression, negate bool) Expression {
+	switch e := expr.(type) {
+	case *NotExpression:
+		return o.rewrite(e.Expression, !negate)
+	case *AndExpression:
+		// NOT (a AND b) == NOT a OR NOT b
+		return o.combine(!negate, o.rewrite(e.Left, negate), o.rewrite(e.Right, negate))
+	case *OrExpression:
+		// NOT (a OR b) == NOT a AND NOT b
+		return o.combine(negate, o.rewrite(e.Left, negate), o.rewrite(e.Right, negate))
+	case *LiteralExpression:
+		return &LiteralExpression{Value: e.Interpret(nil) != negate}
+	}
+	if negate {
+		return &NotExpression{Expression: expr}
+	}
+	return expr
+}
+
+// combine joins operands with AND (conjunction) or OR, folding constants and
+// ordering the operands by rank
+func (o *Optimizer) combine(conjunction bool, operands ...Expression) Expression {
+	var terms []Expression
+	for _, operand := range operands {
+		terms = flatten(conjunction, operand, terms)
+	}
+
+	kept := make([]Expression, 0, len(terms))
+	for _, term := range terms {
+		if literal, ok := term.(*LiteralExpression); ok {
+			if literal.Interpret(nil) != conjunction {
+				// false AND x == false, true OR x == true
+				return &LiteralExpression{Value: !conjunction}
+			}
+			continue // true AND x == x, false OR x == x
+		}
+		kept = append(kept, term)
+	}
+	if len(kept) == 0 {
+		return &LiteralExpression{Value: conjunction}
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		return o.rank(conjunction, kept[i]) < o.rank(conjunction, kept[j])
+	})
+
+	result := kept[0]
+	for _, term := range kept[1:] {
+		if conjunction {
+			result = &AndExpression{Left: result, Right: term}
+		} else {
+			result = &OrExpression{Left: result, Right: term}
+		}
+	}
+	return result
+}
+
+// flatten appends the operands of nested ANDs (or ORs) to terms
+func flatten(conjunction bool, expr Expression, terms []Expression) []Expression {
+	if and, ok := expr.(*AndExpression); ok && conjunction {
+		return flatten(conjunction, and.Right, flatten(conjunction, and.Left, terms))
+	}
+	if or, ok := expr.(*OrExpression); ok && !conjunction {
+		return flatten(conjunction, or.Right, flatten(conjunction, or.Left, terms))
+	}
+	return append(terms, expr)
+}
+
+// rank orders operands so the expected cost of evaluating a short-circuiting
+// chain is minimal: cost divided by the chance the operand decides the result
+func (o *Optimizer) rank(conjunction bool, expr Expression) float64 {
+	cost, selectivity := o.estimate(expr)
+	decisive := selectivity // An OR is decided by a true operand
+	if conjunction {
+		decisive = 1 - selectivity // An AND is decided by a false operand
+	}
+	if decisive <= 0 {
+		return math.Inf(1)
+	}
+	return cost / decisive
+}
+
+// estimate returns the expected evaluation cost of expr and the fraction of
+// rows for which it is true
+func (o *Optimizer) estimate(expr Expression) (cost, selectivity float64) {
+	switch e := expr.(type) {
+	case *LiteralExpression:
+		if e.Interpret(nil) {
+			return 0, 1
+		}
+		return 0, 0
+	case *VariableExpression:
+		return variableCost, o.presence(e.Name) * defaultTruthSelectivity
+	case *EqualsExpression:
+		return o.comparisonCost(e.Value), o.equalsSelectivity(e.Variable)
+	case *GreaterThanExpression:
+		return o.comparisonCost(e.Value), o.rangeSelectivity(e.Variable, e.Value, true)
+	case *LessThanExpression:
+		return o.comparisonCost(e.Value), o.rangeSelectivity(e.Variable, e.Value, false)
+	case *NotExpression:
+		cost, selectivity := o.estimate(e.Expression)
+		return cost, 1 - selectivity
+	case *AndExpression:
+		leftCost, leftSel := o.estimate(e.Left)
+		rightCost, rightSel := o.estimate(e.Right)
+		return leftCost + leftSel*rightCost, leftSel * rightSel
+	case *OrExpression:
+		leftCost, leftSel := o.estimate(e.Left)
+		rightCost, rightSel := o.estimate(e.Right)
+		return leftCost + (1-leftSel)*rightCost, 1 - (1-leftSel)*(1-rightSel)
+	}
+	return comparisonCost, defaultTruthSelectivity
+}
+
+// comparisonCost estimates the cost of comparing a field against value
+func (o *Optimizer) comparisonCost(value interface{}) float64 {
+	if isTemporal(value) {
+		return temporalCost
+	}
+	return comparisonCost
+}
+
+// presence returns the fraction of rows in which field is set
+func (o *Optimizer) presence(field string) float64 {
+	fs, ok := o.Stats[field]
+	if !ok || fs.Rows == 0 {
+		return 1
+	}
+	return float64(fs.Present) / float64(fs.Rows)
+}
+
+// equalsSelectivity estimates the fraction of rows where field equals a value
+func (o *Optimizer) equalsSelectivity(field string) float64 {
+	fs, ok := o.Stats[field]
+	if !ok || fs.Distinct == 0 {
+		return defaultEqualsSelectivity
+	}
+	return o.presence(field) / float64(fs.Distinct)
+}
+
+// rangeSelectivity estimates the fraction of rows where field is above (or
+// below) value, assuming values are spread evenly between min and max
+func (o *Optimizer) rangeSelectivity(field string, value interface{}, greater bool) float64 {
+	fs, ok := o.Stats[field]
+	if !ok || !fs.Numeric || fs.Present == 0 {
+		return defaultRangeSelectivity
+	}
+
+	var target float64
+	switch v := value.(type) {
+	case int:
+		target = float64(v)
+	case float64:
+		target = v
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return defaultRangeSelectivity
+		}
+		target = f
+	default:
+		// Numbers never compare with other kinds of values
+		return 0
+	}
+
+	if fs.Max == fs.Min {
+		if (greater && fs.Min > target) || (!greater && fs.Min < target) {
+			return o.presence(field)
+		}
+		return 0
+	}
+	fraction := (fs.Max - target) / (fs.Max - fs.Min)
+	if !greater {
+		fraction = (target - fs.Min) / (fs.Max - fs.Min)
+	}
+	return o.presence(field) * math.Max(0, math.Min(1, fraction))
+}
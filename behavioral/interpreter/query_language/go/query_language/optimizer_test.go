@@ -0,0 +1,211 @@
+package query_language
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOptimizerRewrites(t *testing.T) {
+	parser := QueryParser{}
+	optimizer := NewOptimizer(nil)
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"true AND name = John", `name = "John"`},
+		{"false AND name = John", "false"},
+		{"true OR name = John", "true"},
+		{"false OR name = John", `name = "John"`},
+		{"NOT NOT name = John", `name = "John"`},
+		{"NOT true", "false"},
+		{"NOT (name = John AND age > 30)", `(NOT name = "John" OR NOT age > 30)`},
+		{"NOT (name = John OR age > 30)", `(NOT age > 30 AND NOT name = "John")`},
+		{"NOT (NOT name = John OR false)", `name = "John"`},
+		{"(true AND false) OR (active AND true)", "active"},
+		{"created_at > @2026-01-01T00:00:00Z AND name = John", `(name = "John" AND created_at > @2026-01-01T00:00:00Z)`},
+		{"age > 30 OR name = John", `(age > 30 OR name = "John")`},
+		{"name = John OR age > 30", `(age > 30 OR name = "John")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := describe(optimizer.Optimize(parser.Parse(tt.query)))
+			if got != tt.expected {
+				t.Errorf("Optimize '%s': expected %s, got %s", tt.query, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOptimizerUsesStatistics(t *testing.T) {
+	data := make([]map[string]interface{}, 1000)
+	for i := range data {
+		data[i] = map[string]interface{}{
+			"level":    []string{"info", "warn"}[i%2],
+			"duration": i,
+			"user":     fmt.Sprintf("user%d", i),
+		}
+	}
+	stats := CollectStatistics(data)
+
+	if fs := stats["duration"]; !fs.Numeric || fs.Min != 0 || fs.Max != 999 || fs.Distinct != 1000 {
+		t.Fatalf("Unexpected statistics for duration: %+v", fs)
+	}
+	if fs := stats["level"]; fs.Numeric || fs.Distinct != 2 || fs.Present != 1000 {
+		t.Fatalf("Unexpected statistics for level: %+v", fs)
+	}
+
+	parser := QueryParser{}
+	query := parser.Parse("level = info AND duration > 990 AND user = user7")
+
+	withoutStats := describe(NewOptimizer(nil).Optimize(query))
+	if withoutStats != `((level = "info" AND user = "user7") AND duration > 990)` {
+		t.Errorf("Unexpected order without statistics: %s", withoutStats)
+	}
+
+	withStats := describe(NewOptimizer(stats).Optimize(query))
+	if withStats != `((user = "user7" AND duration > 990) AND level = "info")` {
+		t.Errorf("Unexpected order with statistics: %s", withStats)
+	}
+}
+
+func TestOptimizerLeavesOriginalIntact(t *testing.T) {
+	parser := QueryParser{}
+	query := parser.Parse("NOT (true AND (name = John OR age > 30))")
+	before := describe(query)
+	NewOptimizer(nil).Optimize(query)
+	if after := describe(query); after != before {
+		t.Errorf("Expected original tree %s to be unchanged, got %s", before, after)
+	}
+}
+
+// randomExpression builds a random expression tree over a small set of fields
+func randomExpression(r *rand.Rand, depth int) Expression {
+	fields := []string{"a", "b", "c", "d"}
+	values := []interface{}{0, 1, 2, 3.5, "x", "y", "2", true, false}
+	field := fields[r.IntN(len(fields))]
+	value := values[r.IntN(len(values))]
+
+	if depth <= 0 || r.IntN(3) == 0 {
+		switch r.IntN(6) {
+		case 0:
+			return &LiteralExpression{Value: values[r.IntN(len(values))]}
+		case 1:
+			return &VariableExpression{Name: field}
+		case 2:
+			return &GreaterThanExpression{Variable: field, Value: value}
+		case 3:
+			return &LessThanExpression{Variable: field, Value: value}
+		default:
+			return &EqualsExpression{Variable: field, Value: value}
+		}
+	}
+
+	switch r.IntN(3) {
+	case 0:
+		return &NotExpression{Expression: randomExpression(r, depth-1)}
+	case 1:
+		return &AndExpression{Left: randomExpression(r, depth-1), Right: randomExpression(r, depth-1)}
+	default:
+		return &OrExpression{Left: randomExpression(r, depth-1), Right: randomExpression(r, depth-1)}
+	}
+}
+
+// randomContext builds a random record, leaving some fields unset
+func randomContext(r *rand.Rand) Context {
+	values := []interface{}{0, 1, 2, 3.5, "x", "y", "2", "", true, false}
+	ctx := Context{}
+	for _, field := range []string{"a", "b", "c", "d"} {
+		if r.IntN(5) > 0 {
+			ctx[field] = values[r.IntN(len(values))]
+		}
+	}
+	return ctx
+}
+
+// TestOptimizerPreservesSemantics checks the property that an optimized tree
+// evaluates exactly like the original for random trees and records
+func TestOptimizerPreservesSemantics(t *testing.T) {
+	r := rand.New(rand.NewPCG(42, 2026))
+	contexts := make([]Context, 50)
+	for i := range contexts {
+		contexts[i] = randomContext(r)
+	}
+	stats := CollectStatistics(func() []map[string]interface{} {
+		data := make([]map[string]interface{}, len(contexts))
+		for i, ctx := range contexts {
+			data[i] = ctx
+		}
+		return data
+	}())
+
+	for _, optimizer := range []*Optimizer{NewOptimizer(nil), NewOptimizer(stats)} {
+		for i := 0; i < 2000; i++ {
+			original := randomExpression(r, 5)
+			optimized := optimizer.Optimize(original)
+			for _, ctx := range contexts {
+				if original.Interpret(ctx) != optimized.Interpret(ctx) {
+					t.Fatalf("Optimized tree diverges for %v:\noriginal:  %s\noptimized: %s",
+						ctx, describe(original), describe(optimized))
+				}
+			}
+		}
+	}
+}
+
+var (
+	largeDataOnce sync.Once
+	largeData     []map[string]interface{}
+)
+
+// millionRows lazily builds a 1M-row dataset shared by the benchmarks
+func millionRows() []map[string]interface{} {
+	largeDataOnce.Do(func() {
+		levels := []string{"debug", "info", "info", "info", "warn", "error"}
+		start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		largeData = make([]map[string]interface{}, 1_000_000)
+		for i := range largeData {
+			largeData[i] = map[string]interface{}{
+				"level":      levels[i%len(levels)],
+				"duration":   i % 1000,
+				"service":    fmt.Sprintf("svc%d", i%500),
+				"created_at": start.Add(time.Duration(i) * time.Second).Format(time.RFC3339),
+			}
+		}
+	})
+	return largeData
+}
+
+func BenchmarkOptimizer(b *testing.B) {
+	data := millionRows()
+	parser := QueryParser{}
+	query := "NOT NOT (true AND created_at > @2026-01-05T00:00:00Z AND level = info AND duration > 900 AND service = svc42)"
+	original := &Query{source: query, expression: parser.Parse(query)}
+
+	b.Run("Unoptimized", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			original.Filter(data)
+		}
+	})
+
+	b.Run("Optimized", func(b *testing.B) {
+		optimized := &Query{source: query, expression: NewOptimizer(nil).Optimize(original.expression)}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			optimized.Filter(data)
+		}
+	})
+
+	b.Run("OptimizedWithStatistics", func(b *testing.B) {
+		optimizer := NewOptimizer(CollectStatistics(data))
+		optimized := &Query{source: query, expression: optimizer.Optimize(original.expression)}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			optimized.Filter(data)
+		}
+	})
+}
@@ -1,8 +1,11 @@
 package query_language
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Context represents the data against which expressions are evaluated
@@ -32,6 +35,11 @@ func (e *LiteralExpression) Interpret(context Context) bool {
 	}
 }
 
+// String renders the literal in query syntax
+func (e *LiteralExpression) String() string {
+	return formatValue(e.Value)
+}
+
 // VariableExpression represents a variable in the context
 type VariableExpression struct {
 	Name string
@@ -43,7 +51,11 @@ func (e *VariableExpression) Interpret(context Context) bool {
 	if !exists {
 		return false
 	}
+	return e.matches(value)
+}
 
+// matches returns the boolean value of a variable that is present
+func (e *VariableExpression) matches(value interface{}) bool {
 	switch v := value.(type) {
 	case bool:
 		return v
@@ -61,6 +73,11 @@ func (e *VariableExpression) GetValue(context Context) interface{} {
 	return context[e.Name]
 }
 
+// String renders the variable in query syntax
+func (e *VariableExpression) String() string {
+	return e.Name
+}
+
 // EqualsExpression represents an equality comparison
 type EqualsExpression struct {
 	Variable string
@@ -73,6 +90,14 @@ func (e *EqualsExpression) Interpret(context Context) bool {
 	if !exists {
 		return false
 	}
+	return e.matches(value)
+}
+
+// matches checks if a present value equals the expression's value
+func (e *EqualsExpression) matches(value interface{}) bool {
+	if cmp, ok := compareTemporal(value, e.Value); ok {
+		return cmp == 0
+	}
 
 	switch v := value.(type) {
 	case string:
@@ -114,6 +139,11 @@ func (e *EqualsExpression) Interpret(context Context) bool {
 	return strings.EqualFold(toString(value), toString(e.Value))
 }
 
+// String renders the comparison in query syntax
+func (e *EqualsExpression) String() string {
+	return fmt.Sprintf("%s = %s", e.Variable, formatValue(e.Value))
+}
+
 // GreaterThanExpression represents a greater than comparison
 type GreaterThanExpression struct {
 	Variable string
@@ -126,12 +156,23 @@ func (e *GreaterThanExpression) Interpret(context Context) bool {
 	if !exists {
 		return false
 	}
+	return e.matches(value)
+}
+
+// matches checks if a present value is greater than the expression's value
+func (e *GreaterThanExpression) matches(value interface{}) bool {
+	if cmp, ok := compareTemporal(value, e.Value); ok {
+		return cmp > 0
+	}
 
 	switch v := value.(type) {
 	case int:
 		if intValue, ok := e.Value.(int); ok {
 			return v > intValue
 		}
+		if floatValue, ok := e.Value.(float64); ok {
+			return float64(v) > floatValue
+		}
 		if strValue, ok := e.Value.(string); ok {
 			if intValue, err := strconv.Atoi(strValue); err == nil {
 				return v > intValue
@@ -141,6 +182,9 @@ func (e *GreaterThanExpression) Interpret(context Context) bool {
 		if floatValue, ok := e.Value.(float64); ok {
 			return v > floatValue
 		}
+		if intValue, ok := e.Value.(int); ok {
+			return v > float64(intValue)
+		}
 		if strValue, ok := e.Value.(string); ok {
 			if floatValue, err := strconv.ParseFloat(strValue, 64); err == nil {
 				return v > floatValue
@@ -152,6 +196,11 @@ func (e *GreaterThanExpression) Interpret(context Context) bool {
 	return false
 }
 
+// String renders the comparison in query syntax
+func (e *GreaterThanExpression) String() string {
+	return fmt.Sprintf("%s > %s", e.Variable, formatValue(e.Value))
+}
+
 // LessThanExpression represents a less than comparison
 type LessThanExpression struct {
 	Variable string
@@ -164,12 +213,23 @@ func (e *LessThanExpression) Interpret(context Context) bool {
 	if !exists {
 		return false
 	}
+	return e.matches(value)
+}
+
+// matches checks if a present value is less than the expression's value
+func (e *LessThanExpression) matches(value interface{}) bool {
+	if cmp, ok := compareTemporal(value, e.Value); ok {
+		return cmp < 0
+	}
 
 	switch v := value.(type) {
 	case int:
 		if intValue, ok := e.Value.(int); ok {
 			return v < intValue
 		}
+		if floatValue, ok := e.Value.(float64); ok {
+			return float64(v) < floatValue
+		}
 		if strValue, ok := e.Value.(string); ok {
 			if intValue, err := strconv.Atoi(strValue); err == nil {
 				return v < intValue
@@ -179,6 +239,9 @@ func (e *LessThanExpression) Interpret(context Context) bool {
 		if floatValue, ok := e.Value.(float64); ok {
 			return v < floatValue
 		}
+		if intValue, ok := e.Value.(int); ok {
+			return v < float64(intValue)
+		}
 		if strValue, ok := e.Value.(string); ok {
 			if floatValue, err := strconv.ParseFloat(strValue, 64); err == nil {
 				return v < floatValue
@@ -190,6 +253,11 @@ func (e *LessThanExpression) Interpret(context Context) bool {
 	return false
 }
 
+// String renders the comparison in query syntax
+func (e *LessThanExpression) String() string {
+	return fmt.Sprintf("%s < %s", e.Variable, formatValue(e.Value))
+}
+
 // AndExpression represents a logical AND of two expressions
 type AndExpression struct {
 	Left  Expression
@@ -201,6 +269,11 @@ func (e *AndExpression) Interpret(context Context) bool {
 	return e.Left.Interpret(context) && e.Right.Interpret(context)
 }
 
+// String renders the conjunction in query syntax
+func (e *AndExpression) String() string {
+	return fmt.Sprintf("(%s AND %s)", describe(e.Left), describe(e.Right))
+}
+
 // OrExpression represents a logical OR of two expressions
 type OrExpression struct {
 	Left  Expression
@@ -212,6 +285,11 @@ func (e *OrExpression) Interpret(context Context) bool {
 	return e.Left.Interpret(context) || e.Right.Interpret(context)
 }
 
+// String renders the disjunction in query syntax
+func (e *OrExpression) String() string {
+	return fmt.Sprintf("(%s OR %s)", describe(e.Left), describe(e.Right))
+}
+
 // NotExpression represents a logical NOT of an expression
 type NotExpression struct {
 	Expression Expression
@@ -222,8 +300,18 @@ func (e *NotExpression) Interpret(context Context) bool {
 	return !e.Expression.Interpret(context)
 }
 
+// String renders the negation in query syntax
+func (e *NotExpression) String() string {
+	return "NOT " + describe(e.Expression)
+}
+
 // QueryParser converts query strings into expression trees
-type QueryParser struct{}
+type QueryParser struct {
+	// Location is the default timezone for date-only literals (UTC when nil)
+	Location *time.Location
+	// Clock supplies the current time for now() (time.Now when nil)
+	Clock func() time.Time
+}
 
 // findSplitIndex finds the index of the operator outside parentheses.
 func (p *QueryParser) findSplitIndex(query, operator string) int {
@@ -262,6 +350,15 @@ func tryConvertValue(valueStr string) interface{} {
 	return valueStr // Keep as string
 }
 
+// parseValue converts the right-hand side of a comparison, recognising
+// timestamp literals and duration arithmetic before plain values.
+func (p *QueryParser) parseValue(valueStr string) interface{} {
+	if value, ok := p.parseTemporal(valueStr); ok {
+		return value
+	}
+	return tryConvertValue(valueStr)
+}
+
 // Parse parses a query string into an expression tree respecting precedence and parentheses.
 func (p *QueryParser) Parse(query string) Expression {
 	query = strings.TrimSpace(query)
@@ -326,7 +423,7 @@ func (p *QueryParser) Parse(query string) Expression {
 		if opIndex != -1 {
 			variable := strings.TrimSpace(query[:opIndex])
 			valueStr := strings.TrimSpace(query[opIndex+len(op):])
-			value := tryConvertValue(valueStr)
+			value := p.parseValue(valueStr)
 
 			switch op {
 			case " = ":
@@ -350,29 +447,101 @@ func (p *QueryParser) Parse(query string) Expression {
 
 // QueryEngine provides methods to filter data using queries
 type QueryEngine struct {
-	parser *QueryParser
+	mu        sync.RWMutex // Guards the settings below against Compile
+	parser    *QueryParser
+	optimizer *Optimizer
+	cache     *queryCache[*Query]
+	schema    Schema
 }
 
 // NewQueryEngine creates a new QueryEngine
 func NewQueryEngine() *QueryEngine {
 	return &QueryEngine{
-		parser: &QueryParser{},
+		parser:    &QueryParser{},
+		optimizer: NewOptimizer(nil),
+		cache:     newQueryCache[*Query](defaultCacheSize),
 	}
 }
 
-// Filter filters a slice of data using a query expression
-func (e *QueryEngine) Filter(data []map[string]interface{}, query string) []map[string]interface{} {
-	expression := e.parser.Parse(query)
-	result := make([]map[string]interface{}, 0)
+// SetTimezone sets the default timezone applied to date-only literals
+func (e *QueryEngine) SetTimezone(loc *time.Location) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.parser.Location = loc
+	e.cache.purge() // Compiled literals were resolved in the old timezone
+}
 
-	for _, item := range data {
-		context := Context(item)
-		if expression.Interpret(context) {
-			result = append(result, item)
+// SetClock sets the clock used to resolve now() in queries
+func (e *QueryEngine) SetClock(clock func() time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.parser.Clock = clock
+	e.cache.purge() // Compiled now() expressions hold the old clock
+}
+
+// SetStatistics sets the field statistics used to order query predicates
+func (e *QueryEngine) SetStatistics(stats Statistics) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.optimizer.Stats = stats
+	e.cache.purge() // Compiled queries were ordered for the old statistics
+}
+
+// SetSchema sets the schema CompileChecked validates queries against
+func (e *QueryEngine) SetSchema(schema Schema) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.schema = schema
+}
+
+// SetCacheSize sets how many compiled queries are kept; 0 disables caching
+func (e *QueryEngine) SetCacheSize(size int) {
+	e.cache.resize(size)
+}
+
+// Compile parses and optimizes a query once so it can be evaluated many
+// times. Compiled queries are cached, so compiling the same string again is
+// cheap.
+func (e *QueryEngine) Compile(query string) *Query {
+	// Hold the settings until the result is cached, so a setter's purge
+	// cannot miss a query compiled with the old ones
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if compiled, ok := e.cache.get(query); ok {
+		return compiled
+	}
+	expression := e.optimizer.Optimize(e.parser.Parse(query))
+	compiled := &Query{source: query, expression: expression}
+	e.cache.put(compiled.source, compiled)
+	return compiled
+}
+
+// CompileChecked compiles a query after validating it against the engine's
+// schema, so typos and type mismatches fail instead of matching nothing
+func (e *QueryEngine) CompileChecked(query string) (*Query, error) {
+	e.mu.RLock()
+	schema := e.schema
+	e.mu.RUnlock()
+	if schema != nil {
+		// Check the tree as written; optimization could hide a typo in a
+		// branch that folds away
+		if err := schema.Check(e.parse(query)); err != nil {
+			return nil, err
 		}
 	}
+	return e.Compile(query), nil
+}
+
+// parse parses a query with the engine's current settings
+func (e *QueryEngine) parse(query string) Expression {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.parser.Parse(query)
+}
 
-	return result
+// Filter filters a slice of data using a query expression
+func (e *QueryEngine) Filter(data []map[string]interface{}, query string) []map[string]interface{} {
+	return e.Compile(query).Filter(data)
 }
 
 // Helper function to convert a value to string
@@ -389,7 +558,37 @@ func toString(v interface{}) string {
 		return strconv.FormatFloat(value, 'f', -1, 64)
 	case bool:
 		return strconv.FormatBool(value)
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
 	default:
 		return ""
 	}
 }
+
+// describe renders an expression in query syntax, falling back to its Go
+// representation for expression types that don't implement fmt.Stringer
+func describe(expr Expression) string {
+	if stringer, ok := expr.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%v", expr)
+}
+
+// formatValue renders a comparison operand in query syntax
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return strconv.Quote(value)
+	case time.Time:
+		return "@" + value.Format(time.RFC3339Nano)
+	case *RelativeTime:
+		switch {
+		case value.Offset > 0:
+			return "now() + " + value.Offset.String()
+		case value.Offset < 0:
+			return "now() - " + (-value.Offset).String()
+		}
+		return "now()"
+	}
+	return fmt.Sprintf("%v", v)
+}
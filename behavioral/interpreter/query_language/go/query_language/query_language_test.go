@@ -50,6 +50,12 @@ func TestExpressions(t *testing.T) {
 		if exprFalse.Interpret(testContext) {
 			t.Errorf("Expected 'age > 30' to be false, got true")
 		}
+
+		// Ints and floats are ordered against each other
+		exprIntFloat := GreaterThanExpression{Variable: "age", Value: 29.5}
+		if !exprIntFloat.Interpret(testContext) {
+			t.Errorf("Expected 'age > 29.5' to be true, got false")
+		}
 		
 		// Test type conversion (int vs string)
 		exprIntStr := GreaterThanExpression{Variable: "age", Value: "25"} 
@@ -69,6 +75,11 @@ func TestExpressions(t *testing.T) {
 			t.Errorf("Expected 'age < 30' to be false, got true")
 		}
 
+		exprFloatInt := LessThanExpression{Variable: "salary", Value: 60000}
+		if !exprFloatInt.Interpret(Context{"salary": 55000.5}) {
+			t.Errorf("Expected 'salary < 60000' to be true, got false")
+		}
+
 		// Test type conversion (int vs string)
 		exprIntStr := LessThanExpression{Variable: "age", Value: "35"}
 		if !exprIntStr.Interpret(testContext) {
@@ -0,0 +1,425 @@
+package query_language
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldType is the type of a field declared in a Schema
+type FieldType string
+
+const (
+	TypeString FieldType = "string"
+	TypeInt    FieldType = "int"
+	TypeFloat  FieldType = "float"
+	TypeBool   FieldType = "bool"
+	TypeTime   FieldType = "time"
+	TypeAny    FieldType = "any" // Mixed or unknown values; never type checked
+)
+
+// FieldSchema describes one field of a record
+type FieldSchema struct {
+	Type     FieldType
+	Nullable bool // Whether the field may be missing or nil
+}
+
+// Schema maps field names to their declared types
+type Schema map[string]FieldSchema
+
+// InferSchema derives a schema from sample records. Fields missing from some
+// samples or holding nil are nullable, ints widen to floats, strings that are
+// all timestamps become time fields and any other mix becomes TypeAny.
+func InferSchema(samples []map[string]interface{}) Schema {
+	schema := make(Schema)
+	seen := make(map[string]int)
+
+	for _, item := range samples {
+		for field, value := range item {
+			seen[field]++
+			fs, known := schema[field]
+			if value == nil {
+				fs.Nullable = true
+				schema[field] = fs
+				continue
+			}
+			if !known || fs.Type == "" {
+				fs.Type = valueType(value)
+			} else {
+				fs.Type = widenType(fs.Type, valueType(value))
+			}
+			schema[field] = fs
+		}
+	}
+
+	for field, fs := range schema {
+		if seen[field] < len(samples) {
+			fs.Nullable = true
+		}
+		if fs.Type == "" {
+			fs.Type = TypeAny // Only ever nil in the samples
+		}
+		schema[field] = fs
+	}
+	return schema
+}
+
+// valueType returns the schema type of a record value
+func valueType(value interface{}) FieldType {
+	switch v := value.(type) {
+	case int:
+		return TypeInt
+	case float64:
+		return TypeFloat
+	case bool:
+		return TypeBool
+	case time.Time, *time.Time:
+		return TypeTime
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return TypeTime
+		}
+		return TypeString
+	}
+	return TypeAny
+}
+
+// widenType returns a type that holds values of both a and b
+func widenType(a, b FieldType) FieldType {
+	switch {
+	case a == b:
+		return a
+	case (a == TypeInt && b == TypeFloat) || (a == TypeFloat && b == TypeInt):
+		return TypeFloat
+	case (a == TypeTime && b == TypeString) || (a == TypeString && b == TypeTime):
+		return TypeString // Some strings weren't timestamps after all
+	}
+	return TypeAny
+}
+
+// Validate checks that a record conforms to the schema: every non-nullable
+// field is present and every present value has the declared type
+func (s Schema) Validate(record map[string]interface{}) error {
+	var errs CheckErrors
+	for _, field := range s.fieldNames() {
+		fs := s[field]
+		value, exists := record[field]
+		if !exists || value == nil {
+			if !fs.Nullable {
+				errs = append(errs, &CheckError{Field: field, Message: fmt.Sprintf("missing required %s field %q", fs.Type, field)})
+			}
+			continue
+		}
+		if actual := valueType(value); !fs.accepts(actual) {
+			errs = append(errs, &CheckError{Field: field, Message: fmt.Sprintf("field %q holds %s, expected %s", field, actual, fs.Type)})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// accepts reports whether values of type actual may be stored in the field
+func (fs FieldSchema) accepts(actual FieldType) bool {
+	switch fs.Type {
+	case TypeAny, actual:
+		return true
+	case TypeFloat:
+		return actual == TypeInt
+	case TypeString:
+		return actual == TypeTime // Timestamps are strings too
+	}
+	return false
+}
+
+// CheckError describes a problem the schema checker found in a query
+type CheckError struct {
+	Field      string
+	Expression string // The offending part of the query, if any
+	Message    string
+	Suggestion string // A known field close to an unknown one, if any
+}
+
+// Error formats the problem, including the suggestion when there is one
+func (e *CheckError) Error() string {
+	msg := e.Message
+	if e.Expression != "" {
+		msg += " in " + e.Expression
+	}
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", e.Suggestion)
+	}
+	return msg
+}
+
+// CheckErrors collects every problem found in a query
+type CheckErrors []*CheckError
+
+// Error joins the individual problems
+func (e CheckErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Check validates a parsed query against the schema before it is executed. It
+// reports unknown fields, comparisons between incompatible types and
+// comparisons that can never be true. The result is nil or CheckErrors.
+func (s Schema) Check(expr Expression) error {
+	checker := &schemaChecker{schema: s}
+	checker.visit(expr, true)
+	if len(checker.errs) == 0 {
+		return nil
+	}
+	return checker.errs
+}
+
+// schemaChecker walks an expression tree collecting CheckErrors
+type schemaChecker struct {
+	schema Schema
+	errs   CheckErrors
+}
+
+// addError records a problem found in expr
+func (c *schemaChecker) addError(field string, expr Expression, format string, args ...interface{}) {
+	c.errs = append(c.errs, &CheckError{Field: field, Expression: describe(expr), Message: fmt.Sprintf(format, args...)})
+}
+
+// visit checks expr and its children. required tells whether expr must be
+// true for the whole query to match, which is lost under NOT and OR
+func (c *schemaChecker) visit(expr Expression, required bool) {
+	switch e := expr.(type) {
+	case *VariableExpression:
+		c.lookup(e.Name, e)
+	case *EqualsExpression:
+		if fs, ok := c.lookup(e.Variable, e); ok {
+			c.checkEquals(e.Variable, fs, e.Value, e)
+		}
+	case *GreaterThanExpression:
+		if fs, ok := c.lookup(e.Variable, e); ok {
+			c.checkRange(e.Variable, fs, e.Value, e)
+		}
+	case *LessThanExpression:
+		if fs, ok := c.lookup(e.Variable, e); ok {
+			c.checkRange(e.Variable, fs, e.Value, e)
+		}
+	case *NotExpression:
+		c.visit(e.Expression, false)
+	case *OrExpression:
+		c.visit(e.Left, false)
+		c.visit(e.Right, false)
+	case *AndExpression:
+		// Check the whole chain at once so each contradiction is reported once
+		terms := flatten(true, e, nil)
+		for _, term := range terms {
+			c.visit(term, required)
+		}
+		// A contradiction elsewhere only makes a branch false, which a
+		// negation or another branch can still turn into a match
+		if required {
+			c.checkContradictions(e, terms)
+		}
+	}
+}
+
+// lookup returns the schema of field, reporting it when unknown
+func (c *schemaChecker) lookup(field string, expr Expression) (FieldSchema, bool) {
+	fs, ok := c.schema[field]
+	if !ok {
+		c.errs = append(c.errs, &CheckError{
+			Field:      field,
+			Expression: describe(expr),
+			Message:    fmt.Sprintf("unknown field %q", field),
+			Suggestion: c.schema.suggest(field),
+		})
+	}
+	return fs, ok
+}
+
+// literalType returns the schema type of a parsed comparison operand
+func literalType(value interface{}) FieldType {
+	switch v := value.(type) {
+	case *RelativeTime, time.Time:
+		return TypeTime
+	case string:
+		if _, ok := parseTimestamp(v, time.UTC); ok {
+			return TypeTime
+		}
+		return TypeString
+	}
+	return valueType(value)
+}
+
+// checkEquals reports equality comparisons that can never match
+func (c *schemaChecker) checkEquals(field string, fs FieldSchema, value interface{}, expr Expression) {
+	literal := literalType(value)
+	compatible := true
+	switch fs.Type {
+	case TypeInt, TypeFloat:
+		compatible = literal == TypeInt || literal == TypeFloat
+	case TypeBool:
+		compatible = literal == TypeBool
+	case TypeTime:
+		compatible = literal == TypeTime
+	}
+	if !compatible {
+		c.addError(field, expr, "cannot compare %s field %q with %s %s", fs.Type, field, literal, formatValue(value))
+	}
+}
+
+// checkRange reports ordering comparisons that can never match
+func (c *schemaChecker) checkRange(field string, fs FieldSchema, value interface{}, expr Expression) {
+	literal := literalType(value)
+	switch fs.Type {
+	case TypeAny:
+		return
+	case TypeString, TypeTime:
+		if isTemporal(value) {
+			return
+		}
+		if literal == TypeTime {
+			// A quoted timestamp is compared as a plain string and never ordered
+			c.addError(field, expr, "%s field %q is only ordered against timestamp literals; write %s as @%s",
+				fs.Type, field, formatValue(value), value)
+		} else {
+			c.addError(field, expr, "cannot order %s field %q against %s %s", fs.Type, field, literal, formatValue(value))
+		}
+	case TypeBool:
+		c.addError(field, expr, "cannot order bool field %q", field)
+	case TypeInt, TypeFloat:
+		// Ints and floats order against each other, as float fields can hold both
+		if literal != TypeInt && literal != TypeFloat {
+			c.addError(field, expr, "cannot order %s field %q against %s %s", fs.Type, field, literal, formatValue(value))
+		}
+	}
+}
+
+// bounds is the numeric interval a field is constrained to by a conjunction
+type bounds struct {
+	lower, upper float64 // Exclusive
+	equals       []float64
+}
+
+// checkContradictions reports numeric conditions on the same field that can't
+// all hold, such as `age > 40 AND age < 30`
+func (c *schemaChecker) checkContradictions(and *AndExpression, terms []Expression) {
+	constraints := make(map[string]*bounds)
+	var fields []string
+	constrain := func(field string) *bounds {
+		b, ok := constraints[field]
+		if !ok {
+			b = &bounds{lower: math.Inf(-1), upper: math.Inf(1)}
+			constraints[field] = b
+			fields = append(fields, field)
+		}
+		return b
+	}
+
+	for _, term := range terms {
+		switch e := term.(type) {
+		case *GreaterThanExpression:
+			if v, ok := c.numericOperand(e.Variable, e.Value); ok {
+				b := constrain(e.Variable)
+				b.lower = math.Max(b.lower, v)
+			}
+		case *LessThanExpression:
+			if v, ok := c.numericOperand(e.Variable, e.Value); ok {
+				b := constrain(e.Variable)
+				b.upper = math.Min(b.upper, v)
+			}
+		case *EqualsExpression:
+			if v, ok := c.numericOperand(e.Variable, e.Value); ok {
+				b := constrain(e.Variable)
+				b.equals = append(b.equals, v)
+			}
+		}
+	}
+
+	for _, field := range fields {
+		b := constraints[field]
+		empty := b.upper <= b.lower
+		if c.schema[field].Type == TypeInt {
+			empty = math.Ceil(b.upper)-1 < math.Floor(b.lower)+1
+		}
+		for _, v := range b.equals {
+			if v <= b.lower || v >= b.upper || v != b.equals[0] {
+				empty = true
+			}
+		}
+		if empty {
+			c.addError(field, and, "conditions on %q can never all be true", field)
+		}
+	}
+}
+
+// numericOperand returns the value of a comparison against a numeric field
+func (c *schemaChecker) numericOperand(field string, value interface{}) (float64, bool) {
+	fs, ok := c.schema[field]
+	if !ok || (fs.Type != TypeInt && fs.Type != TypeFloat) {
+		return 0, false
+	}
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// fieldNames returns the schema's fields in sorted order
+func (s Schema) fieldNames() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// suggest returns the known field closest to an unknown name, or "" when no
+// field is close enough to be a likely typo
+func (s Schema) suggest(name string) string {
+	best, bestDistance := "", math.MaxInt
+	for _, candidate := range s.fieldNames() {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if bestDistance <= max(2, len(name)/3) && bestDistance < len(name) {
+		return best
+	}
+	return ""
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,151 @@
+package query_language
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Shared schema for checker tests
+var employeeSchema = Schema{
+	"name":       {Type: TypeString},
+	"age":        {Type: TypeInt},
+	"salary":     {Type: TypeFloat},
+	"department": {Type: TypeString},
+	"active":     {Type: TypeBool},
+	"hired_at":   {Type: TypeTime, Nullable: true},
+}
+
+func TestInferSchema(t *testing.T) {
+	samples := []map[string]interface{}{
+		{"name": "John", "age": 30, "score": 1, "hired_at": "2024-01-02T09:00:00Z", "note": nil, "tag": "a"},
+		{"name": "Jane", "age": 25, "score": 2.5, "hired_at": time.Now(), "tag": 3},
+	}
+
+	expected := Schema{
+		"name":     {Type: TypeString},
+		"age":      {Type: TypeInt},
+		"score":    {Type: TypeFloat},
+		"hired_at": {Type: TypeTime},
+		"note":     {Type: TypeAny, Nullable: true},
+		"tag":      {Type: TypeAny},
+	}
+	if got := InferSchema(samples); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected schema %v, got %v", expected, got)
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	valid := map[string]interface{}{"name": "John", "age": 30, "salary": 85000, "department": "Engineering", "active": true}
+	if err := employeeSchema.Validate(valid); err != nil {
+		t.Errorf("Expected record to be valid, got %v", err)
+	}
+
+	invalid := map[string]interface{}{"name": "John", "age": "thirty", "salary": 85000.0, "department": "Engineering"}
+	err := employeeSchema.Validate(invalid)
+	var errs CheckErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Expected 2 validation errors, got %v", err)
+	}
+	if errs[0].Field != "active" || errs[1].Field != "age" {
+		t.Errorf("Expected errors for 'active' and 'age', got %v", err)
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	parser := QueryParser{}
+
+	valid := []string{
+		"name = John AND age > 30",
+		"salary > 50000.0 OR department = HR",
+		"salary > 50000 AND age < 25.5",
+		"active = true AND NOT age < 18",
+		"hired_at > now() - 30d",
+		"hired_at = \"2024-01-02T09:00:00Z\"",
+		"department = 42",
+		"age > 20 AND age < 22",
+		"(age > 30 AND age < 40) OR (age > 50 AND age < 60)",
+		"active",
+		// Contradictions that need not hold for the whole query
+		"NOT (age > 40 AND age < 30)",
+		"(age > 40 AND age < 30) OR active",
+	}
+	for _, q := range valid {
+		t.Run(q, func(t *testing.T) {
+			if err := employeeSchema.Check(parser.Parse(q)); err != nil {
+				t.Errorf("Expected '%s' to pass, got %v", q, err)
+			}
+		})
+	}
+
+	invalid := []struct {
+		query    string
+		contains []string
+	}{
+		{"agee > 30", []string{`unknown field "agee"`, `did you mean "age"?`}},
+		{"Salary > 10.5", []string{`unknown field "Salary"`, `did you mean "salary"?`}},
+		{"colour = red", []string{`unknown field "colour"`}},
+		{"age = John", []string{`cannot compare int field "age" with string "John"`}},
+		{"active = 1", []string{`cannot compare bool field "active" with int 1`}},
+		{"name > 5", []string{`cannot order string field "name" against int 5`}},
+		{"active > 0", []string{`cannot order bool field "active"`}},
+		{"hired_at > \"2024-01-01\"", []string{`write "2024-01-01" as @2024-01-01`}},
+		{"hired_at = 5", []string{`cannot compare time field "hired_at" with int 5`}},
+		{"age > 40 AND name = John AND age < 30", []string{`conditions on "age" can never all be true`}},
+		{"age > 20 AND age < 21", []string{`conditions on "age" can never all be true`}},
+		{"age = 30 AND age = 31", []string{`conditions on "age" can never all be true`}},
+		{"agee > 30 OR nme = John", []string{`"agee"`, `"nme"`, `did you mean "name"?`}},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.query, func(t *testing.T) {
+			err := employeeSchema.Check(parser.Parse(tt.query))
+			if err == nil {
+				t.Fatalf("Expected '%s' to fail the check", tt.query)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error for '%s' to contain %q, got %q", tt.query, want, err.Error())
+				}
+			}
+		})
+	}
+
+	t.Run("ContradictionReportedOnce", func(t *testing.T) {
+		err := employeeSchema.Check(parser.Parse("age > 40 AND age < 30 AND active"))
+		var errs CheckErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("Expected exactly one error, got %v", err)
+		}
+	})
+}
+
+func TestCompileChecked(t *testing.T) {
+	engine := NewQueryEngine()
+
+	if _, err := engine.CompileChecked("agee > 30"); err != nil {
+		t.Errorf("Expected no checking without a schema, got %v", err)
+	}
+
+	engine.SetSchema(InferSchema(testData))
+	if _, err := engine.CompileChecked("false AND agee > 30"); err == nil {
+		t.Errorf("Expected a typo in a constant-folded branch to be reported")
+	}
+
+	negated, err := engine.CompileChecked("NOT (age > 40 AND age < 30)")
+	if err != nil {
+		t.Fatalf("Expected a negated contradiction to pass the check, got %v", err)
+	}
+	if !negated.Match(Context{"age": 35}) {
+		t.Errorf("Expected a negated contradiction to match everything")
+	}
+
+	query, err := engine.CompileChecked("department = Engineering AND age > 28")
+	if err != nil {
+		t.Fatalf("Expected query to pass the check, got %v", err)
+	}
+	if got := len(query.Filter(testData)); got != 2 {
+		t.Errorf("Expected 2 matches, got %d", got)
+	}
+}
@@ -0,0 +1,380 @@
+package query_language
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structEngine compiles the queries run by FilterSlice
+var structEngine = NewQueryEngine()
+
+// structInfoCache maps a struct reflect.Type to its *structInfo
+var structInfoCache sync.Map
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// fieldInfo locates a query field within a struct
+type fieldInfo struct {
+	index []int // Field indexes, possibly through embedded structs
+	typ   reflect.Type
+}
+
+// structInfo is the reflection metadata of a struct type, computed once
+type structInfo struct {
+	fields map[string]fieldInfo
+	names  []string // Field names in declaration order
+
+	schemaOnce sync.Once
+	schema     Schema
+	open       []string           // Fields whose nested keys can't be known statically
+	checked    *queryCache[error] // Query string -> error from checking it
+}
+
+// structInfoFor returns the cached metadata of a struct type
+func structInfoFor(t reflect.Type) *structInfo {
+	if cached, ok := structInfoCache.Load(t); ok {
+		return cached.(*structInfo)
+	}
+	info := &structInfo{fields: make(map[string]fieldInfo), checked: newQueryCache[error](defaultCacheSize)}
+	info.collect(t, nil)
+	cached, _ := structInfoCache.LoadOrStore(t, info)
+	return cached.(*structInfo)
+}
+
+// collect adds the exported fields of t, promoting the fields of untagged
+// embedded structs the way encoding/json does. Shallower fields win.
+func (info *structInfo) collect(t reflect.Type, prefix []int) {
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, tagged := fieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && !tagged && fieldType.Kind() == reflect.Struct {
+			embedded = append(embedded, field)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if _, exists := info.fields[name]; exists {
+			continue
+		}
+		index := append(append([]int{}, prefix...), i)
+		info.fields[name] = fieldInfo{index: index, typ: field.Type}
+		info.names = append(info.names, name)
+	}
+
+	for _, field := range embedded {
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		info.collect(fieldType, append(append([]int{}, prefix...), field.Index...))
+	}
+}
+
+// fieldName returns the query name of a struct field: its `ql` tag, else its
+// `json` tag, else the Go field name. A name of "-" excludes the field.
+func fieldName(field reflect.StructField) (string, bool) {
+	for _, key := range []string{"ql", "json"} {
+		if tag, ok := field.Tag.Lookup(key); ok {
+			name, _, _ := strings.Cut(tag, ",")
+			if name != "" {
+				return name, true
+			}
+		}
+	}
+	return field.Name, false
+}
+
+// field returns the value of a field, or false when a nil pointer is on the
+// way to it
+func (info *structInfo) field(v reflect.Value, name string) (reflect.Value, bool) {
+	fi, ok := info.fields[name]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	for _, i := range fi.index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// structSource evaluates queries directly against a struct value
+type structSource struct {
+	value reflect.Value
+}
+
+// lookup resolves a field name, following dotted paths into nested structs
+// and maps. A nil pointer anywhere on the path means the field is missing.
+func (s structSource) lookup(path string) (interface{}, bool) {
+	v := s.value
+	for {
+		var ok bool
+		if v, ok = indirect(v); !ok {
+			return nil, false
+		}
+		next, rest, found := child(v, path)
+		if !found {
+			return nil, false
+		}
+		if rest == "" {
+			return normalizeValue(next)
+		}
+		v, path = next, rest
+	}
+}
+
+// child returns the member of v named by path, preferring a member whose name
+// is the whole path over the first segment of a dotted path
+func child(v reflect.Value, path string) (reflect.Value, string, bool) {
+	if next, ok := member(v, path); ok {
+		return next, "", true
+	}
+	head, tail, dotted := strings.Cut(path, ".")
+	if !dotted {
+		return reflect.Value{}, "", false
+	}
+	next, ok := member(v, head)
+	return next, tail, ok
+}
+
+// member returns a struct field or a map entry with a string key
+func member(v reflect.Value, name string) (reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.Struct:
+		return structInfoFor(v.Type()).field(v, name)
+	case reflect.Map:
+		keyType := v.Type().Key()
+		if keyType.Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		next := v.MapIndex(reflect.ValueOf(name).Convert(keyType))
+		return next, next.IsValid()
+	}
+	return reflect.Value{}, false
+}
+
+// context builds a Context of the top-level fields, for expression types
+// that can only be interpreted against a map
+func (s structSource) context() Context {
+	ctx := Context{}
+	v, ok := indirect(s.value)
+	if !ok || v.Kind() != reflect.Struct {
+		return ctx
+	}
+	info := structInfoFor(v.Type())
+	for _, name := range info.names {
+		if value, ok := s.lookup(name); ok {
+			ctx[name] = value
+		}
+	}
+	return ctx
+}
+
+// indirect dereferences pointers and interfaces, reporting false for nil
+func indirect(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v, v.IsValid()
+}
+
+// normalizeValue converts a field to the value types the expressions compare:
+// int, float64, string, bool and time.Time. Named types convert through their
+// underlying kind, unsigned values beyond an int become float64 and other
+// values are passed through unchanged.
+func normalizeValue(v reflect.Value) (interface{}, bool) {
+	v, ok := indirect(v)
+	if !ok {
+		return nil, false
+	}
+	if v.Type() == timeType {
+		return v.Interface(), true
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt {
+			return float64(v.Uint()), true // Too large for an int
+		}
+		return int(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	}
+	return v.Interface(), true
+}
+
+// fieldSource supplies field values to evaluate
+type fieldSource interface {
+	lookup(field string) (interface{}, bool)
+	context() Context
+}
+
+// evaluate interprets expr against a field source, looking up only the
+// fields the query uses
+func evaluate(expr Expression, source fieldSource) bool {
+	switch e := expr.(type) {
+	case *LiteralExpression:
+		return e.Interpret(nil)
+	case *VariableExpression:
+		value, exists := source.lookup(e.Name)
+		return exists && e.matches(value)
+	case *EqualsExpression:
+		value, exists := source.lookup(e.Variable)
+		return exists && e.matches(value)
+	case *GreaterThanExpression:
+		value, exists := source.lookup(e.Variable)
+		return exists && e.matches(value)
+	case *LessThanExpression:
+		value, exists := source.lookup(e.Variable)
+		return exists && e.matches(value)
+	case *NotExpression:
+		return !evaluate(e.Expression, source)
+	case *AndExpression:
+		return evaluate(e.Left, source) && evaluate(e.Right, source)
+	case *OrExpression:
+		return evaluate(e.Left, source) || evaluate(e.Right, source)
+	}
+	return expr.Interpret(source.context())
+}
+
+// FilterSlice returns the items matching the query. T must be a struct type
+// or a pointer to one; query fields are resolved through `ql` and `json`
+// struct tags, with dotted names reaching into nested structs and maps. The
+// query is checked against the struct's fields first, so unknown fields and
+// type mismatches are reported as errors.
+func FilterSlice[T any](items []T, query string) ([]T, error) {
+	t := reflect.TypeFor[T]()
+	base := t
+	for base.Kind() == reflect.Pointer {
+		base = base.Elem()
+	}
+	if base.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("FilterSlice: %s is not a struct type", t)
+	}
+
+	info := structInfoFor(base)
+	if err := info.check(query); err != nil {
+		return nil, err
+	}
+	compiled := structEngine.Compile(query)
+
+	result := make([]T, 0)
+	for i := range items {
+		if evaluate(compiled.expression, structSource{value: reflect.ValueOf(&items[i]).Elem()}) {
+			result = append(result, items[i])
+		}
+	}
+	return result, nil
+}
+
+// check validates a query against the struct's fields, caching the outcome
+func (info *structInfo) check(query string) error {
+	if err, ok := info.checked.get(query); ok {
+		return err
+	}
+
+	info.schemaOnce.Do(func() {
+		info.schema = make(Schema)
+		info.addToSchema("", info, false, map[*structInfo]bool{info: true})
+	})
+
+	var errs CheckErrors
+	if err := info.schema.Check(structEngine.parse(query)); err != nil {
+		for _, checkErr := range err.(CheckErrors) {
+			if !info.isOpen(checkErr.Field) {
+				errs = append(errs, checkErr)
+			}
+		}
+	}
+
+	var result error
+	if len(errs) > 0 {
+		result = errs
+	}
+	info.checked.put(query, result)
+	return result
+}
+
+// isOpen reports whether a field lies under a map or interface field, whose
+// keys are only known at run time
+func (info *structInfo) isOpen(field string) bool {
+	for _, prefix := range info.open {
+		if strings.HasPrefix(field, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// addToSchema adds the fields of a struct to the schema under a prefix
+func (info *structInfo) addToSchema(prefix string, fields *structInfo, nullable bool, visiting map[*structInfo]bool) {
+	for _, name := range fields.names {
+		fi := fields.fields[name]
+		path := prefix + name
+		typ, fieldNullable := fi.typ, nullable
+		for typ.Kind() == reflect.Pointer {
+			typ, fieldNullable = typ.Elem(), true
+		}
+
+		info.schema[path] = FieldSchema{Type: kindType(typ), Nullable: fieldNullable}
+		switch {
+		case typ.Kind() == reflect.Struct && typ != timeType:
+			nested := structInfoFor(typ)
+			if visiting[nested] {
+				info.open = append(info.open, path) // Recursive type
+				continue
+			}
+			visiting[nested] = true
+			info.addToSchema(path+".", nested, fieldNullable, visiting)
+			delete(visiting, nested)
+		case typ.Kind() == reflect.Map || typ.Kind() == reflect.Interface:
+			info.open = append(info.open, path)
+		}
+	}
+}
+
+// kindType returns the schema type of a Go type
+func kindType(t reflect.Type) FieldType {
+	if t == timeType {
+		return TypeTime
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return TypeInt
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat
+	case reflect.String:
+		return TypeString
+	case reflect.Bool:
+		return TypeBool
+	}
+	return TypeAny
+}
@@ -0,0 +1,176 @@
+package query_language
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Level string
+
+type Address struct {
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+type Audit struct {
+	CreatedAt time.Time `json:"created_at"`
+	Version   uint8
+}
+
+type Employee struct {
+	Audit
+	Name       string            `json:"name"`
+	Age        int               `json:"age"`
+	Department string            `ql:"dept" json:"department"`
+	Salary     float64           `json:"salary,omitempty"`
+	Level      Level             `json:"level"`
+	Active     bool              `json:"active"`
+	Manager    *Employee         `json:"manager"`
+	Address    *Address          `json:"address"`
+	Labels     map[string]string `json:"labels"`
+	Password   string            `json:"-"`
+	internal   int
+}
+
+var (
+	hq    = &Address{City: "Berlin", Country: "DE"}
+	boss  = &Employee{Name: "Grace", Age: 52, Department: "Engineering", Address: hq}
+	staff = []Employee{
+		{Name: "John", Age: 34, Department: "Engineering", Salary: 85000, Level: "senior", Active: true,
+			Manager: boss, Address: hq, Labels: map[string]string{"team": "core"},
+			Audit: Audit{CreatedAt: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), Version: 3}},
+		{Name: "Sarah", Age: 29, Department: "Marketing", Salary: 72000, Level: "junior",
+			Address: &Address{City: "Paris", Country: "FR"},
+			Audit:   Audit{CreatedAt: time.Date(2025, 5, 2, 0, 0, 0, 0, time.UTC), Version: 1}},
+		{Name: "Michael", Age: 41, Department: "Engineering", Salary: 110000, Level: "senior", Active: true,
+			Manager: boss, Labels: map[string]string{"team": "infra"}},
+	}
+)
+
+func TestFilterSlice(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"dept = Engineering AND age > 35", []string{"Michael"}},
+		{"level = senior", []string{"John", "Michael"}},
+		{"salary > 80000.0 AND active", []string{"John", "Michael"}},
+		{"address.city = Berlin", []string{"John"}},
+		{"address.country = FR OR manager.name = Grace", []string{"John", "Sarah", "Michael"}},
+		{"manager.address.city = Berlin AND NOT address.city = Berlin", []string{"Michael"}},
+		{"labels.team = infra", []string{"Michael"}},
+		{"created_at > @2025-01-01", []string{"Sarah"}},
+		{"Version > 2", []string{"John"}},
+		{"manager", []string{"John", "Michael"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			result, err := FilterSlice(staff, tt.query)
+			if err != nil {
+				t.Fatalf("FilterSlice returned error: %v", err)
+			}
+			names := make([]string, len(result))
+			for i, e := range result {
+				names[i] = e.Name
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestFilterSlicePointers(t *testing.T) {
+	pointers := []*Employee{&staff[0], nil, &staff[2]}
+	result, err := FilterSlice(pointers, "age > 40")
+	if err != nil {
+		t.Fatalf("FilterSlice returned error: %v", err)
+	}
+	if len(result) != 1 || result[0] != &staff[2] {
+		t.Errorf("Expected the original pointer to Michael, got %v", result)
+	}
+}
+
+func TestFilterSliceErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		contains string
+	}{
+		{"agee > 30", `did you mean "age"?`},
+		{"nme = John", `did you mean "name"?`},
+		{"Password = secret", `unknown field "Password"`},
+		{"internal = 1", `unknown field "internal"`},
+		{"address.town = Paris", `did you mean "address.city"?`},
+		{"active > 1", `cannot order bool field "active"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := FilterSlice(staff, tt.query)
+			var errs CheckErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Expected CheckErrors, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Expected error to contain %q, got %q", tt.contains, err.Error())
+			}
+		})
+	}
+
+	if _, err := FilterSlice([]int{1, 2, 3}, "x = 1"); err == nil {
+		t.Errorf("Expected an error for a non-struct element type")
+	}
+}
+
+func TestStructInfoCached(t *testing.T) {
+	first := structInfoFor(reflect.TypeFor[Employee]())
+	second := structInfoFor(reflect.TypeFor[Employee]())
+	if first != second {
+		t.Errorf("Expected reflection metadata to be computed once per type")
+	}
+	if _, ok := first.fields["Version"]; !ok {
+		t.Errorf("Expected fields of the embedded Audit struct to be promoted")
+	}
+}
+
+func TestFilterSliceLargeUnsigned(t *testing.T) {
+	type counter struct {
+		Count uint64
+	}
+	items := []counter{{Count: 5}, {Count: math.MaxUint64}}
+	result, err := FilterSlice(items, "Count > 100")
+	if err != nil {
+		t.Fatalf("Expected the query to check, got %v", err)
+	}
+	if len(result) != 1 || result[0].Count != math.MaxUint64 {
+		t.Errorf("Expected only the largest count to match, got %v", result)
+	}
+}
+
+func TestStructInfoCheckedBounded(t *testing.T) {
+	info := structInfoFor(reflect.TypeFor[Employee]())
+	for i := range defaultCacheSize + 10 {
+		info.check(fmt.Sprintf("age > %d", i))
+	}
+	if n := info.checked.len(); n > defaultCacheSize {
+		t.Errorf("Expected at most %d checked queries to be kept, got %d", defaultCacheSize, n)
+	}
+}
+
+func BenchmarkFilterSlice(b *testing.B) {
+	items := make([]Employee, 10000)
+	for i := range items {
+		items[i] = staff[i%len(staff)]
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := FilterSlice(items, "dept = Engineering AND age > 35 AND address.city = Berlin"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
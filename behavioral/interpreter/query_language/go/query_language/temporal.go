@@ -0,0 +1,211 @@
+package query_language
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateOnlyLayouts are the timestamp layouts that carry no zone information.
+// Literals in these layouts are interpreted in the parser's default timezone.
+var dateOnlyLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// RelativeTime represents a point in time relative to the moment of evaluation,
+// such as `now()` or `now() - 7d`
+type RelativeTime struct {
+	Offset time.Duration
+	Clock  func() time.Time
+}
+
+// Time resolves the relative time against its clock
+func (r *RelativeTime) Time() time.Time {
+	clock := r.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+	return clock().Add(r.Offset)
+}
+
+// parseTimestamp parses an RFC3339 timestamp, or a date-only value in loc
+func parseTimestamp(s string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range dateOnlyLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// durationUnits maps duration suffixes to their length, longest suffix first
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"ms", time.Millisecond},
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+}
+
+// parseDuration parses durations such as 7d, 36h, 1w2d or 1.5h
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && (rest[i] == '.' || (rest[i] >= '0' && rest[i] <= '9')) {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		amount, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		rest = rest[i:]
+
+		matched := false
+		for _, u := range durationUnits {
+			if strings.HasPrefix(rest, u.suffix) {
+				total += time.Duration(amount * float64(u.unit))
+				rest = rest[len(u.suffix):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return 0, fmt.Errorf("invalid duration unit in %q", s)
+		}
+	}
+	return total, nil
+}
+
+// findLastSplitIndex finds the index of the last operator outside parentheses.
+func findLastSplitIndex(query, operator string) int {
+	level := 0
+	opLen := len(operator)
+	for i := len(query) - 1; i >= 0; i-- {
+		if query[i] == ')' {
+			level++
+		} else if query[i] == '(' {
+			level--
+		} else if level == 0 && i+opLen <= len(query) && query[i:i+opLen] == operator {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseTemporal parses timestamp literals (`@2026-01-01T00:00:00Z`), `now()`
+// and duration arithmetic on either of them (`now() - 7d`).
+func (p *QueryParser) parseTemporal(valueStr string) (interface{}, bool) {
+	valueStr = strings.TrimSpace(valueStr)
+
+	// Duration arithmetic binds left to right, so split at the last operator
+	plus := findLastSplitIndex(valueStr, " + ")
+	minus := findLastSplitIndex(valueStr, " - ")
+	if split := max(plus, minus); split != -1 {
+		base, ok := p.parseTemporal(valueStr[:split])
+		if !ok {
+			return nil, false
+		}
+		offset, err := parseDuration(valueStr[split+3:])
+		if err != nil {
+			return nil, false
+		}
+		if split == minus {
+			offset = -offset
+		}
+		switch b := base.(type) {
+		case time.Time:
+			return b.Add(offset), true
+		case *RelativeTime:
+			return &RelativeTime{Offset: b.Offset + offset, Clock: b.Clock}, true
+		}
+		return nil, false
+	}
+
+	if strings.EqualFold(valueStr, "now()") {
+		return &RelativeTime{Clock: p.Clock}, true
+	}
+	if strings.HasPrefix(valueStr, "@") {
+		return parseTimestamp(valueStr[1:], p.Location)
+	}
+	return nil, false
+}
+
+// isTemporal reports whether v is a time value rather than something that
+// merely looks like one
+func isTemporal(v interface{}) bool {
+	switch v.(type) {
+	case time.Time, *time.Time, *RelativeTime:
+		return true
+	}
+	return false
+}
+
+// toTime converts a value to a time.Time. Strings are parsed in loc when they
+// carry no zone of their own.
+func toTime(v interface{}, loc *time.Location) (time.Time, bool) {
+	switch value := v.(type) {
+	case time.Time:
+		return value, true
+	case *time.Time:
+		if value == nil {
+			return time.Time{}, false
+		}
+		return *value, true
+	case *RelativeTime:
+		return value.Time(), true
+	case string:
+		return parseTimestamp(value, loc)
+	}
+	return time.Time{}, false
+}
+
+// compareTemporal compares value with target chronologically. It returns false
+// unless at least one side is a time value and both sides convert to a time.
+func compareTemporal(value, target interface{}) (int, bool) {
+	if !isTemporal(value) && !isTemporal(target) {
+		return 0, false
+	}
+
+	// Resolve the time side first so zone-less strings follow its location
+	var loc *time.Location
+	if isTemporal(target) {
+		if t, ok := toTime(target, nil); ok {
+			loc = t.Location()
+		}
+	} else if t, ok := toTime(value, nil); ok {
+		loc = t.Location()
+	}
+
+	left, ok := toTime(value, loc)
+	if !ok {
+		return 0, false
+	}
+	right, ok := toTime(target, loc)
+	if !ok {
+		return 0, false
+	}
+	return left.Compare(right), true
+}
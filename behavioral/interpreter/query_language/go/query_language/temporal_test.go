@@ -0,0 +1,177 @@
+package query_language
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var fixedNow = time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+// Shared data for temporal engine tests
+var eventData = []map[string]interface{}{
+	{"id": "a", "created_at": "2026-03-14T09:00:00Z"},
+	{"id": "b", "created_at": time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+	{"id": "c", "created_at": "2026-01-01"},
+	{"id": "d", "created_at": "2025-12-31T23:30:00+02:00"},
+	{"id": "f", "created_at": "2026-01-01T03:00:00Z"},
+	{"id": "e", "created_at": "not a date"},
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"7d", 7 * 24 * time.Hour},
+		{"36h", 36 * time.Hour},
+		{"1w2d", 9 * 24 * time.Hour},
+		{"1.5h", 90 * time.Minute},
+		{"90m", 90 * time.Minute},
+		{"250ms", 250 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseDuration(tt.input)
+			if err != nil {
+				t.Fatalf("parseDuration(%q) returned error: %v", tt.input, err)
+			}
+			if got != tt.expected {
+				t.Errorf("parseDuration(%q): expected %v, got %v", tt.input, tt.expected, got)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "d", "7y", "7d-"} {
+		if _, err := parseDuration(bad); err == nil {
+			t.Errorf("parseDuration(%q): expected error, got nil", bad)
+		}
+	}
+}
+
+func TestTemporalLiterals(t *testing.T) {
+	parser := QueryParser{Clock: func() time.Time { return fixedNow }}
+
+	t.Run("TimestampLiteral", func(t *testing.T) {
+		expr := parser.Parse("created_at = @2026-01-01T00:00:00Z").(*EqualsExpression)
+		expected := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		if got, ok := expr.Value.(time.Time); !ok || !got.Equal(expected) {
+			t.Errorf("Expected literal %v, got %v", expected, expr.Value)
+		}
+	})
+
+	t.Run("LiteralArithmetic", func(t *testing.T) {
+		expr := parser.Parse("created_at > @2026-01-01 + 1d12h").(*GreaterThanExpression)
+		expected := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+		if got, ok := expr.Value.(time.Time); !ok || !got.Equal(expected) {
+			t.Errorf("Expected literal %v, got %v", expected, expr.Value)
+		}
+	})
+
+	t.Run("RelativeTime", func(t *testing.T) {
+		expr := parser.Parse("created_at > now() - 7d + 1h").(*GreaterThanExpression)
+		rel, ok := expr.Value.(*RelativeTime)
+		if !ok {
+			t.Fatalf("Expected *RelativeTime, got %T", expr.Value)
+		}
+		expected := fixedNow.Add(-7*24*time.Hour + time.Hour)
+		if !rel.Time().Equal(expected) {
+			t.Errorf("Expected %v, got %v", expected, rel.Time())
+		}
+	})
+
+	t.Run("DefaultTimezone", func(t *testing.T) {
+		loc := time.FixedZone("UTC+2", 2*60*60)
+		zoned := QueryParser{Location: loc}
+		expr := zoned.Parse("created_at = @2026-01-01").(*EqualsExpression)
+		expected := time.Date(2026, 1, 1, 0, 0, 0, 0, loc)
+		if got, ok := expr.Value.(time.Time); !ok || !got.Equal(expected) {
+			t.Errorf("Expected literal %v, got %v", expected, expr.Value)
+		}
+	})
+
+	t.Run("InvalidLiteralStaysString", func(t *testing.T) {
+		expr := parser.Parse("handle = @someone").(*EqualsExpression)
+		if expr.Value != "@someone" {
+			t.Errorf("Expected string literal '@someone', got %v", expr.Value)
+		}
+	})
+}
+
+func TestTemporalComparisons(t *testing.T) {
+	ctx := Context{
+		"created_at": time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC),
+		"updated_at": "2026-03-12T10:00:00+01:00",
+	}
+	parser := QueryParser{Clock: func() time.Time { return fixedNow }}
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"created_at > now() - 7d", true},
+		{"created_at > now() - 1d", false},
+		{"created_at < @2026-03-10T08:00:01Z", true},
+		{"created_at = @2026-03-10T09:00:00+01:00", true},
+		{"created_at = \"2026-03-10T08:00:00Z\"", true},
+		{"updated_at > @2026-03-12T09:30:00Z", false},
+		{"updated_at = @2026-03-12T09:00:00Z", true},
+		{"updated_at < now()", true},
+		{"created_at > @2026-03-01 AND updated_at < @2026-03-13", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			result := parser.Parse(tt.query).Interpret(ctx)
+			if result != tt.expected {
+				t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestQueryEngineTemporal(t *testing.T) {
+	engine := NewQueryEngine()
+	engine.SetClock(func() time.Time { return fixedNow })
+
+	tests := []struct {
+		name        string
+		query       string
+		expectedIDs []string
+	}{
+		{"LastWeek", "created_at > now() - 7d", []string{"a"}},
+		{"SinceMarch", "created_at > @2026-02-28", []string{"a", "b"}},
+		{"BeforeNewYear", "created_at < @2026-01-01", []string{"d"}},
+		{"NewYearExact", "created_at = @2026-01-01T00:00:00Z", []string{"c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := engine.Filter(eventData, tt.query)
+			ids := make([]string, len(result))
+			for i, item := range result {
+				ids[i] = item["id"].(string)
+			}
+			if !reflect.DeepEqual(ids, tt.expectedIDs) {
+				t.Errorf("Query '%s': expected ids %v, got %v", tt.query, tt.expectedIDs, ids)
+			}
+		})
+	}
+
+	t.Run("Timezone", func(t *testing.T) {
+		engine.SetTimezone(time.FixedZone("UTC-5", -5*60*60))
+		defer engine.SetTimezone(nil)
+
+		// Midnight of 2026-01-01 in UTC-5 is 05:00Z, so "f" now falls before it
+		result := engine.Filter(eventData, "created_at < @2026-01-01")
+		ids := make([]string, len(result))
+		for i, item := range result {
+			ids[i] = item["id"].(string)
+		}
+		expected := []string{"d", "f"}
+		if !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Expected ids %v, got %v", expected, ids)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"iterator_pattern_data_stream_processor_go/data_stream_processor"
+	"iterator_pattern_data_stream_processor_go/stream"
 	"log"
 )
 
@@ -53,4 +54,14 @@ func main() {
 	} else {
 		fmt.Println("  Did not get expected error for out-of-bounds Get()")
 	}
-}
\ No newline at end of file
+
+	// Typed streams need no type assertions and work with for range
+	fmt.Println("\nIterating a typed stream with for range:")
+	sizes := stream.Of(512, 1024, 2048)
+	total := 0
+	for i, size := range sizes.All() {
+		total += size
+		fmt.Printf("  Chunk %d: %d bytes\n", i, size)
+	}
+	fmt.Printf("  Total: %d bytes\n", total)
+}
@@ -0,0 +1,335 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Checkpoint kinds
+const (
+	SliceCheckpoint = "slice"
+	FileCheckpoint  = "file"
+)
+
+// Checkpoint is a serializable iterator position. Offset is the index of the
+// next element of a slice source, or the byte offset of the next record of a
+// file source; Items counts the elements consumed before it.
+type Checkpoint struct {
+	Kind   string `json:"kind"`
+	Offset int64  `json:"offset"`
+	Items  int64  `json:"items"`
+}
+
+// Seekable is an Iterator that can look ahead and move to any position.
+type Seekable[T any] interface {
+	Iterator[T]
+	Peek() (T, error)
+	Reset() error
+	Seek(n int) error
+}
+
+// Checkpointable is an Iterator whose position can be saved and restored.
+type Checkpointable[T any] interface {
+	Iterator[T]
+	Checkpoint() Checkpoint
+	Restore(cp Checkpoint) error
+}
+
+// Position returns the index of the next element.
+func (it *StreamIterator[T]) Position() int {
+	return it.position
+}
+
+// Peek returns the next element without advancing.
+func (it *StreamIterator[T]) Peek() (T, error) {
+	value, err := it.Next()
+	if err == nil {
+		it.position--
+	}
+	return value, err
+}
+
+// Reset moves back to the first element. A fail-fast iterator starts over
+// from the current state of the stream.
+func (it *StreamIterator[T]) Reset() error {
+	return it.Seek(0)
+}
+
+// Seek moves to the nth element, so that Next returns it.
+func (it *StreamIterator[T]) Seek(n int) error {
+	length := len(it.snapshot)
+	if it.stream != nil {
+		var modCount int
+		it.stream.mu.RLock()
+		length, modCount = len(it.stream.dataChunks), it.stream.modCount
+		it.stream.mu.RUnlock()
+		if n >= 0 && n <= length {
+			it.modCount, it.failed = modCount, false
+		}
+	}
+	if n < 0 || n > length {
+		return ErrIndexOutOfBounds
+	}
+	it.position = n
+	return nil
+}
+
+// Checkpoint returns the current position.
+func (it *StreamIterator[T]) Checkpoint() Checkpoint {
+	return Checkpoint{Kind: SliceCheckpoint, Offset: int64(it.position), Items: int64(it.position)}
+}
+
+// Restore moves to a position returned by Checkpoint.
+func (it *StreamIterator[T]) Restore(cp Checkpoint) error {
+	if cp.Kind != SliceCheckpoint {
+		return fmt.Errorf("cannot restore a %q checkpoint on a slice iterator", cp.Kind)
+	}
+	return it.Seek(int(cp.Offset))
+}
+
+// LineIterator is a seekable, checkpointable Source over the lines of a file
+// or any io.ReadSeeker. Its checkpoints are byte offsets.
+type LineIterator struct {
+	rs     io.ReadSeeker
+	reader *bufio.Reader
+	offset int64 // Byte offset of the next line
+	items  int64 // Lines consumed
+
+	peeked   bool
+	line     string
+	size     int64 // Bytes of the peeked line, including its line ending
+	err      error
+	finished bool
+	closed   bool
+}
+
+// SeekableLines creates a LineIterator over rs, starting at its current
+// position. Lines are returned without line endings. Closing it closes rs
+// when rs is an io.Closer.
+func SeekableLines(rs io.ReadSeeker) *LineIterator {
+	it := &LineIterator{rs: rs, reader: bufio.NewReader(rs)}
+	if offset, err := rs.Seek(0, io.SeekCurrent); err == nil {
+		it.offset = offset
+	}
+	return it
+}
+
+// OpenLines opens the named file as a LineIterator.
+func OpenLines(name string) (*LineIterator, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return SeekableLines(file), nil
+}
+
+// fill reads the next line into the peek buffer
+func (it *LineIterator) fill() {
+	if it.peeked || it.finished || it.closed {
+		return
+	}
+	line, err := it.reader.ReadString('\n')
+	if err == io.EOF && line == "" {
+		it.finished = true
+		return
+	}
+	it.peeked = true
+	it.size = int64(len(line))
+	it.line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if err != io.EOF {
+		it.err = err
+	}
+}
+
+// HasNext checks if there are more lines.
+func (it *LineIterator) HasNext() bool {
+	it.fill()
+	return it.peeked
+}
+
+// Peek returns the next line without advancing.
+func (it *LineIterator) Peek() (string, error) {
+	if !it.HasNext() {
+		return "", ErrNoMoreElements
+	}
+	return it.line, it.err
+}
+
+// Next returns the next line.
+func (it *LineIterator) Next() (string, error) {
+	line, err := it.Peek()
+	if errors.Is(err, ErrNoMoreElements) {
+		return line, err
+	}
+	if err != nil {
+		// A read error ends the iteration
+		it.peeked, it.finished = false, true
+		return "", err
+	}
+	it.peeked = false
+	it.offset += it.size
+	it.items++
+	return line, nil
+}
+
+// restart repositions the reader at a byte offset
+func (it *LineIterator) restart(offset, items int64) error {
+	if it.closed {
+		return os.ErrClosed
+	}
+	if _, err := it.rs.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	it.reader.Reset(it.rs)
+	it.offset, it.items = offset, items
+	it.peeked, it.finished, it.err = false, false, nil
+	return nil
+}
+
+// Reset moves back to the start of the input.
+func (it *LineIterator) Reset() error {
+	return it.restart(0, 0)
+}
+
+// Seek moves to the nth line, reading the lines before it.
+func (it *LineIterator) Seek(n int) error {
+	if n < 0 {
+		return ErrIndexOutOfBounds
+	}
+	if err := it.Reset(); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if !it.HasNext() {
+			return ErrIndexOutOfBounds
+		}
+		if _, err := it.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checkpoint returns the byte offset of the next line.
+func (it *LineIterator) Checkpoint() Checkpoint {
+	return Checkpoint{Kind: FileCheckpoint, Offset: it.offset, Items: it.items}
+}
+
+// Restore moves to a position returned by Checkpoint.
+func (it *LineIterator) Restore(cp Checkpoint) error {
+	if cp.Kind != FileCheckpoint {
+		return fmt.Errorf("cannot restore a %q checkpoint on a file iterator", cp.Kind)
+	}
+	return it.restart(cp.Offset, cp.Items)
+}
+
+// Close ends the iteration and closes the input.
+func (it *LineIterator) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed, it.peeked = true, false
+	if c, ok := it.rs.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// CheckpointStore persists the checkpoint of a resumable job.
+type CheckpointStore interface {
+	// Load returns the saved checkpoint, or false when there is none.
+	Load() (Checkpoint, bool, error)
+	Save(cp Checkpoint) error
+}
+
+// FileCheckpointStore keeps a checkpoint in a JSON file. Saving replaces the
+// file atomically, so a crash while saving leaves the previous checkpoint.
+type FileCheckpointStore struct {
+	path string
+}
+
+// NewFileCheckpointStore creates a store keeping its checkpoint at path.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+// Load reads the saved checkpoint.
+func (s *FileCheckpointStore) Load() (Checkpoint, bool, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Checkpoint{}, false, nil
+	}
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return Checkpoint{}, false, fmt.Errorf("reading checkpoint %s: %w", s.path, err)
+	}
+	return cp, true, nil
+}
+
+// Save writes the checkpoint to a temporary file and renames it into place.
+func (s *FileCheckpointStore) Save(cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Resume runs process on every element of it, restoring the checkpoint in
+// store first and saving a new one after every n processed elements and at
+// the end. After a crash, elements processed since the last checkpoint are
+// processed again, so process should be idempotent. The first error from
+// the iterator or from process stops the run.
+func Resume[T any](it Checkpointable[T], store CheckpointStore, every int, process func(T) error) error {
+	cp, ok, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := it.Restore(cp); err != nil {
+			return err
+		}
+	}
+
+	every = max(every, 1)
+	for processed := 1; it.HasNext(); processed++ {
+		value, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if err := process(value); err != nil {
+			return err
+		}
+		if processed%every == 0 {
+			if err := store.Save(it.Checkpoint()); err != nil {
+				return err
+			}
+		}
+	}
+	return store.Save(it.Checkpoint())
+}
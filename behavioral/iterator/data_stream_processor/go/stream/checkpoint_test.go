@@ -0,0 +1,186 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStreamIteratorSeek(t *testing.T) {
+	it := Of("a", "b", "c", "d").FailFastIterator()
+
+	if v, _ := it.Peek(); v != "a" {
+		t.Errorf("Expected Peek to return a, got %q", v)
+	}
+	if v, _ := it.Next(); v != "a" {
+		t.Errorf("Expected Peek not to advance, got %q", v)
+	}
+	if err := it.Seek(3); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := it.Next(); v != "d" {
+		t.Errorf("Expected d after Seek(3), got %q", v)
+	}
+	if _, err := it.Peek(); !errors.Is(err, ErrNoMoreElements) {
+		t.Errorf("Expected ErrNoMoreElements peeking at the end, got %v", err)
+	}
+	if err := it.Seek(5); !errors.Is(err, ErrIndexOutOfBounds) {
+		t.Errorf("Expected ErrIndexOutOfBounds, got %v", err)
+	}
+
+	cp := it.Checkpoint()
+	it.Reset()
+	if v, _ := it.Next(); v != "a" || it.Position() != 1 {
+		t.Errorf("Expected a at position 1 after Reset, got %q at %d", v, it.Position())
+	}
+	if err := it.Restore(cp); err != nil || it.HasNext() {
+		t.Errorf("Expected to be restored at the end, got %v", err)
+	}
+	if err := it.Restore(Checkpoint{Kind: FileCheckpoint}); err == nil {
+		t.Error("Expected an error restoring a file checkpoint")
+	}
+}
+
+func TestLineIterator(t *testing.T) {
+	it := SeekableLines(strings.NewReader("alpha\r\nbeta\n\ngamma"))
+	var lines []string
+	var offsets []int64
+	for it.HasNext() {
+		offsets = append(offsets, it.Checkpoint().Offset)
+		line, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if !reflect.DeepEqual(lines, []string{"alpha", "beta", "", "gamma"}) {
+		t.Errorf("Unexpected lines %q", lines)
+	}
+	if !reflect.DeepEqual(offsets, []int64{0, 7, 12, 13}) {
+		t.Errorf("Unexpected byte offsets %v", offsets)
+	}
+
+	if err := it.Restore(Checkpoint{Kind: FileCheckpoint, Offset: 7, Items: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := it.Peek(); line != "beta" {
+		t.Errorf("Expected beta at offset 7, got %q", line)
+	}
+	if cp := it.Checkpoint(); cp.Offset != 7 || cp.Items != 1 {
+		t.Errorf("Expected Peek not to move the checkpoint, got %+v", cp)
+	}
+
+	if err := it.Seek(3); err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := it.Next(); line != "gamma" {
+		t.Errorf("Expected gamma after Seek(3), got %q", line)
+	}
+	if err := it.Seek(9); !errors.Is(err, ErrIndexOutOfBounds) {
+		t.Errorf("Expected ErrIndexOutOfBounds seeking past the end, got %v", err)
+	}
+}
+
+// writeLines writes n numbered lines to a temp file
+func writeLines(t *testing.T, n int) string {
+	t.Helper()
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "record-%d\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "input.log")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResumeAfterCrash(t *testing.T) {
+	input := writeLines(t, 10)
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "job.checkpoint"))
+	crash := errors.New("crash")
+
+	var processed []string
+	run := func(crashAt string) error {
+		it, err := OpenLines(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer it.Close()
+		return Resume[string](it, store, 3, func(line string) error {
+			if line == crashAt {
+				return crash
+			}
+			processed = append(processed, line)
+			return nil
+		})
+	}
+
+	if err := run("record-7"); err != crash {
+		t.Fatalf("Expected the first run to crash, got %v", err)
+	}
+	cp, ok, err := store.Load()
+	if err != nil || !ok || cp.Items != 6 || cp.Offset != 6*int64(len("record-0\n")) {
+		t.Fatalf("Expected a checkpoint after 6 records, got %+v, %v, %v", cp, ok, err)
+	}
+
+	processed = nil
+	if err := run(""); err != nil {
+		t.Fatalf("Unexpected error resuming: %v", err)
+	}
+	expected := []string{"record-6", "record-7", "record-8", "record-9"}
+	if !reflect.DeepEqual(processed, expected) {
+		t.Errorf("Expected to resume at record-6, got %v", processed)
+	}
+
+	processed = nil
+	if err := run(""); err != nil || len(processed) != 0 {
+		t.Errorf("Expected a finished job to process nothing, got %v, %v", processed, err)
+	}
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileCheckpointStore(filepath.Join(dir, "cp.json"))
+	if _, ok, err := store.Load(); ok || err != nil {
+		t.Fatalf("Expected no checkpoint yet, got %v, %v", ok, err)
+	}
+
+	want := Checkpoint{Kind: SliceCheckpoint, Offset: 42, Items: 42}
+	if err := store.Save(want); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "cp.json"))
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded["kind"] != "slice" {
+		t.Errorf("Expected a JSON checkpoint, got %s", data)
+	}
+	if got, ok, _ := store.Load(); !ok || got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files to be left, got %d entries", len(entries))
+	}
+
+	// A checkpoint past the end of a slice source can't be restored
+	slice := Of(1, 2, 3, 4, 5).FailFastIterator()
+	if err := Resume[int](slice, store, 2, func(int) error { return nil }); !errors.Is(err, ErrIndexOutOfBounds) {
+		t.Errorf("Expected ErrIndexOutOfBounds, got %v", err)
+	}
+
+	fresh := NewFileCheckpointStore(filepath.Join(dir, "slice.json"))
+	var sum int
+	if err := Resume[int](slice, fresh, 2, func(n int) error { sum += n; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := fresh.Load(); sum != 15 || got.Items != 5 {
+		t.Errorf("Expected sum 15 and a final checkpoint at 5, got %d and %+v", sum, got)
+	}
+}
@@ -0,0 +1,244 @@
+package stream
+
+// Combinators wrap an upstream Iterator and pull from it only as elements are
+// requested, so pipelines never materialize intermediate slices. An error
+// returned by the upstream Next is returned by the downstream Next unchanged.
+
+// Pair holds two values, as produced by Zip and Enumerate.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// pullIterator adapts a function producing one element at a time into an
+// Iterator. pull reports false once there are no more elements.
+type pullIterator[T any] struct {
+	pull    func() (T, error, bool)
+	value   T
+	err     error
+	pending bool
+	done    bool
+}
+
+// newPullIterator creates an Iterator over the elements produced by pull.
+func newPullIterator[T any](pull func() (T, error, bool)) *pullIterator[T] {
+	return &pullIterator[T]{pull: pull}
+}
+
+// HasNext checks if there are more elements, producing the next one if needed.
+func (it *pullIterator[T]) HasNext() bool {
+	if it.pending || it.done {
+		return it.pending
+	}
+	value, err, ok := it.pull()
+	if !ok {
+		it.done = true
+		return false
+	}
+	it.value, it.err, it.pending = value, err, true
+	return true
+}
+
+// Next returns the next element.
+func (it *pullIterator[T]) Next() (T, error) {
+	var zero T
+	if !it.HasNext() {
+		return zero, ErrNoMoreElements
+	}
+	value, err := it.value, it.err
+	it.value, it.err, it.pending = zero, nil, false
+	return value, err
+}
+
+// Map returns an Iterator applying fn to every element.
+func Map[T, U any](it Iterator[T], fn func(T) U) Iterator[U] {
+	return newPullIterator(func() (U, error, bool) {
+		var zero U
+		if !it.HasNext() {
+			return zero, nil, false
+		}
+		value, err := it.Next()
+		if err != nil {
+			return zero, err, true
+		}
+		return fn(value), nil, true
+	})
+}
+
+// Filter returns an Iterator over the elements for which keep returns true.
+func Filter[T any](it Iterator[T], keep func(T) bool) Iterator[T] {
+	return newPullIterator(func() (T, error, bool) {
+		for it.HasNext() {
+			value, err := it.Next()
+			if err != nil || keep(value) {
+				return value, err, true
+			}
+		}
+		var zero T
+		return zero, nil, false
+	})
+}
+
+// FlatMap returns an Iterator over the elements of the iterators fn returns
+// for every element.
+func FlatMap[T, U any](it Iterator[T], fn func(T) Iterator[U]) Iterator[U] {
+	var inner Iterator[U]
+	return newPullIterator(func() (U, error, bool) {
+		var zero U
+		for inner == nil || !inner.HasNext() {
+			if !it.HasNext() {
+				return zero, nil, false
+			}
+			value, err := it.Next()
+			if err != nil {
+				return zero, err, true
+			}
+			inner = fn(value)
+		}
+		value, err := inner.Next()
+		return value, err, true
+	})
+}
+
+// Take returns an Iterator over at most the first n elements. Upstream is
+// not pulled past the nth element.
+func Take[T any](it Iterator[T], n int) Iterator[T] {
+	taken := 0
+	return newPullIterator(func() (T, error, bool) {
+		var zero T
+		if taken >= n || !it.HasNext() {
+			return zero, nil, false
+		}
+		value, err := it.Next()
+		if err == nil {
+			taken++
+		}
+		return value, err, true
+	})
+}
+
+// Skip returns an Iterator over the elements after the first n.
+func Skip[T any](it Iterator[T], n int) Iterator[T] {
+	skipped := 0
+	return newPullIterator(func() (T, error, bool) {
+		for it.HasNext() {
+			value, err := it.Next()
+			if err != nil || skipped >= n {
+				return value, err, true
+			}
+			skipped++
+		}
+		var zero T
+		return zero, nil, false
+	})
+}
+
+// TakeWhile returns an Iterator over the leading elements for which keep
+// returns true. The first element failing keep is consumed and dropped.
+func TakeWhile[T any](it Iterator[T], keep func(T) bool) Iterator[T] {
+	stopped := false
+	return newPullIterator(func() (T, error, bool) {
+		var zero T
+		if stopped || !it.HasNext() {
+			return zero, nil, false
+		}
+		value, err := it.Next()
+		if err != nil {
+			return zero, err, true
+		}
+		if !keep(value) {
+			stopped = true
+			return zero, nil, false
+		}
+		return value, nil, true
+	})
+}
+
+// Zip returns an Iterator pairing the elements of two iterators. It ends with
+// the shorter one.
+func Zip[A, B any](a Iterator[A], b Iterator[B]) Iterator[Pair[A, B]] {
+	return newPullIterator(func() (Pair[A, B], error, bool) {
+		if !a.HasNext() || !b.HasNext() {
+			return Pair[A, B]{}, nil, false
+		}
+		first, err := a.Next()
+		if err != nil {
+			return Pair[A, B]{}, err, true
+		}
+		second, err := b.Next()
+		if err != nil {
+			return Pair[A, B]{}, err, true
+		}
+		return Pair[A, B]{First: first, Second: second}, nil, true
+	})
+}
+
+// Chain returns an Iterator over the elements of each iterator in turn.
+func Chain[T any](its ...Iterator[T]) Iterator[T] {
+	return newPullIterator(func() (T, error, bool) {
+		for len(its) > 0 && !its[0].HasNext() {
+			its = its[1:]
+		}
+		if len(its) == 0 {
+			var zero T
+			return zero, nil, false
+		}
+		value, err := its[0].Next()
+		return value, err, true
+	})
+}
+
+// Distinct returns an Iterator skipping elements already seen. It remembers
+// every distinct element.
+func Distinct[T comparable](it Iterator[T]) Iterator[T] {
+	seen := make(map[T]struct{})
+	return Filter(it, func(value T) bool {
+		if _, ok := seen[value]; ok {
+			return false
+		}
+		seen[value] = struct{}{}
+		return true
+	})
+}
+
+// Batch returns an Iterator over slices of n consecutive elements; the last
+// batch may be shorter. When upstream fails mid-batch the error is returned
+// first and the batch is completed by later calls.
+func Batch[T any](it Iterator[T], n int) Iterator[[]T] {
+	if n < 1 {
+		n = 1
+	}
+	var batch []T
+	return newPullIterator(func() ([]T, error, bool) {
+		for len(batch) < n && it.HasNext() {
+			value, err := it.Next()
+			if err != nil {
+				return nil, err, true
+			}
+			batch = append(batch, value)
+		}
+		if len(batch) == 0 {
+			return nil, nil, false
+		}
+		full := batch
+		batch = nil
+		return full, nil, true
+	})
+}
+
+// Peek returns an Iterator calling fn with every element as it passes through.
+func Peek[T any](it Iterator[T], fn func(T)) Iterator[T] {
+	return Map(it, func(value T) T {
+		fn(value)
+		return value
+	})
+}
+
+// Enumerate returns an Iterator pairing every element with its index.
+func Enumerate[T any](it Iterator[T]) Iterator[Pair[int, T]] {
+	index := 0
+	return Map(it, func(value T) Pair[int, T] {
+		index++
+		return Pair[int, T]{First: index - 1, Second: value}
+	})
+}
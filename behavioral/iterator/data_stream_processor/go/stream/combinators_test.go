@@ -0,0 +1,171 @@
+package stream
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// naturals returns an endless Iterator over 0, 1, 2, ... and a counter of the
+// elements pulled from it
+func naturals() (Iterator[int], *int) {
+	pulled := 0
+	next := 0
+	return newPullIterator(func() (int, error, bool) {
+		pulled++
+		next++
+		return next - 1, nil, true
+	}), &pulled
+}
+
+func ints(values ...int) Iterator[int] {
+	return Of(values...).CreateIterator()
+}
+
+func TestCombinators(t *testing.T) {
+	even := func(n int) bool { return n%2 == 0 }
+	tests := []struct {
+		name     string
+		it       Iterator[int]
+		expected []int
+	}{
+		{"Map", Map(ints(1, 2, 3), func(n int) int { return n * n }), []int{1, 4, 9}},
+		{"Filter", Filter(ints(1, 2, 3, 4), even), []int{2, 4}},
+		{"FlatMap", FlatMap(ints(1, 2, 0, 3), func(n int) Iterator[int] {
+			return Take(ints(0, 1, 2, 3), n)
+		}), []int{0, 0, 1, 0, 1, 2}},
+		{"Take", Take(ints(1, 2, 3), 2), []int{1, 2}},
+		{"TakeMore", Take(ints(1, 2), 5), []int{1, 2}},
+		{"Skip", Skip(ints(1, 2, 3), 2), []int{3}},
+		{"TakeWhile", TakeWhile(ints(2, 4, 5, 6), even), []int{2, 4}},
+		{"Chain", Chain(ints(1), ints(), ints(2, 3)), []int{1, 2, 3}},
+		{"Distinct", Distinct(ints(3, 1, 3, 2, 1)), []int{3, 1, 2}},
+		{"Pipeline", Take(Filter(Map(ints(1, 2, 3, 4, 5, 6), func(n int) int { return n * 3 }), even), 2), []int{6, 12}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Collect(tt.it)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestZipAndEnumerate(t *testing.T) {
+	zipped, _ := Collect(Zip(ints(1, 2, 3), Of("a", "b").CreateIterator()))
+	expected := []Pair[int, string]{{1, "a"}, {2, "b"}}
+	if !reflect.DeepEqual(zipped, expected) {
+		t.Errorf("Expected %v, got %v", expected, zipped)
+	}
+
+	enumerated, _ := Collect(Enumerate(Of("x", "y").CreateIterator()))
+	if !reflect.DeepEqual(enumerated, []Pair[int, string]{{0, "x"}, {1, "y"}}) {
+		t.Errorf("Unexpected enumeration %v", enumerated)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	batches, _ := Collect(Batch(ints(1, 2, 3, 4, 5), 2))
+	if !reflect.DeepEqual(batches, [][]int{{1, 2}, {3, 4}, {5}}) {
+		t.Errorf("Expected [[1 2] [3 4] [5]], got %v", batches)
+	}
+
+	boom := errors.New("boom")
+	it := Batch(Chain[int](&failingIterator{values: []int{1}, err: boom}, ints(2, 3)), 2)
+	if _, err := it.Next(); err != boom {
+		t.Fatalf("Expected boom, got %v", err)
+	}
+	rest, err := Collect(it)
+	if err != nil || !reflect.DeepEqual(rest, [][]int{{1, 2}, {3}}) {
+		t.Errorf("Expected the batch to resume after the error, got %v, %v", rest, err)
+	}
+}
+
+func TestCombinatorsAreLazy(t *testing.T) {
+	source, pulled := naturals()
+	var seen []int
+	it := Take(Peek(Filter(source, func(n int) bool { return n%3 == 0 }), func(n int) {
+		seen = append(seen, n)
+	}), 3)
+
+	if *pulled != 0 {
+		t.Errorf("Expected nothing to be pulled before iterating, got %d", *pulled)
+	}
+	result, err := Collect(it)
+	if err != nil || !reflect.DeepEqual(result, []int{0, 3, 6}) {
+		t.Fatalf("Expected [0 3 6], got %v, %v", result, err)
+	}
+	if *pulled != 7 {
+		t.Errorf("Expected 7 elements to be pulled from the source, got %d", *pulled)
+	}
+	if !reflect.DeepEqual(seen, result) {
+		t.Errorf("Expected Peek to see %v, got %v", result, seen)
+	}
+
+	if first, ok, _ := First(Skip(source, 10)); !ok || first != 17 {
+		t.Errorf("Expected First to return 17, got %d", first)
+	}
+}
+
+func TestErrorsPropagate(t *testing.T) {
+	boom := errors.New("boom")
+	failing := func() Iterator[int] { return &failingIterator{values: []int{1, 2}, err: boom} }
+	identity := func(n int) int { return n }
+	pipelines := map[string]Iterator[int]{
+		"Map":       Map(failing(), identity),
+		"Filter":    Filter(failing(), func(int) bool { return true }),
+		"FlatMap":   FlatMap(failing(), func(n int) Iterator[int] { return ints(n) }),
+		"Take":      Take(failing(), 5),
+		"Skip":      Skip(failing(), 1),
+		"TakeWhile": TakeWhile(failing(), func(int) bool { return true }),
+		"Chain":     Chain(ints(0), failing()),
+		"Distinct":  Distinct(failing()),
+		"Zip":       Map(Zip(failing(), ints(1, 2, 3)), func(p Pair[int, int]) int { return p.First }),
+	}
+	for name, it := range pipelines {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Collect(it); err != boom {
+				t.Errorf("Expected boom to propagate, got %v", err)
+			}
+		})
+	}
+
+	if n, err := Count(failing()); n != 2 || err != boom {
+		t.Errorf("Expected Count to stop at the error with 2, got %d, %v", n, err)
+	}
+}
+
+func TestTerminalOperations(t *testing.T) {
+	sum, _ := Reduce(ints(1, 2, 3, 4), 0, func(acc, n int) int { return acc + n })
+	if sum != 10 {
+		t.Errorf("Expected sum 10, got %d", sum)
+	}
+
+	if n, _ := Count(ints(5, 6, 7)); n != 3 {
+		t.Errorf("Expected count 3, got %d", n)
+	}
+
+	if _, ok, err := First(ints()); ok || err != nil {
+		t.Errorf("Expected no first element of an empty stream")
+	}
+
+	words := Of("apple", "avocado", "banana", "blueberry", "cherry").CreateIterator()
+	groups, _ := GroupBy(words, func(w string) string { return w[:1] })
+	expected := map[string][]string{"a": {"apple", "avocado"}, "b": {"banana", "blueberry"}, "c": {"cherry"}}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected %v, got %v", expected, groups)
+	}
+
+	long, short, _ := Partition(Of("go", "iterator", "map").CreateIterator(), func(w string) bool {
+		return len(w) > 3
+	})
+	if strings.Join(long, ",") != "iterator" || strings.Join(short, ",") != "go,map" {
+		t.Errorf("Unexpected partition %v / %v", long, short)
+	}
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestFailFastIterator(t *testing.T) {
+	ds := Of(1, 2, 3)
+	it := ds.CreateIterator()
+	if v, err := it.Next(); v != 1 || err != nil {
+		t.Fatalf("Expected 1, got %d, %v", v, err)
+	}
+
+	ds.AddChunk(4)
+	if _, err := it.Next(); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("Expected ErrConcurrentModification, got %v", err)
+	}
+	if it.HasNext() {
+		t.Error("Expected HasNext to be false after a modification was reported")
+	}
+	if _, err := it.Next(); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("Expected the modification to be reported again, got %v", err)
+	}
+
+	if n, err := Count(ds.CreateIterator()); n != 4 || err != nil {
+		t.Errorf("Expected a new iterator to see 4 chunks, got %d, %v", n, err)
+	}
+}
+
+func TestSnapshotIterator(t *testing.T) {
+	ds := Of("a", "b")
+	ds.SetIterationMode(Snapshot)
+	it := ds.CreateIterator()
+	ds.AddChunk("c")
+
+	values, err := Collect(it)
+	if err != nil || !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Errorf("Expected the snapshot [a b], got %v, %v", values, err)
+	}
+
+	var ranged []string
+	for v := range ds.Values() {
+		ds.AddChunk("added while ranging")
+		ranged = append(ranged, v)
+	}
+	if !reflect.DeepEqual(ranged, []string{"a", "b", "c"}) {
+		t.Errorf("Expected range to walk a snapshot, got %v", ranged)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	ds := NewDataStream[int]()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				ds.AddChunk(i)
+			}
+		}()
+	}
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				before := ds.GetCount()
+				snapshot := ds.SnapshotIterator()
+				after := ds.GetCount()
+				n, err := Count[int](snapshot)
+				if err != nil || n < before || n > after {
+					t.Errorf("Expected a snapshot of %d to %d chunks, got %d, %v", before, after, n, err)
+				}
+				// A fail-fast iterator either walks everything or reports the race
+				if _, err := Count[int](ds.FailFastIterator()); err != nil && !errors.Is(err, ErrConcurrentModification) {
+					t.Errorf("Unexpected error from a fail-fast iterator: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ds.GetCount() != 2000 {
+		t.Errorf("Expected 2000 chunks, got %d", ds.GetCount())
+	}
+}
@@ -0,0 +1,243 @@
+package stream
+
+import (
+	"context"
+	"sync"
+)
+
+// parallelResult is the outcome of one upstream element
+type parallelResult[U any] struct {
+	index int
+	value U
+	err   error
+}
+
+// parallelJob is an upstream element, or an upstream error, to process
+type parallelJob[T any] struct {
+	index int
+	value T
+	err   error
+}
+
+// ParallelIterator is the Iterator returned by ParallelMap. Its settings must
+// be changed before the first call to HasNext or Next.
+type ParallelIterator[T, U any] struct {
+	ctx      context.Context
+	upstream Iterator[T]
+	workers  int
+	fn       func(context.Context, T) (U, error)
+
+	ordered  bool
+	failFast bool
+	buffer   int
+
+	started  bool
+	done     bool
+	cancel   context.CancelFunc
+	slots    chan struct{} // Holds a token for every element read but not yet returned
+	results  chan parallelResult[U]
+	stop     chan struct{} // Closed to stop reading upstream
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	pending map[int]parallelResult[U] // Results waiting for their turn
+	next    int                       // Index of the next result in order
+	ready   *parallelResult[U]
+}
+
+// ParallelMap returns an Iterator applying fn to every element on a pool of
+// worker goroutines. Results come back in input order unless SetOrdered(false)
+// is called. Upstream is read by a single goroutine, at most a buffer's worth
+// of elements ahead of the consumer. Errors from upstream are returned in
+// place of their element. Cancelling ctx ends the iteration with ctx.Err().
+func ParallelMap[T, U any](ctx context.Context, it Iterator[T], workers int, fn func(context.Context, T) (U, error)) *ParallelIterator[T, U] {
+	if workers < 1 {
+		workers = 1
+	}
+	return &ParallelIterator[T, U]{
+		ctx:      ctx,
+		upstream: it,
+		workers:  workers,
+		fn:       fn,
+		ordered:  true,
+		buffer:   2 * workers,
+	}
+}
+
+// SetOrdered selects whether results keep the input order. Unordered results
+// are returned as soon as they are ready.
+func (p *ParallelIterator[T, U]) SetOrdered(ordered bool) {
+	p.ordered = ordered
+}
+
+// SetFailFast selects whether the first error stops reading upstream. The
+// iteration then ends after returning that error.
+func (p *ParallelIterator[T, U]) SetFailFast(failFast bool) {
+	p.failFast = failFast
+}
+
+// SetBuffer sets how many elements may be read from upstream but not yet
+// returned by Next.
+func (p *ParallelIterator[T, U]) SetBuffer(size int) {
+	p.buffer = max(size, 1)
+}
+
+// start launches the reader and the workers
+func (p *ParallelIterator[T, U]) start() {
+	p.started = true
+	p.ctx, p.cancel = context.WithCancel(p.ctx)
+	p.slots = make(chan struct{}, p.buffer)
+	// Every result holds a slot, so sending a result never blocks
+	p.results = make(chan parallelResult[U], p.buffer)
+	p.stop = make(chan struct{})
+	p.pending = make(map[int]parallelResult[U])
+	jobs := make(chan parallelJob[T])
+
+	p.wg.Add(1)
+	go p.read(jobs)
+
+	var workers sync.WaitGroup
+	workers.Add(p.workers)
+	for i := 0; i < p.workers; i++ {
+		go func() {
+			defer workers.Done()
+			p.work(jobs)
+		}()
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		workers.Wait()
+		close(p.results)
+	}()
+}
+
+// read feeds upstream elements to the workers, waiting for a free slot
+// before reading each one
+func (p *ParallelIterator[T, U]) read(jobs chan<- parallelJob[T]) {
+	defer p.wg.Done()
+	defer close(jobs)
+	for index := 0; ; index++ {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+		select {
+		case p.slots <- struct{}{}:
+		case <-p.stop:
+			return
+		case <-p.ctx.Done():
+			return
+		}
+		if !p.upstream.HasNext() {
+			return
+		}
+		value, err := p.upstream.Next()
+		if err != nil && p.failFast {
+			p.halt()
+		}
+		select {
+		case jobs <- parallelJob[T]{index: index, value: value, err: err}:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// work applies fn to jobs until there are none left
+func (p *ParallelIterator[T, U]) work(jobs <-chan parallelJob[T]) {
+	for job := range jobs {
+		result := parallelResult[U]{index: job.index, err: job.err}
+		if job.err == nil {
+			if err := p.ctx.Err(); err != nil {
+				result.err = err
+			} else {
+				result.value, result.err = p.fn(p.ctx, job.value)
+			}
+		}
+		if result.err != nil && p.failFast {
+			p.halt()
+		}
+		p.results <- result
+	}
+}
+
+// halt stops reading upstream; elements already read are still processed
+func (p *ParallelIterator[T, U]) halt() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// receive waits for the next result to return, reporting false at the end.
+// The slot of a returned result is freed for the reader.
+func (p *ParallelIterator[T, U]) receive() (parallelResult[U], bool) {
+	for {
+		if p.ordered {
+			if result, ok := p.pending[p.next]; ok {
+				delete(p.pending, p.next)
+				p.next++
+				<-p.slots
+				return result, true
+			}
+		}
+		select {
+		case result, ok := <-p.results:
+			if !ok {
+				return result, false
+			}
+			if !p.ordered {
+				<-p.slots
+				return result, true
+			}
+			p.pending[result.index] = result
+		case <-p.ctx.Done():
+			return parallelResult[U]{err: p.ctx.Err()}, true
+		}
+	}
+}
+
+// HasNext checks if there are more results, waiting for the next one if
+// needed.
+func (p *ParallelIterator[T, U]) HasNext() bool {
+	if p.ready != nil || p.done {
+		return p.ready != nil
+	}
+	if !p.started {
+		p.start()
+	}
+	result, ok := p.receive()
+	if !ok {
+		p.Close()
+		return false
+	}
+	p.ready = &result
+	return true
+}
+
+// Next returns the next result.
+func (p *ParallelIterator[T, U]) Next() (U, error) {
+	if !p.HasNext() {
+		var zero U
+		return zero, ErrNoMoreElements
+	}
+	result := *p.ready
+	p.ready = nil
+	if result.err != nil && (p.failFast || p.ctx.Err() != nil) {
+		p.Close()
+	}
+	return result.value, result.err
+}
+
+// Close cancels the work in progress and waits for the goroutines to exit.
+// Upstream is not read once Close returns, but a call to upstream Next that
+// is already blocked must return first.
+func (p *ParallelIterator[T, U]) Close() error {
+	p.done = true
+	if !p.started {
+		return nil
+	}
+	p.cancel()
+	p.halt()
+	p.wg.Wait()
+	return nil
+}
@@ -0,0 +1,221 @@
+package stream
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingIterator counts the elements pulled from the wrapped iterator
+type countingIterator[T any] struct {
+	Iterator[T]
+	pulled atomic.Int64
+}
+
+func (it *countingIterator[T]) Next() (T, error) {
+	it.pulled.Add(1)
+	return it.Iterator.Next()
+}
+
+func rangeOf(n int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = i
+	}
+	return values
+}
+
+// jitter squares n after sleeping a random time, so results finish out of order
+func jitter(_ context.Context, n int) (int, error) {
+	time.Sleep(time.Duration(rand.IntN(200)) * time.Microsecond)
+	return n * n, nil
+}
+
+func TestParallelMapOrdered(t *testing.T) {
+	it := ParallelMap(context.Background(), Of(rangeOf(200)...).CreateIterator(), 8, jitter)
+	defer it.Close()
+	result, err := Collect[int](it)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected, _ := Collect(Map(Of(rangeOf(200)...).CreateIterator(), func(n int) int { return n * n }))
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected results in input order")
+	}
+}
+
+func TestParallelMapUnordered(t *testing.T) {
+	it := ParallelMap(context.Background(), Of(rangeOf(200)...).CreateIterator(), 8, jitter)
+	it.SetOrdered(false)
+	result, err := Collect[int](it)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	slices.Sort(result)
+	if len(result) != 200 || result[199] != 199*199 {
+		t.Errorf("Expected all 200 results, got %d", len(result))
+	}
+}
+
+func TestParallelMapBackpressure(t *testing.T) {
+	source, _ := naturals()
+	upstream := &countingIterator[int]{Iterator: source}
+	it := ParallelMap(context.Background(), Iterator[int](upstream), 4, func(_ context.Context, n int) (int, error) {
+		return n, nil
+	})
+	it.SetBuffer(6)
+	defer it.Close()
+
+	for i := 0; i < 3; i++ {
+		if v, err := it.Next(); v != i || err != nil {
+			t.Fatalf("Expected %d, got %d, %v", i, v, err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	// Three results were returned, so at most 3 + 6 elements may be read
+	if pulled := upstream.pulled.Load(); pulled > 9 {
+		t.Errorf("Expected at most 9 elements read from an endless upstream, got %d", pulled)
+	}
+}
+
+func TestParallelMapErrors(t *testing.T) {
+	boom := errors.New("boom")
+	failOnFive := func(_ context.Context, n int) (int, error) {
+		if n == 5 {
+			return 0, boom
+		}
+		return n, nil
+	}
+
+	t.Run("Continue", func(t *testing.T) {
+		it := ParallelMap(context.Background(), Of(rangeOf(10)...).CreateIterator(), 3, failOnFive)
+		var values []int
+		var errs []error
+		for it.HasNext() {
+			v, err := it.Next()
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			values = append(values, v)
+		}
+		if len(values) != 9 || len(errs) != 1 || errs[0] != boom {
+			t.Errorf("Expected 9 values and boom, got %v and %v", values, errs)
+		}
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		source, _ := naturals()
+		upstream := &countingIterator[int]{Iterator: source}
+		it := ParallelMap(context.Background(), Iterator[int](upstream), 3, failOnFive)
+		it.SetFailFast(true)
+		values, err := Collect[int](it)
+		if err != boom {
+			t.Fatalf("Expected boom, got %v", err)
+		}
+		if !reflect.DeepEqual(values, []int{0, 1, 2, 3, 4}) {
+			t.Errorf("Expected the results before the error, got %v", values)
+		}
+		if pulled := upstream.pulled.Load(); pulled > 5+6 {
+			t.Errorf("Expected upstream reading to stop, got %d elements read", pulled)
+		}
+		if it.HasNext() {
+			t.Error("Expected the iteration to end after a fail-fast error")
+		}
+	})
+
+	t.Run("Upstream", func(t *testing.T) {
+		upstream := Chain[int](ints(1, 2), &failingIterator{err: boom}, ints(3))
+		it := ParallelMap(context.Background(), upstream, 2, func(_ context.Context, n int) (int, error) {
+			return n * 10, nil
+		})
+		it.SetFailFast(true)
+		values, err := Collect[int](it)
+		if err != boom || !reflect.DeepEqual(values, []int{10, 20}) {
+			t.Errorf("Expected [10 20] then boom, got %v, %v", values, err)
+		}
+	})
+}
+
+func TestParallelMapCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source, _ := naturals()
+	started := make(chan struct{}, 100)
+	it := ParallelMap(ctx, source, 4, func(ctx context.Context, n int) (int, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err := it.Next()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if it.HasNext() {
+		t.Error("Expected the iteration to end after cancellation")
+	}
+}
+
+func TestParallelMapCloseStopsGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		source, _ := naturals()
+		it := ParallelMap(context.Background(), source, 4, jitter)
+		it.Next()
+		it.Close()
+	}
+	time.Sleep(10 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected goroutines to exit on Close, %d before and %d after", before, after)
+	}
+}
+
+// hashRounds is a CPU heavy transform
+func hashRounds(_ context.Context, n int) ([32]byte, error) {
+	sum := sha256.Sum256([]byte(fmt.Sprint(n)))
+	for i := 0; i < 2000; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return sum, nil
+}
+
+// Run with -cpu 1,2,4,8 to see ParallelMap scale with GOMAXPROCS
+func BenchmarkParallelMap(b *testing.B) {
+	chunks := rangeOf(256)
+
+	b.Run("Sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			it := Map(Of(chunks...).CreateIterator(), func(n int) [32]byte {
+				sum, _ := hashRounds(context.Background(), n)
+				return sum
+			})
+			if _, err := Count(it); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, ordered := range []bool{true, false} {
+		b.Run(fmt.Sprintf("Ordered=%t", ordered), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				it := ParallelMap(context.Background(), Of(chunks...).CreateIterator(), runtime.GOMAXPROCS(0), hashRounds)
+				it.SetOrdered(ordered)
+				if _, err := Count[[32]byte](it); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package stream
+
+import "iter"
+
+// Seq adapts an Iterator to an iter.Seq for use with for range. Iteration
+// ends at the first error; use Seq2 to observe it.
+func Seq[T any](it Iterator[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for it.HasNext() {
+			value, err := it.Next()
+			if err != nil || !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// Seq2 adapts an Iterator to an iter.Seq2 that yields each element with a
+// nil error. An error from Next is yielded once, with the zero value, and
+// ends the iteration.
+func Seq2[T any](it Iterator[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for it.HasNext() {
+			value, err := it.Next()
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(value, nil) {
+				return
+			}
+		}
+	}
+}
+
+// SeqIterator is an Iterator pulling its elements from an iter.Seq or
+// iter.Seq2. Stop must be called when it is abandoned before the end.
+type SeqIterator[T any] struct {
+	next    func() (T, error, bool)
+	stop    func()
+	value   T
+	err     error
+	pending bool
+	done    bool
+}
+
+// FromSeq creates an Iterator over the values of an iter.Seq.
+func FromSeq[T any](seq iter.Seq[T]) *SeqIterator[T] {
+	return FromSeq2(func(yield func(T, error) bool) {
+		for value := range seq {
+			if !yield(value, nil) {
+				return
+			}
+		}
+	})
+}
+
+// FromSeq2 creates an Iterator over an iter.Seq2 of values and errors. Each
+// non-nil error is returned by the corresponding call to Next.
+func FromSeq2[T any](seq iter.Seq2[T, error]) *SeqIterator[T] {
+	next, stop := iter.Pull2(seq)
+	return &SeqIterator[T]{next: next, stop: stop}
+}
+
+// HasNext checks if there are more elements to iterate, pulling the next one
+// from the sequence if needed.
+func (it *SeqIterator[T]) HasNext() bool {
+	if it.pending || it.done {
+		return it.pending
+	}
+	value, err, ok := it.next()
+	if !ok {
+		it.Stop()
+		return false
+	}
+	it.value, it.err, it.pending = value, err, true
+	return true
+}
+
+// Next returns the next element of the sequence.
+func (it *SeqIterator[T]) Next() (T, error) {
+	var zero T
+	if !it.HasNext() {
+		return zero, ErrNoMoreElements
+	}
+	value, err := it.value, it.err
+	it.value, it.err, it.pending = zero, nil, false
+	return value, err
+}
+
+// Stop ends the iteration and releases the sequence.
+func (it *SeqIterator[T]) Stop() {
+	var zero T
+	it.value, it.err, it.pending = zero, nil, false
+	it.done = true
+	it.stop()
+}
@@ -0,0 +1,440 @@
+package stream
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"slices"
+)
+
+// mergeFanIn is the most runs merged at once; more runs are merged in
+// several passes so the number of open files stays bounded
+const mergeFanIn = 64
+
+// Encoder writes elements to a run file.
+type Encoder[T any] interface {
+	Encode(value T) error
+}
+
+// Decoder reads back the elements of a run file, returning io.EOF at the end.
+type Decoder[T any] interface {
+	Decode() (T, error)
+}
+
+// Codec creates the encoders and decoders of run files.
+type Codec[T any] interface {
+	NewEncoder(w io.Writer) Encoder[T]
+	NewDecoder(r io.Reader) Decoder[T]
+}
+
+// GobCodec returns a Codec using encoding/gob. Only exported fields of
+// structs are kept.
+func GobCodec[T any]() Codec[T] {
+	return gobCodec[T]{}
+}
+
+// JSONCodec returns a Codec using encoding/json, writing one value per line.
+func JSONCodec[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+type gobCodec[T any] struct{}
+
+func (gobCodec[T]) NewEncoder(w io.Writer) Encoder[T] {
+	return encoderFunc[T](gob.NewEncoder(w).Encode)
+}
+
+func (gobCodec[T]) NewDecoder(r io.Reader) Decoder[T] {
+	return decoderFunc[T](gob.NewDecoder(r).Decode)
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) NewEncoder(w io.Writer) Encoder[T] {
+	return encoderFunc[T](json.NewEncoder(w).Encode)
+}
+
+func (jsonCodec[T]) NewDecoder(r io.Reader) Decoder[T] {
+	return decoderFunc[T](json.NewDecoder(r).Decode)
+}
+
+// encoderFunc adapts the Encode method of encoding/gob and encoding/json
+type encoderFunc[T any] func(any) error
+
+func (f encoderFunc[T]) Encode(value T) error {
+	return f(value)
+}
+
+// decoderFunc adapts the Decode method of encoding/gob and encoding/json
+type decoderFunc[T any] func(any) error
+
+func (f decoderFunc[T]) Decode() (T, error) {
+	var value T
+	err := f(&value)
+	return value, err
+}
+
+// SortIterator is the Source returned by SortedBy. Its settings must be
+// changed before the first call to HasNext or Next.
+type SortIterator[T any] struct {
+	*pullIterator[T]
+	upstream Iterator[T]
+	less     func(a, b T) bool
+	budget   int
+	codec    Codec[T]
+	tempDir  string
+
+	started bool
+	failed  bool
+	dir     string // Directory of the run files, removed on Close
+	spills  int
+	memory  []T // Sorted elements when nothing was spilled
+	merger  *merger[T]
+}
+
+// SortedBy returns a Source over the elements of it in the order defined by
+// less. Equal elements keep their input order. At most memoryBudget elements
+// are held in memory: larger inputs are sorted in runs that are spilled to
+// temporary files and merged back as elements are requested. The temporary
+// files are removed once the iteration ends, fails or is closed. The whole
+// upstream is read on the first call to HasNext or Next.
+func SortedBy[T any](it Iterator[T], less func(a, b T) bool, memoryBudget int) *SortIterator[T] {
+	s := &SortIterator[T]{
+		upstream: it,
+		less:     less,
+		budget:   max(memoryBudget, 1),
+		codec:    GobCodec[T](),
+	}
+	s.pullIterator = newPullIterator(s.pull)
+	return s
+}
+
+// SetCodec sets how spilled runs are encoded. The default is GobCodec.
+func (s *SortIterator[T]) SetCodec(codec Codec[T]) {
+	s.codec = codec
+}
+
+// SetTempDir sets the directory for run files. The default is os.TempDir.
+func (s *SortIterator[T]) SetTempDir(dir string) {
+	s.tempDir = dir
+}
+
+// Spills returns the number of run files written so far, including those of
+// intermediate merge passes.
+func (s *SortIterator[T]) Spills() int {
+	return s.spills
+}
+
+// compare orders elements with less, for the slices and heap functions
+func (s *SortIterator[T]) compare(a, b T) int {
+	switch {
+	case s.less(a, b):
+		return -1
+	case s.less(b, a):
+		return 1
+	}
+	return 0
+}
+
+// pull returns the next sorted element
+func (s *SortIterator[T]) pull() (T, error, bool) {
+	var zero T
+	if s.failed {
+		return zero, nil, false
+	}
+	if !s.started {
+		s.started = true
+		if err := s.sortRuns(); err != nil {
+			s.failed = true
+			s.cleanup()
+			return zero, err, true
+		}
+	}
+
+	if s.merger == nil {
+		if len(s.memory) == 0 {
+			return zero, nil, false
+		}
+		value := s.memory[0]
+		s.memory = s.memory[1:]
+		return value, nil, true
+	}
+	value, err, ok := s.merger.next()
+	if err != nil {
+		s.failed = true
+		s.cleanup()
+		return zero, err, true
+	}
+	if !ok {
+		s.cleanup()
+	}
+	return value, nil, ok
+}
+
+// sortRuns reads upstream, spilling sorted runs whenever the budget is full,
+// and prepares the final merge
+func (s *SortIterator[T]) sortRuns() error {
+	var buffer []T
+	var runs []string
+	for s.upstream.HasNext() {
+		value, err := s.upstream.Next()
+		if err != nil {
+			return err
+		}
+		buffer = append(buffer, value)
+		if len(buffer) >= s.budget {
+			run, err := s.spill(buffer)
+			if err != nil {
+				return err
+			}
+			runs = append(runs, run)
+			buffer = buffer[:0]
+		}
+	}
+
+	slices.SortStableFunc(buffer, s.compare)
+	if len(runs) == 0 {
+		s.memory = buffer
+		return nil
+	}
+	if len(buffer) > 0 {
+		run, err := s.spill(buffer)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+	}
+
+	// Merge passes keep runs in input order, so ties still resolve stably
+	for len(runs) > mergeFanIn {
+		var merged []string
+		for i := 0; i < len(runs); i += mergeFanIn {
+			run, err := s.mergeRuns(runs[i:min(i+mergeFanIn, len(runs))])
+			if err != nil {
+				return err
+			}
+			merged = append(merged, run)
+		}
+		runs = merged
+	}
+
+	m, err := s.openMerger(runs)
+	if err != nil {
+		return err
+	}
+	s.merger = m
+	return nil
+}
+
+// createRun creates a new run file
+func (s *SortIterator[T]) createRun() (*os.File, error) {
+	if s.dir == "" {
+		dir, err := os.MkdirTemp(s.tempDir, "stream-sort-")
+		if err != nil {
+			return nil, err
+		}
+		s.dir = dir
+	}
+	s.spills++
+	return os.CreateTemp(s.dir, "run-*")
+}
+
+// writeRun writes the elements next returns to a new run file
+func (s *SortIterator[T]) writeRun(next func() (T, error, bool)) (string, error) {
+	file, err := s.createRun()
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(file)
+	enc := s.codec.NewEncoder(w)
+	for {
+		value, err, ok := next()
+		if err == nil && ok {
+			err = enc.Encode(value)
+		}
+		if err != nil {
+			file.Close()
+			return "", err
+		}
+		if !ok {
+			break
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// spill sorts the buffer and writes it as a run
+func (s *SortIterator[T]) spill(buffer []T) (string, error) {
+	slices.SortStableFunc(buffer, s.compare)
+	i := 0
+	return s.writeRun(func() (T, error, bool) {
+		if i == len(buffer) {
+			var zero T
+			return zero, nil, false
+		}
+		i++
+		return buffer[i-1], nil, true
+	})
+}
+
+// mergeRuns merges runs into a new run and removes them
+func (s *SortIterator[T]) mergeRuns(runs []string) (string, error) {
+	m, err := s.openMerger(runs)
+	if err != nil {
+		return "", err
+	}
+	defer m.close()
+	run, err := s.writeRun(m.next)
+	if err != nil {
+		return "", err
+	}
+	for _, path := range runs {
+		os.Remove(path)
+	}
+	return run, nil
+}
+
+// openMerger opens runs for a k-way merge
+func (s *SortIterator[T]) openMerger(runs []string) (*merger[T], error) {
+	m := &merger[T]{compare: s.compare}
+	for i, path := range runs {
+		file, err := os.Open(path)
+		if err != nil {
+			m.close()
+			return nil, err
+		}
+		c := &runCursor[T]{run: i, file: file, dec: s.codec.NewDecoder(bufio.NewReader(file))}
+		m.files = append(m.files, file)
+		if err := c.advance(); err != nil {
+			m.close()
+			return nil, err
+		}
+		if c.ok {
+			m.cursors = append(m.cursors, c)
+		}
+	}
+	heap.Init(m)
+	return m, nil
+}
+
+// cleanup closes and removes every run file
+func (s *SortIterator[T]) cleanup() {
+	if s.merger != nil {
+		s.merger.close()
+		s.merger = nil
+	}
+	s.memory = nil
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+		s.dir = ""
+	}
+}
+
+// Close ends the iteration and removes the temporary files.
+func (s *SortIterator[T]) Close() error {
+	var zero T
+	s.done, s.pending, s.value, s.err = true, false, zero, nil
+	s.failed = true
+	s.cleanup()
+	return nil
+}
+
+// runCursor is the position of the merge in one run
+type runCursor[T any] struct {
+	run   int
+	file  *os.File
+	dec   Decoder[T]
+	value T
+	ok    bool
+}
+
+// advance reads the next element of the run
+func (c *runCursor[T]) advance() error {
+	value, err := c.dec.Decode()
+	if errors.Is(err, io.EOF) {
+		c.ok = false
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.value, c.ok = value, true
+	return nil
+}
+
+// merger is a min-heap of run cursors, ordered by their current element and
+// then by run, which keeps the merge stable
+type merger[T any] struct {
+	compare func(a, b T) int
+	cursors []*runCursor[T]
+	files   []*os.File
+}
+
+func (m *merger[T]) Len() int { return len(m.cursors) }
+
+func (m *merger[T]) Less(i, j int) bool {
+	if c := m.compare(m.cursors[i].value, m.cursors[j].value); c != 0 {
+		return c < 0
+	}
+	return m.cursors[i].run < m.cursors[j].run
+}
+
+func (m *merger[T]) Swap(i, j int) { m.cursors[i], m.cursors[j] = m.cursors[j], m.cursors[i] }
+
+func (m *merger[T]) Push(x any) { m.cursors = append(m.cursors, x.(*runCursor[T])) }
+
+func (m *merger[T]) Pop() any {
+	last := m.cursors[len(m.cursors)-1]
+	m.cursors = m.cursors[:len(m.cursors)-1]
+	return last
+}
+
+// next returns the smallest element of all runs
+func (m *merger[T]) next() (T, error, bool) {
+	var zero T
+	if len(m.cursors) == 0 {
+		return zero, nil, false
+	}
+	c := m.cursors[0]
+	value := c.value
+	if err := c.advance(); err != nil {
+		return zero, err, true
+	}
+	if c.ok {
+		heap.Fix(m, 0)
+	} else {
+		heap.Pop(m)
+		c.file.Close()
+	}
+	return value, nil, true
+}
+
+// close closes the run files
+func (m *merger[T]) close() {
+	for _, file := range m.files {
+		file.Close()
+	}
+	m.cursors = nil
+}
+
+// Compact returns an Iterator dropping elements equal to the one before, which
+// deduplicates a sorted stream without remembering every element.
+func Compact[T any](it Iterator[T], equal func(a, b T) bool) Iterator[T] {
+	var last T
+	seen := false
+	return Filter(it, func(value T) bool {
+		if seen && equal(last, value) {
+			return false
+		}
+		last, seen = value, true
+		return true
+	})
+}
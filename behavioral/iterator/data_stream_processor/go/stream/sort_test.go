@@ -0,0 +1,128 @@
+package stream
+
+import (
+	"errors"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"testing"
+)
+
+// record is a sortable element carrying its input position
+type record struct {
+	Key int
+	Seq int
+}
+
+func randomRecords(n, keys int) []record {
+	rng := rand.New(rand.NewPCG(1, 2))
+	records := make([]record, n)
+	for i := range records {
+		records[i] = record{Key: rng.IntN(keys), Seq: i}
+	}
+	return records
+}
+
+func byKey(a, b record) bool { return a.Key < b.Key }
+
+// assertEmptyDir fails when dir still holds files
+func assertEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected temporary files to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestSortedBy(t *testing.T) {
+	input := randomRecords(10000, 500)
+	expected := slices.Clone(input)
+	slices.SortStableFunc(expected, func(a, b record) int { return a.Key - b.Key })
+
+	codecs := map[string]Codec[record]{"Gob": GobCodec[record](), "JSON": JSONCodec[record]()}
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			sorted := SortedBy(Of(input...).CreateIterator(), byKey, 100)
+			sorted.SetCodec(codec)
+			sorted.SetTempDir(dir)
+
+			result, err := Collect[record](sorted)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !slices.Equal(result, expected) {
+				t.Errorf("Expected a stable sort of %d records", len(expected))
+			}
+			// 100 runs, then 2 merged runs of at most 64 from an extra pass
+			if sorted.Spills() != 102 {
+				t.Errorf("Expected 102 spill files, got %d", sorted.Spills())
+			}
+			assertEmptyDir(t, dir)
+		})
+	}
+}
+
+func TestSortedByInMemory(t *testing.T) {
+	dir := t.TempDir()
+	sorted := SortedBy(ints(3, 1, 2), func(a, b int) bool { return a < b }, 10)
+	sorted.SetTempDir(dir)
+	result, _ := Collect[int](sorted)
+	if !slices.Equal(result, []int{1, 2, 3}) || sorted.Spills() != 0 {
+		t.Errorf("Expected [1 2 3] without spilling, got %v after %d spills", result, sorted.Spills())
+	}
+
+	empty, _ := Collect[int](SortedBy(ints(), func(a, b int) bool { return a < b }, 10))
+	if len(empty) != 0 {
+		t.Errorf("Expected an empty result, got %v", empty)
+	}
+}
+
+func TestSortedByCleanup(t *testing.T) {
+	t.Run("Close", func(t *testing.T) {
+		dir := t.TempDir()
+		sorted := SortedBy(Of(randomRecords(1000, 50)...).CreateIterator(), byKey, 10)
+		sorted.SetTempDir(dir)
+		if _, err := sorted.Next(); err != nil {
+			t.Fatal(err)
+		}
+		sorted.Close()
+		assertEmptyDir(t, dir)
+		if sorted.HasNext() {
+			t.Error("Expected the iteration to end after Close")
+		}
+	})
+
+	t.Run("UpstreamError", func(t *testing.T) {
+		dir := t.TempDir()
+		boom := errors.New("boom")
+		upstream := Chain[int](ints(5, 4, 3, 2, 1), &failingIterator{err: boom})
+		sorted := SortedBy(upstream, func(a, b int) bool { return a < b }, 2)
+		sorted.SetTempDir(dir)
+		if _, err := Collect[int](sorted); err != boom {
+			t.Errorf("Expected boom, got %v", err)
+		}
+		if sorted.Spills() == 0 {
+			t.Error("Expected runs to be spilled before the error")
+		}
+		assertEmptyDir(t, dir)
+	})
+}
+
+func TestSortAndDeduplicate(t *testing.T) {
+	dir := t.TempDir()
+	sorted := SortedBy(Map(Of(randomRecords(5000, 300)...).CreateIterator(), func(r record) int { return r.Key }),
+		func(a, b int) bool { return a < b }, 64)
+	sorted.SetTempDir(dir)
+	unique, err := Collect(Compact[int](sorted, func(a, b int) bool { return a == b }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unique) != 300 || !slices.IsSorted(unique) {
+		t.Errorf("Expected 300 sorted distinct keys, got %d", len(unique))
+	}
+	assertEmptyDir(t, dir)
+}
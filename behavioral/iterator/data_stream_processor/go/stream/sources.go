@@ -0,0 +1,236 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// MaxTokenSize is the longest line or token a scanning source accepts. Longer
+// ones make Next return bufio.ErrTooLong.
+const MaxTokenSize = 16 * 1024 * 1024
+
+// Source is an Iterator reading lazily from an underlying resource. Close
+// releases the resource; it may be called at any time and more than once.
+type Source[T any] interface {
+	Iterator[T]
+	io.Closer
+}
+
+// LineError reports a record that could not be decoded. Iteration continues
+// with the next record.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// readerSource is a Source producing elements with a pull function. Closing
+// it closes the reader when the reader is an io.Closer.
+type readerSource[T any] struct {
+	*pullIterator[T]
+	closer func() error
+	closed bool
+}
+
+// newReaderSource creates a Source over r whose elements are produced by pull.
+func newReaderSource[T any](r io.Reader, pull func() (T, error, bool)) *readerSource[T] {
+	s := &readerSource[T]{pullIterator: newPullIterator(pull)}
+	if c, ok := r.(io.Closer); ok {
+		s.closer = c.Close
+	}
+	return s
+}
+
+// Close ends the iteration and closes the underlying reader.
+func (s *readerSource[T]) Close() error {
+	if s.closed {
+		return nil
+	}
+	var zero T
+	s.closed, s.done = true, true
+	s.value, s.err, s.pending = zero, nil, false
+	if s.closer != nil {
+		return s.closer()
+	}
+	return nil
+}
+
+// terminal wraps a pull function so that it ends after the first error
+func terminal[T any](pull func() (T, error, bool)) func() (T, error, bool) {
+	failed := false
+	return func() (T, error, bool) {
+		if failed {
+			var zero T
+			return zero, nil, false
+		}
+		value, err, ok := pull()
+		failed = err != nil
+		return value, err, ok
+	}
+}
+
+// newScanner creates a Scanner accepting tokens up to MaxTokenSize
+func newScanner(r io.Reader, split bufio.SplitFunc) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxTokenSize)
+	scanner.Split(split)
+	return scanner
+}
+
+// Scan creates a Source over the tokens a bufio.SplitFunc finds in r, such as
+// bufio.ScanWords or bufio.ScanRunes.
+func Scan(r io.Reader, split bufio.SplitFunc) Source[string] {
+	scanner := newScanner(r, split)
+	return newReaderSource(r, terminal(func() (string, error, bool) {
+		if scanner.Scan() {
+			return scanner.Text(), nil, true
+		}
+		if err := scanner.Err(); err != nil {
+			return "", err, true
+		}
+		return "", nil, false
+	}))
+}
+
+// Lines creates a Source over the lines of r, without line endings.
+func Lines(r io.Reader) Source[string] {
+	return Scan(r, bufio.ScanLines)
+}
+
+// Chunks creates a Source over consecutive byte chunks of r. Every chunk has
+// the given size except possibly the last one.
+func Chunks(r io.Reader, size int) Source[[]byte] {
+	if size < 1 {
+		size = 1
+	}
+	var pending error
+	return newReaderSource(r, terminal(func() ([]byte, error, bool) {
+		if pending != nil {
+			return nil, pending, true
+		}
+		chunk := make([]byte, size)
+		n, err := io.ReadFull(r, chunk)
+		switch {
+		case err == io.EOF:
+			return nil, nil, false
+		case err == io.ErrUnexpectedEOF:
+			err = nil
+		case err != nil && n > 0:
+			// Hand out the bytes that were read before reporting the error
+			pending, err = err, nil
+		}
+		if err != nil {
+			return nil, err, true
+		}
+		return chunk[:n], nil, true
+	}))
+}
+
+// CSVRows creates a Source over the rows of CSV data. Rows with a parse error
+// return a *csv.ParseError, after which iteration continues.
+func CSVRows(r io.Reader) Source[[]string] {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	failed := false
+	return newReaderSource(r, func() ([]string, error, bool) {
+		if failed {
+			return nil, nil, false
+		}
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil, false
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			failed = true
+		}
+		return row, err, true
+	})
+}
+
+// JSONLines creates a Source decoding every non-empty line of r into a T.
+// Lines that can't be decoded return a *LineError, after which iteration
+// continues.
+func JSONLines[T any](r io.Reader) Source[T] {
+	scanner := newScanner(r, bufio.ScanLines)
+	line := 0
+	failed := false
+	return newReaderSource(r, func() (T, error, bool) {
+		var record T
+		for !failed && scanner.Scan() {
+			line++
+			data := scanner.Bytes()
+			if len(data) == 0 {
+				continue
+			}
+			if err := json.Unmarshal(data, &record); err != nil {
+				var zero T
+				return zero, &LineError{Line: line, Err: err}, true
+			}
+			return record, nil, true
+		}
+		if err := scanner.Err(); err != nil && !failed {
+			failed = true
+			return record, err, true
+		}
+		return record, nil, false
+	})
+}
+
+// OpenFile opens the named file and creates a Source over it with open, such
+// as Lines or JSONLines[T]. Closing the Source closes the file.
+func OpenFile[T any](name string, open func(io.Reader) Source[T]) (Source[T], error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return open(file), nil
+}
+
+// walkSource is a Source over the files of a directory tree
+type walkSource struct {
+	*SeqIterator[string]
+}
+
+// Close stops the walk.
+func (s walkSource) Close() error {
+	s.Stop()
+	return nil
+}
+
+// WalkFiles creates a Source over the paths of the regular files under root
+// in fsys, in lexical order. The tree is walked as paths are requested. A
+// directory that can't be read returns its error and is skipped. Use
+// os.DirFS to walk the local file system.
+func WalkFiles(fsys fs.FS, root string) Source[string] {
+	return walkSource{FromSeq2(func(yield func(string, error) bool) {
+		fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if !yield("", err) {
+					return fs.SkipAll
+				}
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() && !yield(path, nil) {
+				return fs.SkipAll
+			}
+			return nil
+		})
+	})}
+}
@@ -0,0 +1,198 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"testing/iotest"
+)
+
+// trackingReader records whether it was closed
+type trackingReader struct {
+	io.Reader
+	closed int
+}
+
+func (r *trackingReader) Close() error {
+	r.closed++
+	return nil
+}
+
+func TestLines(t *testing.T) {
+	lines, err := Collect(Lines(strings.NewReader("first\r\nsecond\n\nlast")))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(lines, []string{"first", "second", "", "last"}) {
+		t.Errorf("Unexpected lines %q", lines)
+	}
+
+	long := strings.Repeat("x", MaxTokenSize+1)
+	if _, err := Collect(Lines(strings.NewReader(long))); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("Expected bufio.ErrTooLong for an oversized line, got %v", err)
+	}
+}
+
+func TestScanWords(t *testing.T) {
+	words, _ := Collect(Scan(strings.NewReader("  the quick\tbrown\nfox "), bufio.ScanWords))
+	if !reflect.DeepEqual(words, []string{"the", "quick", "brown", "fox"}) {
+		t.Errorf("Unexpected words %q", words)
+	}
+}
+
+func TestChunks(t *testing.T) {
+	chunks, _ := Collect(Chunks(iotest.OneByteReader(strings.NewReader("abcdefgh")), 3))
+	if !reflect.DeepEqual(chunks, [][]byte{[]byte("abc"), []byte("def"), []byte("gh")}) {
+		t.Errorf("Unexpected chunks %q", chunks)
+	}
+
+	boom := errors.New("disk failure")
+	source := Chunks(io.MultiReader(strings.NewReader("abcd"), iotest.ErrReader(boom)), 3)
+	chunks, err := Collect(source)
+	if err != boom {
+		t.Fatalf("Expected the read error from Next, got %v", err)
+	}
+	if !reflect.DeepEqual(chunks, [][]byte{[]byte("abc"), []byte("d")}) {
+		t.Errorf("Expected the bytes before the error, got %q", chunks)
+	}
+	if source.HasNext() {
+		t.Error("Expected the source to end after a read error")
+	}
+}
+
+func TestCSVRows(t *testing.T) {
+	input := "name,age\nJohn,34\n\"broken,1\nSarah,29\n"
+	source := CSVRows(strings.NewReader(input))
+	var rows [][]string
+	var parseErrs int
+	for source.HasNext() {
+		row, err := source.Next()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			parseErrs++
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if parseErrs != 1 {
+		t.Errorf("Expected one parse error, got %d", parseErrs)
+	}
+	if len(rows) != 2 || rows[1][0] != "John" {
+		t.Errorf("Unexpected rows %q", rows)
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	type event struct {
+		Level    string `json:"level"`
+		Duration int    `json:"duration"`
+	}
+	input := `{"level":"error","duration":250}
+
+{"level":"info","duration":
+{"level":"error","duration":900}
+`
+	source := JSONLines[event](strings.NewReader(input))
+	var events []event
+	var lineErr *LineError
+	for source.HasNext() {
+		value, err := source.Next()
+		if errors.As(err, &lineErr) {
+			continue
+		}
+		events = append(events, value)
+	}
+	if lineErr == nil || lineErr.Line != 3 {
+		t.Errorf("Expected a decoding error on line 3, got %v", lineErr)
+	}
+	expected := []event{{"error", 250}, {"error", 900}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+
+	records, _ := Collect(JSONLines[map[string]any](strings.NewReader("{\"a\":1}\n{\"b\":2}\n")))
+	if len(records) != 2 || len(records[1]) != 1 {
+		t.Errorf("Expected every record to be decoded into a fresh map, got %v", records)
+	}
+}
+
+func TestSourceClose(t *testing.T) {
+	reader := &trackingReader{Reader: strings.NewReader("a\nb\nc\n")}
+	source := Lines(reader)
+	if first, _ := source.Next(); first != "a" {
+		t.Fatalf("Expected a, got %q", first)
+	}
+	if err := source.Close(); err != nil {
+		t.Fatalf("Unexpected error closing: %v", err)
+	}
+	source.Close()
+	if reader.closed != 1 {
+		t.Errorf("Expected the reader to be closed once, got %d", reader.closed)
+	}
+	if source.HasNext() {
+		t.Error("Expected HasNext to be false after Close")
+	}
+	if _, err := source.Next(); !errors.Is(err, ErrNoMoreElements) {
+		t.Errorf("Expected ErrNoMoreElements after Close, got %v", err)
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("INFO start\nERROR disk\nINFO stop\nERROR net\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := OpenFile(path, Lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	errorsOnly := Filter[string](source, func(line string) bool { return strings.HasPrefix(line, "ERROR") })
+	first, _, _ := First(errorsOnly)
+	if first != "ERROR disk" {
+		t.Errorf("Expected the first error line, got %q", first)
+	}
+
+	if _, err := OpenFile(filepath.Join(t.TempDir(), "missing"), Lines); err == nil {
+		t.Error("Expected an error opening a missing file")
+	}
+}
+
+func TestWalkFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"logs/b.log":         {Data: []byte("b")},
+		"logs/a.log":         {Data: []byte("a")},
+		"logs/archive/c.log": {Data: []byte("c")},
+		"logs/empty":         {Mode: os.ModeDir},
+		"other.txt":          {Data: []byte("x")},
+	}
+	files, err := Collect(WalkFiles(fsys, "logs"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"logs/a.log", "logs/archive/c.log", "logs/b.log"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+
+	walk := WalkFiles(fsys, ".")
+	if first, _ := walk.Next(); first != "logs/a.log" {
+		t.Errorf("Expected logs/a.log first, got %q", first)
+	}
+	walk.Close()
+	if walk.HasNext() {
+		t.Error("Expected the walk to stop after Close")
+	}
+
+	if _, err := Collect(WalkFiles(fsys, "missing")); err == nil {
+		t.Error("Expected an error walking a missing directory")
+	}
+}
@@ -0,0 +1,214 @@
+// Package stream provides type-safe iterators over streams of data chunks.
+package stream
+
+import (
+	"errors"
+	"iter"
+	"sync"
+)
+
+var (
+	// ErrNoMoreElements is returned by Next once an iterator is exhausted.
+	ErrNoMoreElements = errors.New("no more elements")
+	// ErrIndexOutOfBounds is returned by Get for an index outside the stream.
+	ErrIndexOutOfBounds = errors.New("index out of bounds")
+	// ErrConcurrentModification is returned by a fail-fast iterator once
+	// its stream was modified after the iterator was created.
+	ErrConcurrentModification = errors.New("stream modified during iteration")
+)
+
+// IterationMode selects how iterators react to chunks added while they walk.
+type IterationMode int
+
+const (
+	// FailFast iterators fail with ErrConcurrentModification once the stream
+	// is modified.
+	FailFast IterationMode = iota
+	// Snapshot iterators walk the chunks present when they were created and
+	// never see later modifications.
+	Snapshot
+)
+
+// Iterator defines methods for traversing elements of type T.
+type Iterator[T any] interface {
+	HasNext() bool
+	Next() (T, error)
+}
+
+// Aggregate defines a method for creating an iterator.
+type Aggregate[T any] interface {
+	CreateIterator() Iterator[T]
+}
+
+// DataStream holds the collection of data chunks. It is safe for concurrent
+// use.
+type DataStream[T any] struct {
+	mu         sync.RWMutex
+	dataChunks []T
+	modCount   int // Incremented by every modification
+	mode       IterationMode
+}
+
+// NewDataStream creates a new, empty DataStream.
+func NewDataStream[T any]() *DataStream[T] {
+	return &DataStream[T]{
+		dataChunks: make([]T, 0),
+	}
+}
+
+// Of creates a DataStream holding the given chunks.
+func Of[T any](chunks ...T) *DataStream[T] {
+	ds := NewDataStream[T]()
+	ds.dataChunks = append(ds.dataChunks, chunks...)
+	return ds
+}
+
+// SetIterationMode selects the kind of iterator CreateIterator returns. The
+// default is FailFast.
+func (ds *DataStream[T]) SetIterationMode(mode IterationMode) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.mode = mode
+}
+
+// AddChunk adds a data chunk to the stream.
+func (ds *DataStream[T]) AddChunk(chunk T) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.dataChunks = append(ds.dataChunks, chunk)
+	ds.modCount++
+}
+
+// Get retrieves a chunk by its index.
+func (ds *DataStream[T]) Get(index int) (T, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	if index < 0 || index >= len(ds.dataChunks) {
+		var zero T
+		return zero, ErrIndexOutOfBounds
+	}
+	return ds.dataChunks[index], nil
+}
+
+// GetCount returns the number of chunks in the stream.
+func (ds *DataStream[T]) GetCount() int {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return len(ds.dataChunks)
+}
+
+// snapshot returns the current chunks and modification count. Chunks are
+// only ever appended, so the snapshot shares the backing array: its capacity
+// is capped, which makes any append to it copy, and appends to the stream
+// write past its end.
+func (ds *DataStream[T]) snapshot() ([]T, int) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.dataChunks[:len(ds.dataChunks):len(ds.dataChunks)], ds.modCount
+}
+
+// CreateIterator creates an iterator for the DataStream in the mode set by
+// SetIterationMode.
+func (ds *DataStream[T]) CreateIterator() Iterator[T] {
+	ds.mu.RLock()
+	mode := ds.mode
+	ds.mu.RUnlock()
+	if mode == Snapshot {
+		return ds.SnapshotIterator()
+	}
+	return ds.FailFastIterator()
+}
+
+// FailFastIterator creates an iterator failing with
+// ErrConcurrentModification once the stream is modified.
+func (ds *DataStream[T]) FailFastIterator() *StreamIterator[T] {
+	_, modCount := ds.snapshot()
+	return &StreamIterator[T]{
+		stream:   ds,
+		position: 0,
+		modCount: modCount,
+	}
+}
+
+// SnapshotIterator creates an iterator over the chunks currently in the
+// stream, unaffected by later modifications.
+func (ds *DataStream[T]) SnapshotIterator() *StreamIterator[T] {
+	chunks, _ := ds.snapshot()
+	return &StreamIterator[T]{
+		snapshot: chunks,
+		position: 0,
+	}
+}
+
+// All returns an iter.Seq2 over the index and value of every chunk, walking
+// a snapshot of the stream.
+func (ds *DataStream[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		chunks, _ := ds.snapshot()
+		for i, chunk := range chunks {
+			if !yield(i, chunk) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iter.Seq over the chunks of the stream, walking a
+// snapshot of the stream.
+func (ds *DataStream[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		chunks, _ := ds.snapshot()
+		for _, chunk := range chunks {
+			if !yield(chunk) {
+				return
+			}
+		}
+	}
+}
+
+// StreamIterator implements the Iterator interface for DataStream. It walks
+// either the live stream, failing fast, or a snapshot of it. A
+// StreamIterator itself must not be shared between goroutines.
+type StreamIterator[T any] struct {
+	stream   *DataStream[T] // Live stream of a fail-fast iterator
+	snapshot []T            // Chunks of a snapshot iterator
+	position int
+	modCount int  // Modification count the live stream must still have
+	failed   bool // Set once a modification was reported
+}
+
+// HasNext checks if there are more elements to iterate. It is false once a
+// fail-fast iterator has reported a modification.
+func (it *StreamIterator[T]) HasNext() bool {
+	if it.failed {
+		return false
+	}
+	if it.stream == nil {
+		return it.position < len(it.snapshot)
+	}
+	return it.position < it.stream.GetCount()
+}
+
+// Next returns the next element in the stream.
+func (it *StreamIterator[T]) Next() (T, error) {
+	var zero T
+	if it.stream == nil {
+		if it.position >= len(it.snapshot) {
+			return zero, ErrNoMoreElements
+		}
+		it.position++
+		return it.snapshot[it.position-1], nil
+	}
+
+	it.stream.mu.RLock()
+	defer it.stream.mu.RUnlock()
+	if it.failed || it.stream.modCount != it.modCount {
+		it.failed = true
+		return zero, ErrConcurrentModification
+	}
+	if it.position >= len(it.stream.dataChunks) {
+		return zero, ErrNoMoreElements
+	}
+	it.position++
+	return it.stream.dataChunks[it.position-1], nil
+}
@@ -0,0 +1,161 @@
+package stream
+
+import (
+	"errors"
+	"iter"
+	"reflect"
+	"testing"
+)
+
+func TestTypedIteration(t *testing.T) {
+	ds := NewDataStream[string]()
+	ds.AddChunk("a")
+	ds.AddChunk("b")
+
+	var it Iterator[string] = ds.CreateIterator()
+	var collected []string
+	for it.HasNext() {
+		chunk, err := it.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error during iteration: %v", err)
+		}
+		collected = append(collected, chunk)
+	}
+	if !reflect.DeepEqual(collected, []string{"a", "b"}) {
+		t.Errorf("Expected [a b], got %v", collected)
+	}
+	if _, err := it.Next(); !errors.Is(err, ErrNoMoreElements) {
+		t.Errorf("Expected ErrNoMoreElements after iteration, got %v", err)
+	}
+	if _, err := ds.Get(2); !errors.Is(err, ErrIndexOutOfBounds) {
+		t.Errorf("Expected ErrIndexOutOfBounds, got %v", err)
+	}
+}
+
+func TestRangeOverStream(t *testing.T) {
+	ds := Of(10, 20, 30)
+
+	sum := 0
+	for v := range ds.Values() {
+		sum += v
+	}
+	if sum != 60 {
+		t.Errorf("Expected sum 60, got %d", sum)
+	}
+
+	for i, v := range ds.All() {
+		if v != (i+1)*10 {
+			t.Errorf("Expected %d at index %d, got %d", (i+1)*10, i, v)
+		}
+		if i == 1 {
+			break
+		}
+	}
+
+	var fromIterator []int
+	for v := range Seq(ds.CreateIterator()) {
+		fromIterator = append(fromIterator, v)
+	}
+	if !reflect.DeepEqual(fromIterator, []int{10, 20, 30}) {
+		t.Errorf("Expected [10 20 30], got %v", fromIterator)
+	}
+}
+
+// failingIterator returns its values, then an error
+type failingIterator struct {
+	values []int
+	err    error
+}
+
+func (it *failingIterator) HasNext() bool { return len(it.values) > 0 || it.err != nil }
+
+func (it *failingIterator) Next() (int, error) {
+	if len(it.values) > 0 {
+		v := it.values[0]
+		it.values = it.values[1:]
+		return v, nil
+	}
+	err := it.err
+	it.err = nil
+	return 0, err
+}
+
+func TestSeq2PropagatesErrors(t *testing.T) {
+	boom := errors.New("boom")
+	var values []int
+	var got error
+	for v, err := range Seq2[int](&failingIterator{values: []int{1, 2}, err: boom}) {
+		if err != nil {
+			got = err
+			continue
+		}
+		values = append(values, v)
+	}
+	if !reflect.DeepEqual(values, []int{1, 2}) || got != boom {
+		t.Errorf("Expected [1 2] then boom, got %v and %v", values, got)
+	}
+}
+
+func TestFromSeq(t *testing.T) {
+	it := FromSeq(Of("x", "y").Values())
+	if !it.HasNext() || !it.HasNext() {
+		t.Fatal("Expected HasNext to be true and idempotent")
+	}
+	var collected []string
+	for it.HasNext() {
+		v, err := it.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		collected = append(collected, v)
+	}
+	if !reflect.DeepEqual(collected, []string{"x", "y"}) {
+		t.Errorf("Expected [x y], got %v", collected)
+	}
+	if _, err := it.Next(); !errors.Is(err, ErrNoMoreElements) {
+		t.Errorf("Expected ErrNoMoreElements, got %v", err)
+	}
+}
+
+func TestFromSeq2Errors(t *testing.T) {
+	boom := errors.New("boom")
+	var seq iter.Seq2[int, error] = func(yield func(int, error) bool) {
+		_ = yield(1, nil) && yield(0, boom) && yield(3, nil)
+	}
+	it := FromSeq2(seq)
+	var values []int
+	var errs []error
+	for it.HasNext() {
+		v, err := it.Next()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values = append(values, v)
+	}
+	if !reflect.DeepEqual(values, []int{1, 3}) || len(errs) != 1 || errs[0] != boom {
+		t.Errorf("Expected [1 3] and one error, got %v and %v", values, errs)
+	}
+}
+
+func TestStopReleasesSequence(t *testing.T) {
+	cleaned := false
+	it := FromSeq(func(yield func(int) bool) {
+		defer func() { cleaned = true }()
+		for i := 0; ; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	})
+	if v, _ := it.Next(); v != 0 {
+		t.Errorf("Expected 0, got %d", v)
+	}
+	it.Stop()
+	if !cleaned {
+		t.Error("Expected Stop to run the sequence's deferred cleanup")
+	}
+	if it.HasNext() {
+		t.Error("Expected HasNext to be false after Stop")
+	}
+}
@@ -0,0 +1,73 @@
+package stream
+
+// Terminal operations drain an Iterator and stop at the first error, returning
+// it along with what was gathered so far.
+
+// Reduce folds every element into an accumulator, starting from initial.
+func Reduce[T, U any](it Iterator[T], initial U, fn func(U, T) U) (U, error) {
+	acc := initial
+	for it.HasNext() {
+		value, err := it.Next()
+		if err != nil {
+			return acc, err
+		}
+		acc = fn(acc, value)
+	}
+	return acc, nil
+}
+
+// Collect returns the elements in a slice.
+func Collect[T any](it Iterator[T]) ([]T, error) {
+	return Reduce(it, []T{}, func(values []T, value T) []T {
+		return append(values, value)
+	})
+}
+
+// Count returns the number of elements.
+func Count[T any](it Iterator[T]) (int, error) {
+	return Reduce(it, 0, func(n int, _ T) int {
+		return n + 1
+	})
+}
+
+// First returns the first element, or false when there is none. Nothing past
+// the first element is pulled.
+func First[T any](it Iterator[T]) (T, bool, error) {
+	var zero T
+	if !it.HasNext() {
+		return zero, false, nil
+	}
+	value, err := it.Next()
+	if err != nil {
+		return zero, false, err
+	}
+	return value, true, nil
+}
+
+// GroupBy groups the elements by the key fn returns, keeping their order
+// within each group.
+func GroupBy[T any, K comparable](it Iterator[T], key func(T) K) (map[K][]T, error) {
+	return Reduce(it, make(map[K][]T), func(groups map[K][]T, value T) map[K][]T {
+		k := key(value)
+		groups[k] = append(groups[k], value)
+		return groups
+	})
+}
+
+// Partition splits the elements into those for which pred returns true and
+// the rest.
+func Partition[T any](it Iterator[T], pred func(T) bool) ([]T, []T, error) {
+	matched, rest := []T{}, []T{}
+	for it.HasNext() {
+		value, err := it.Next()
+		if err != nil {
+			return matched, rest, err
+		}
+		if pred(value) {
+			matched = append(matched, value)
+		} else {
+			rest = append(rest, value)
+		}
+	}
+	return matched, rest, nil
+}
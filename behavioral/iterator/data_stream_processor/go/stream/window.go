@@ -0,0 +1,369 @@
+package stream
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// windowKind distinguishes the ways events are assigned to windows
+type windowKind int
+
+const (
+	tumblingWindow windowKind = iota
+	slidingWindow
+	sessionWindow
+)
+
+// WindowSpec describes how events are grouped into windows of event time.
+type WindowSpec struct {
+	kind  windowKind
+	size  time.Duration
+	slide time.Duration
+}
+
+// Tumbling returns back-to-back windows of the given size, aligned to the
+// Unix epoch. Every event belongs to exactly one window. It panics if size
+// is not positive.
+func Tumbling(size time.Duration) WindowSpec {
+	if size <= 0 {
+		panic(fmt.Sprintf("stream: Tumbling window size must be positive, got %v", size))
+	}
+	return WindowSpec{kind: tumblingWindow, size: size, slide: size}
+}
+
+// Sliding returns windows of the given size starting every slide. An event
+// belongs to every window covering it. It panics unless 0 < slide <= size.
+func Sliding(size, slide time.Duration) WindowSpec {
+	if slide <= 0 || slide > size {
+		panic(fmt.Sprintf("stream: Sliding window needs 0 < slide <= size, got size %v and slide %v", size, slide))
+	}
+	return WindowSpec{kind: slidingWindow, size: size, slide: slide}
+}
+
+// Session returns windows of activity per key, closed by a gap without
+// events. A window spans from its first event to its last event plus gap.
+// It panics if gap is not positive.
+func Session(gap time.Duration) WindowSpec {
+	if gap <= 0 {
+		panic(fmt.Sprintf("stream: Session window gap must be positive, got %v", gap))
+	}
+	return WindowSpec{kind: sessionWindow, size: gap}
+}
+
+// Window is the aggregate of the events of one key in one window.
+type Window[K comparable, A any] struct {
+	Key   K
+	Start time.Time
+	End   time.Time
+	Count int
+	Value A
+}
+
+// Summary aggregates a numeric value of events.
+type Summary struct {
+	Count int
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+// Mean returns the average value, or 0 for an empty summary.
+func (s Summary) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Summarize returns an aggregation function for Windowed adding the value of
+// every event to a Summary.
+func Summarize[T any](value func(T) float64) func(Summary, T) Summary {
+	return func(s Summary, event T) Summary {
+		v := value(event)
+		if s.Count == 0 || v < s.Min {
+			s.Min = v
+		}
+		if s.Count == 0 || v > s.Max {
+			s.Max = v
+		}
+		s.Count++
+		s.Sum += v
+		return s
+	}
+}
+
+// windowState is an open window
+type windowState[T any, K comparable, A any] struct {
+	window Window[K, A]
+	seq    int // Creation order, to emit windows ending together deterministically
+	events []T // Events of a session, folded when it closes
+}
+
+// windowID identifies a tumbling or sliding window
+type windowID[K comparable] struct {
+	key        K
+	start, end int64
+}
+
+// WindowIterator is the Iterator returned by Windowed.
+type WindowIterator[T any, K comparable, A any] struct {
+	*pullIterator[Window[K, A]]
+	upstream Iterator[T]
+	spec     WindowSpec
+	keyOf    func(T) K
+	timeOf   func(T) time.Time
+	add      func(A, T) A
+
+	lateness  time.Duration
+	maxTime   time.Time
+	seen      bool
+	watermark time.Time
+	dropped   int
+	seq       int
+
+	fixed    map[windowID[K]]*windowState[T, K, A]
+	sessions map[K][]*windowState[T, K, A]
+	ready    []Window[K, A] // Closed windows not yet returned
+}
+
+// Windowed returns an Iterator over the aggregates of windows of events.
+// Events are grouped by the key keyOf returns and assigned to windows by the
+// event time timeOf returns; add folds each event into its windows' values,
+// starting from the zero A. A window is returned once the watermark, the
+// latest event time seen minus the allowed lateness, passes its end. Events
+// arriving for windows that were already returned are dropped. Upstream
+// errors are returned unchanged.
+func Windowed[T any, K comparable, A any](it Iterator[T], spec WindowSpec, keyOf func(T) K, timeOf func(T) time.Time, add func(A, T) A) *WindowIterator[T, K, A] {
+	w := &WindowIterator[T, K, A]{
+		upstream: it,
+		spec:     spec,
+		keyOf:    keyOf,
+		timeOf:   timeOf,
+		add:      add,
+		fixed:    make(map[windowID[K]]*windowState[T, K, A]),
+		sessions: make(map[K][]*windowState[T, K, A]),
+	}
+	w.pullIterator = newPullIterator(w.pull)
+	return w
+}
+
+// SetAllowedLateness sets how far behind the latest event time an event may
+// be and still be counted. It must be set before iterating.
+func (w *WindowIterator[T, K, A]) SetAllowedLateness(lateness time.Duration) {
+	w.lateness = lateness
+}
+
+// Watermark returns the event time up to which windows are complete.
+func (w *WindowIterator[T, K, A]) Watermark() time.Time {
+	return w.watermark
+}
+
+// Dropped returns the number of late events dropped so far.
+func (w *WindowIterator[T, K, A]) Dropped() int {
+	return w.dropped
+}
+
+// pull returns the next closed window, reading upstream until one closes
+func (w *WindowIterator[T, K, A]) pull() (Window[K, A], error, bool) {
+	for len(w.ready) == 0 {
+		if !w.upstream.HasNext() {
+			w.flush()
+			if len(w.ready) == 0 {
+				return Window[K, A]{}, nil, false
+			}
+			break
+		}
+		event, err := w.upstream.Next()
+		if err != nil {
+			return Window[K, A]{}, err, true
+		}
+		w.observe(event)
+	}
+	window := w.ready[0]
+	w.ready = w.ready[1:]
+	return window, nil, true
+}
+
+// observe adds an event to its windows and closes the windows the advanced
+// watermark has passed
+func (w *WindowIterator[T, K, A]) observe(event T) {
+	t := w.timeOf(event)
+	if !w.seen || t.After(w.maxTime) {
+		w.maxTime, w.seen = t, true
+	}
+
+	var accepted bool
+	if w.spec.kind == sessionWindow {
+		accepted = w.addToSession(event, t)
+	} else {
+		accepted = w.addToFixed(event, t)
+	}
+	if !accepted {
+		w.dropped++
+	}
+
+	watermark := w.maxTime.Add(-w.lateness)
+	if watermark.After(w.watermark) || w.watermark.IsZero() {
+		w.watermark = watermark
+		w.close(func(window Window[K, A]) bool { return !window.End.After(w.watermark) })
+	}
+}
+
+// isClosed reports whether a window ending at end was already returned
+func (w *WindowIterator[T, K, A]) isClosed(end time.Time) bool {
+	return !w.watermark.IsZero() && !end.After(w.watermark)
+}
+
+// addToFixed adds an event to the tumbling or sliding windows covering it
+func (w *WindowIterator[T, K, A]) addToFixed(event T, t time.Time) bool {
+	size, slide := int64(w.spec.size), int64(w.spec.slide)
+	ns := t.UnixNano()
+	last := ns - mod(ns, slide) // Start of the latest window covering t
+	key := w.keyOf(event)
+	accepted := false
+	for start := last; start > ns-size; start -= slide {
+		end := time.Unix(0, start+size).In(t.Location())
+		if w.isClosed(end) {
+			continue
+		}
+		id := windowID[K]{key: key, start: start, end: start + size}
+		state, ok := w.fixed[id]
+		if !ok {
+			state = w.newState(key, time.Unix(0, start).In(t.Location()), end)
+			w.fixed[id] = state
+		}
+		w.fold(state, event)
+		accepted = true
+	}
+	return accepted
+}
+
+// addToSession adds an event to its key's session, merging the sessions it
+// bridges
+func (w *WindowIterator[T, K, A]) addToSession(event T, t time.Time) bool {
+	key := w.keyOf(event)
+	start, end := t, t.Add(w.spec.size)
+	var merged *windowState[T, K, A]
+	var rest []*windowState[T, K, A]
+	for _, s := range w.sessions[key] {
+		if s.window.Start.After(end) || start.After(s.window.End) {
+			rest = append(rest, s)
+			continue
+		}
+		if merged == nil {
+			merged = s
+			continue
+		}
+		// The event bridges two sessions; fold the later one into the first
+		merged = w.mergeSessions(merged, s)
+	}
+	if merged == nil {
+		if w.isClosed(end) {
+			return false
+		}
+		merged = w.newState(key, start, end)
+	}
+	if start.Before(merged.window.Start) {
+		merged.window.Start = start
+	}
+	if end.After(merged.window.End) {
+		merged.window.End = end
+	}
+	w.fold(merged, event)
+	w.sessions[key] = append(rest, merged)
+	return true
+}
+
+// mergeSessions combines two overlapping sessions into the older one
+func (w *WindowIterator[T, K, A]) mergeSessions(a, b *windowState[T, K, A]) *windowState[T, K, A] {
+	if b.seq < a.seq {
+		a, b = b, a
+	}
+	if b.window.Start.Before(a.window.Start) {
+		a.window.Start = b.window.Start
+	}
+	if b.window.End.After(a.window.End) {
+		a.window.End = b.window.End
+	}
+	a.window.Count += b.window.Count
+	a.events = append(a.events, b.events...)
+	return a
+}
+
+// newState opens a window
+func (w *WindowIterator[T, K, A]) newState(key K, start, end time.Time) *windowState[T, K, A] {
+	w.seq++
+	return &windowState[T, K, A]{
+		window: Window[K, A]{Key: key, Start: start, End: end},
+		seq:    w.seq,
+	}
+}
+
+// fold adds an event to a window's value. Sessions may merge, and values
+// can't be, so session events are kept and folded when the session closes.
+func (w *WindowIterator[T, K, A]) fold(state *windowState[T, K, A], event T) {
+	state.window.Count++
+	if w.spec.kind == sessionWindow {
+		state.events = append(state.events, event)
+		return
+	}
+	state.window.Value = w.add(state.window.Value, event)
+}
+
+// flush closes every open window once upstream is exhausted
+func (w *WindowIterator[T, K, A]) flush() {
+	w.close(func(Window[K, A]) bool { return true })
+}
+
+// close moves the windows selected by done to the ready queue, ordered by
+// end time, then start time, then creation
+func (w *WindowIterator[T, K, A]) close(done func(Window[K, A]) bool) {
+	var closed []*windowState[T, K, A]
+	for id, state := range w.fixed {
+		if done(state.window) {
+			closed = append(closed, state)
+			delete(w.fixed, id)
+		}
+	}
+	for key, sessions := range w.sessions {
+		open := sessions[:0]
+		for _, state := range sessions {
+			if done(state.window) {
+				closed = append(closed, state)
+			} else {
+				open = append(open, state)
+			}
+		}
+		if len(open) == 0 {
+			delete(w.sessions, key)
+		} else {
+			w.sessions[key] = open
+		}
+	}
+
+	slices.SortFunc(closed, func(a, b *windowState[T, K, A]) int {
+		if c := a.window.End.Compare(b.window.End); c != 0 {
+			return c
+		}
+		if c := a.window.Start.Compare(b.window.Start); c != 0 {
+			return c
+		}
+		return a.seq - b.seq
+	})
+	for _, state := range closed {
+		for _, event := range state.events {
+			state.window.Value = w.add(state.window.Value, event)
+		}
+		w.ready = append(w.ready, state.window)
+	}
+}
+
+// mod returns the non-negative remainder of a divided by b
+func mod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
@@ -0,0 +1,174 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// epoch is the event-time origin of the window tests
+var epoch = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+type reading struct {
+	sensor string
+	at     time.Duration // Event time as an offset from epoch
+	value  float64
+}
+
+func readings(values ...reading) Iterator[reading] {
+	return Of(values...).CreateIterator()
+}
+
+func sensorOf(r reading) string     { return r.sensor }
+func eventTime(r reading) time.Time { return epoch.Add(r.at) }
+
+// describeWindows formats windows as "key start-end count mean", with times
+// as offsets from epoch
+func describeWindows(windows []Window[string, Summary]) []string {
+	var out []string
+	for _, w := range windows {
+		out = append(out, fmt.Sprintf("%s %v-%v %d %g", w.Key, w.Start.Sub(epoch), w.End.Sub(epoch), w.Count, w.Value.Mean()))
+	}
+	return out
+}
+
+func TestTumblingWindows(t *testing.T) {
+	events := readings(
+		reading{"a", 5 * time.Second, 10},
+		reading{"b", 20 * time.Second, 1},
+		reading{"a", 50 * time.Second, 20},
+		reading{"a", 70 * time.Second, 5},
+		reading{"b", 3 * time.Minute, 2},
+	)
+	it := Windowed(events, Tumbling(time.Minute), sensorOf, eventTime, Summarize(func(r reading) float64 { return r.value }))
+
+	// The first window closes as soon as an event passes its end
+	first, _ := it.Next()
+	if first.Key != "a" || first.Count != 2 || first.Value.Mean() != 15 || first.Value.Max != 20 {
+		t.Errorf("Unexpected first window %+v", first)
+	}
+	if got := it.Watermark().Sub(epoch); got != 70*time.Second {
+		t.Errorf("Expected the watermark at 1m10s, got %v", got)
+	}
+
+	rest, err := Collect[Window[string, Summary]](it)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"b 0s-1m0s 1 1", "a 1m0s-2m0s 1 5", "b 3m0s-4m0s 1 2"}
+	if got := describeWindows(rest); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestAllowedLateness(t *testing.T) {
+	events := readings(
+		reading{"a", 50 * time.Second, 1},
+		reading{"a", 70 * time.Second, 2},
+		reading{"a", 55 * time.Second, 3},  // Out of order, within the lateness
+		reading{"a", 100 * time.Second, 4}, // Watermark 1m10s closes the first minute
+		reading{"a", 58 * time.Second, 5},  // Too late
+		reading{"a", 65 * time.Second, 6},
+	)
+	count := func(n int, _ reading) int { return n + 1 }
+
+	it := Windowed(events, Tumbling(time.Minute), sensorOf, eventTime, count)
+	it.SetAllowedLateness(30 * time.Second)
+	windows, _ := Collect[Window[string, int]](it)
+	if len(windows) != 2 || windows[0].Value != 2 || windows[1].Value != 3 {
+		t.Errorf("Expected windows of 2 and 3 events, got %+v", windows)
+	}
+	if it.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped event, got %d", it.Dropped())
+	}
+
+	strict := Windowed(readings(reading{"a", 70 * time.Second, 0}, reading{"a", 55 * time.Second, 0}),
+		Tumbling(time.Minute), sensorOf, eventTime, count)
+	Collect[Window[string, int]](strict)
+	if strict.Dropped() != 1 {
+		t.Errorf("Expected out-of-order events to be dropped without lateness, got %d", strict.Dropped())
+	}
+}
+
+func TestSlidingWindows(t *testing.T) {
+	events := readings(
+		reading{"a", 30 * time.Second, 1},
+		reading{"a", 90 * time.Second, 3},
+		reading{"a", 150 * time.Second, 5},
+	)
+	it := Windowed(events, Sliding(2*time.Minute, time.Minute), sensorOf, eventTime, Summarize(func(r reading) float64 { return r.value }))
+	windows, _ := Collect[Window[string, Summary]](it)
+	expected := []string{
+		"a -1m0s-1m0s 1 1",
+		"a 0s-2m0s 2 2",
+		"a 1m0s-3m0s 2 4",
+		"a 2m0s-4m0s 1 5",
+	}
+	if got := describeWindows(windows); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestInvalidWindowSpecs(t *testing.T) {
+	specs := map[string]func(){
+		"Tumbling(0)":      func() { Tumbling(0) },
+		"Sliding(1m, 0)":   func() { Sliding(time.Minute, 0) },
+		"Sliding(1m, -1s)": func() { Sliding(time.Minute, -time.Second) },
+		"Sliding(1m, 2m)":  func() { Sliding(time.Minute, 2*time.Minute) },
+		"Sliding(0, 0)":    func() { Sliding(0, 0) },
+		"Tumbling(-1m)":    func() { Tumbling(-time.Minute) },
+		"Session(0)":       func() { Session(0) },
+		"Session(-1s)":     func() { Session(-time.Second) },
+	}
+	for name, build := range specs {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %s to panic", name)
+				}
+			}()
+			build()
+		}()
+	}
+}
+
+func TestSessionWindows(t *testing.T) {
+	events := readings(
+		reading{"a", 0, 1},
+		reading{"b", 5 * time.Second, 7},
+		reading{"a", 40 * time.Second, 3},  // Starts a second session
+		reading{"a", 20 * time.Second, 2},  // Bridges both sessions
+		reading{"a", 2 * time.Minute, 10},  // A gap later
+		reading{"b", 130 * time.Second, 8}, // Watermark 1m40s closes a's first session
+	)
+	it := Windowed(events, Session(30*time.Second), sensorOf, eventTime, Summarize(func(r reading) float64 { return r.value }))
+	it.SetAllowedLateness(30 * time.Second)
+	windows, _ := Collect[Window[string, Summary]](it)
+	expected := []string{
+		"b 5s-35s 1 7",
+		"a 0s-1m10s 3 2",
+		"a 2m0s-2m30s 1 10",
+		"b 2m10s-2m40s 1 8",
+	}
+	if got := describeWindows(windows); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestWindowedPropagatesErrors(t *testing.T) {
+	boom := errors.New("boom")
+	upstream := &failingIterator{values: []int{1, 2}, err: boom}
+	at := func(n int) time.Time { return epoch.Add(time.Duration(n) * time.Second) }
+	it := Windowed[int](upstream, Tumbling(time.Minute), func(int) string { return "k" }, at,
+		func(sum, n int) int { return sum + n })
+
+	if _, err := it.Next(); err != boom {
+		t.Fatalf("Expected boom, got %v", err)
+	}
+	window, err := it.Next()
+	if err != nil || window.Value != 3 {
+		t.Errorf("Expected the open window after the error, got %+v, %v", window, err)
+	}
+}
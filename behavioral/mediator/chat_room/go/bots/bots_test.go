@@ -0,0 +1,183 @@
+package bots_test
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mediator_pattern_chat_room_go/bots"
+	"mediator_pattern_chat_room_go/chat_room"
+)
+
+// Member records the structured messages it receives.
+type Member struct {
+	name     string
+	mutex    sync.Mutex
+	messages []chat_room.Message
+}
+
+func (m *Member) Send(message string)                         {}
+func (m *Member) Receive(message string, senderName string)   {}
+func (m *Member) GetName() string                             { return m.name }
+func (m *Member) SetMediator(mediator chat_room.ChatMediator) {}
+
+func (m *Member) ReceiveMessage(msg chat_room.Message) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messages = append(m.messages, msg)
+}
+
+// From returns the bodies of the messages sent by name, prefixed with the
+// kind of direct messages.
+func (m *Member) From(name string) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var bodies []string
+	for _, msg := range m.messages {
+		if msg.Sender != name {
+			continue
+		}
+		if msg.Kind == chat_room.DirectMessage {
+			bodies = append(bodies, "dm: "+msg.Body)
+		} else {
+			bodies = append(bodies, msg.Body)
+		}
+	}
+	return bodies
+}
+
+// newRoom creates a room with a bot and members, with room logs silenced.
+func newRoom(t *testing.T, bot chat_room.User, names ...string) (*chat_room.ChatRoom, []*Member) {
+	t.Helper()
+	quiet(t)
+	room, err := chat_room.NewLobby().CreateRoom("general", chat_room.RoomOptions{Owner: "Olivia"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members []*Member
+	for _, name := range names {
+		member := &Member{name: name}
+		room.Join(member)
+		members = append(members, member)
+	}
+	room.Join(bot)
+	return room, members
+}
+
+// quiet discards log output until the end of the test
+func quiet(t *testing.T) {
+	log.SetOutput(&bytes.Buffer{})
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func TestEchoBot(t *testing.T) {
+	bot := bots.NewEchoBot("echo")
+	room, members := newRoom(t, bot, "Alice", "Bob")
+	alice, bob := members[0], members[1]
+
+	room.Post(chat_room.Message{Body: "@echo, hello there"}, alice)
+	room.Post(chat_room.Message{Body: "!echo once more"}, alice)
+	room.Flush() // The bot answers messages from its mailbox, commands right away
+	if err := room.RunCommand("/echo by command", alice); err != nil {
+		t.Errorf("Expected /echo to run, got %v", err)
+	}
+	room.Flush()
+
+	expected := []string{"Alice said: hello there", "once more", "by command"}
+	if got := bob.From("echo"); !slices.Equal(got, expected) {
+		t.Errorf("Expected Bob to see %q, got %q", expected, got)
+	}
+}
+
+func TestReminderBot(t *testing.T) {
+	bot := bots.NewReminderBot("reminder", 5*time.Millisecond)
+	defer bot.Stop()
+	room, members := newRoom(t, bot, "Alice", "Bob")
+	alice, bob := members[0], members[1]
+
+	if err := room.RunCommand("/remind soon stretch", alice); !errors.Is(err, chat_room.ErrUsage) {
+		t.Errorf("Expected ErrUsage for a bad duration, got %v", err)
+	}
+	if err := room.RunCommand("/remind 30ms stretch your legs", alice); err != nil {
+		t.Fatalf("Expected /remind to run, got %v", err)
+	}
+	room.Flush()
+	if got := alice.From("reminder"); !slices.Equal(got, []string{"dm: I will remind you in 30ms."}) {
+		t.Errorf("Expected a private confirmation, got %q", got)
+	}
+	if got := bob.From("reminder"); len(got) != 0 {
+		t.Errorf("Expected nothing for Bob yet, got %q", got)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(bob.From("reminder")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := bob.From("reminder"); !slices.Equal(got, []string{"@Alice reminder: stretch your legs"}) {
+		t.Errorf("Expected the reminder, got %q", got)
+	}
+	room.Flush()
+	alice.mutex.Lock()
+	defer alice.mutex.Unlock()
+	if last := alice.messages[len(alice.messages)-1]; !last.Mentioned {
+		t.Errorf("Expected the reminder to mention Alice")
+	}
+}
+
+func TestPollBot(t *testing.T) {
+	bot := bots.NewPollBot("poll")
+	bot.SetBudget(0, 0)
+	room, members := newRoom(t, bot, "Olivia", "Alice", "Bob")
+	olivia, alice, bob := members[0], members[1], members[2]
+
+	run := func(line string, member *Member) error {
+		return room.RunCommand(line, member)
+	}
+	if err := run("/vote 1", alice); err == nil || !strings.Contains(err.Error(), "no poll") {
+		t.Errorf("Expected no poll to be running, got %v", err)
+	}
+	if err := run("/poll Lunch? | Pizza", alice); !errors.Is(err, chat_room.ErrUsage) {
+		t.Errorf("Expected ErrUsage for a poll with one option, got %v", err)
+	}
+	if err := run("/poll Lunch? | Pizza | Sushi", alice); err != nil {
+		t.Fatalf("Expected the poll to start, got %v", err)
+	}
+	if err := run("/poll Dinner? | Soup | Salad", bob); err == nil {
+		t.Errorf("Expected a second poll to be refused")
+	}
+	if err := run("/vote 3", bob); err == nil {
+		t.Errorf("Expected an unknown option to be refused")
+	}
+	run("/vote 1", bob)
+	run("/vote 2", bob) // Changes the vote
+	run("/vote 2", alice)
+	run("/vote 1", olivia)
+	run("/results", bob)
+	if err := run("/endpoll", bob); !errors.Is(err, chat_room.ErrPermissionDenied) {
+		t.Errorf("Expected Bob not to close Alice's poll, got %v", err)
+	}
+	if err := run("/endpoll", olivia); err != nil {
+		t.Errorf("Expected the owner of the room to close the poll, got %v", err)
+	}
+	room.Flush()
+
+	expected := []string{
+		"Alice asks: Lunch? 1) Pizza 2) Sushi. Vote with /vote <number>.",
+		"dm: You voted for Pizza.",
+		"dm: You voted for Sushi.",
+		"dm: Lunch? Pizza 1, Sushi 2 (3 votes)",
+		"Poll closed: Lunch? Pizza 1, Sushi 2 (3 votes)",
+	}
+	if got := bob.From("poll"); !slices.Equal(got, expected) {
+		t.Errorf("Expected Bob to see %q, got %q", expected, got)
+	}
+	if err := run("/poll Dinner? | Soup | Salad", bob); err != nil {
+		t.Errorf("Expected a new poll once the last one closed, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+// Package bots holds example chat_room bots: an echo bot, a reminder bot
+// and a poll bot.
+package bots
+
+import (
+	"regexp"
+	"strings"
+
+	"mediator_pattern_chat_room_go/chat_room"
+)
+
+// NewEchoBot creates a bot that repeats what it is told: messages that
+// @mention it, lines starting with "!echo", and the /echo command.
+func NewEchoBot(name string) *chat_room.Bot {
+	bot := chat_room.NewBot(name)
+	mention := regexp.MustCompile(`@` + regexp.QuoteMeta(name) + `\b[,:]?`)
+
+	bot.OnMention(func(event *chat_room.BotEvent) error {
+		text := strings.Join(strings.Fields(mention.ReplaceAllString(event.Message.Body, "")), " ")
+		return event.Reply(event.Message.Sender + " said: " + text)
+	})
+	bot.OnPattern(`^!echo\s+(.+)`, func(event *chat_room.BotEvent) error {
+		return event.Reply(event.Match[1])
+	})
+	bot.OnCommand(chat_room.Command{Name: "echo", Usage: "<text>", Help: "Have the bot repeat text"}, func(event *chat_room.BotEvent) error {
+		if len(event.Args) == 0 {
+			return chat_room.ErrUsage
+		}
+		return event.Reply(strings.Join(event.Args, " "))
+	})
+	return bot
+}
@@ -0,0 +1,157 @@
+package bots
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"mediator_pattern_chat_room_go/chat_room"
+)
+
+var (
+	errNoPoll       = errors.New("no poll is running")
+	errPollRunning  = errors.New("a poll is already running")
+	errNoSuchOption = errors.New("no such option")
+)
+
+// poll is the running poll of a room
+type poll struct {
+	question string
+	options  []string
+	owner    string
+	votes    map[string]int // Option index by voter
+}
+
+// results renders the tally of a poll
+func (p *poll) results() string {
+	counts := make([]int, len(p.options))
+	for _, option := range p.votes {
+		counts[option]++
+	}
+	parts := make([]string, len(p.options))
+	for i, option := range p.options {
+		parts[i] = fmt.Sprintf("%s %d", option, counts[i])
+	}
+	return fmt.Sprintf("%s %s (%d votes)", p.question, strings.Join(parts, ", "), len(p.votes))
+}
+
+// pollKey is where the poll bot keeps the *poll of a room
+func pollKey(room *chat_room.ChatRoom) string {
+	return "poll:" + room.Name()
+}
+
+// NewPollBot creates a bot running one poll per room:
+//
+//	/poll Lunch? | Pizza | Sushi   starts a poll
+//	/vote 2                        votes, or changes a vote
+//	/results                       shows the tally privately
+//	/endpoll                       announces the tally, for the one who
+//	                               started the poll and moderators
+func NewPollBot(name string) *chat_room.Bot {
+	bot := chat_room.NewBot(name)
+
+	start := chat_room.Command{Name: "poll", Usage: "<question> | <option> | <option>...", Help: "Start a poll"}
+	bot.OnCommand(start, func(event *chat_room.BotEvent) error {
+		fields := strings.Split(strings.Join(event.Args, " "), "|")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if len(fields) < 3 || slices.Contains(fields, "") {
+			return chat_room.ErrUsage
+		}
+		created := &poll{question: fields[0], options: fields[1:], owner: event.Message.Sender, votes: make(map[string]int)}
+		var err error
+		event.State().Update(pollKey(event.Room), func(value any, ok bool) any {
+			if ok {
+				err = errPollRunning
+				return value
+			}
+			return created
+		})
+		if err != nil {
+			return err
+		}
+		options := make([]string, len(created.options))
+		for i, option := range created.options {
+			options[i] = fmt.Sprintf("%d) %s", i+1, option)
+		}
+		return event.Reply(fmt.Sprintf("%s asks: %s %s. Vote with /vote <number>.", created.owner, created.question, strings.Join(options, " ")))
+	})
+
+	vote := chat_room.Command{Name: "vote", Usage: "<number>", Help: "Vote in the running poll"}
+	bot.OnCommand(vote, func(event *chat_room.BotEvent) error {
+		if len(event.Args) != 1 {
+			return chat_room.ErrUsage
+		}
+		choice, err := strconv.Atoi(event.Args[0])
+		if err != nil {
+			return chat_room.ErrUsage
+		}
+		var option string
+		event.State().Update(pollKey(event.Room), func(value any, ok bool) any {
+			if !ok {
+				err = errNoPoll
+				return nil
+			}
+			running := value.(*poll)
+			if choice < 1 || choice > len(running.options) {
+				err = errNoSuchOption
+				return running
+			}
+			running.votes[event.Message.Sender] = choice - 1
+			option = running.options[choice-1]
+			return running
+		})
+		if err != nil {
+			return err
+		}
+		return event.ReplyPrivately("You voted for " + option + ".")
+	})
+
+	results := chat_room.Command{Name: "results", Help: "Show the votes of the running poll"}
+	bot.OnCommand(results, func(event *chat_room.BotEvent) error {
+		tally, err := tallyOf(event, false)
+		if err != nil {
+			return err
+		}
+		return event.ReplyPrivately(tally)
+	})
+
+	end := chat_room.Command{Name: "endpoll", Help: "Close the running poll"}
+	bot.OnCommand(end, func(event *chat_room.BotEvent) error {
+		tally, err := tallyOf(event, true)
+		if err != nil {
+			return err
+		}
+		return event.Reply("Poll closed: " + tally)
+	})
+	return bot
+}
+
+// tallyOf returns the results of the poll of the room, closing it if asked
+// to by its owner or a moderator
+func tallyOf(event *chat_room.BotEvent, close bool) (string, error) {
+	sender := event.Message.Sender
+	moderator := event.Room.Role(sender) >= chat_room.Moderator
+	var tally string
+	var err error
+	event.State().Update(pollKey(event.Room), func(value any, ok bool) any {
+		if !ok {
+			err = errNoPoll
+			return nil
+		}
+		running := value.(*poll)
+		tally = running.results()
+		if !close {
+			return running
+		}
+		if running.owner != sender && !moderator {
+			err = chat_room.ErrPermissionDenied
+			return running
+		}
+		return nil
+	})
+	return tally, err
+}
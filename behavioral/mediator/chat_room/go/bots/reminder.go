@@ -0,0 +1,81 @@
+package bots
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"mediator_pattern_chat_room_go/chat_room"
+)
+
+// reminder is a pending /remind
+type reminder struct {
+	due  time.Time
+	room string
+	user string
+	text string
+}
+
+// remindersKey is where the reminder bot keeps its []reminder
+const remindersKey = "reminders"
+
+// NewReminderBot creates a bot that answers "/remind 10m stretch" by
+// mentioning the member with the text once the duration has passed. Due
+// reminders are checked every tick, which must be positive.
+func NewReminderBot(name string, tick time.Duration) *chat_room.Bot {
+	bot := chat_room.NewBot(name)
+
+	remind := chat_room.Command{Name: "remind", Usage: "<duration> <text>", Help: "Get a reminder, like /remind 10m stretch"}
+	bot.OnCommand(remind, func(event *chat_room.BotEvent) error {
+		if len(event.Args) < 2 {
+			return chat_room.ErrUsage
+		}
+		delay, err := time.ParseDuration(event.Args[0])
+		if err != nil || delay <= 0 {
+			return chat_room.ErrUsage
+		}
+		pending := reminder{
+			due:  time.Now().Add(delay),
+			room: event.Room.Name(),
+			user: event.Message.Sender,
+			text: strings.Join(event.Args[1:], " "),
+		}
+		event.State().Update(remindersKey, func(value any, ok bool) any {
+			reminders, _ := value.([]reminder)
+			return append(reminders, pending)
+		})
+		return event.ReplyPrivately(fmt.Sprintf("I will remind you in %s.", delay))
+	})
+
+	bot.Every(tick, func(event *chat_room.BotEvent) error {
+		var due []reminder
+		now := time.Now()
+		event.State().Update(remindersKey, func(value any, ok bool) any {
+			reminders, _ := value.([]reminder)
+			var pending []reminder
+			for _, r := range reminders {
+				if r.room == event.Room.Name() && !now.Before(r.due) {
+					due = append(due, r)
+				} else {
+					pending = append(pending, r)
+				}
+			}
+			if len(pending) == 0 {
+				return nil
+			}
+			return pending
+		})
+		for i, r := range due {
+			if err := event.Reply(fmt.Sprintf("@%s reminder: %s", r.user, r.text)); err != nil {
+				// Try again at the next tick, for instance once the budget refills
+				event.State().Update(remindersKey, func(value any, ok bool) any {
+					reminders, _ := value.([]reminder)
+					return append(reminders, due[i:]...)
+				})
+				return err
+			}
+		}
+		return nil
+	})
+	return bot
+}
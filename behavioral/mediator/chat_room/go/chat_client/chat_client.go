@@ -0,0 +1,176 @@
+// Package chat_client connects to a chat_server over TCP or WebSocket.
+package chat_client
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"mediator_pattern_chat_room_go/protocol"
+)
+
+// ErrClosed is returned when sending on a closed client.
+var ErrClosed = errors.New("chat client closed")
+
+// JoinError reports that the server rejected the join.
+type JoinError struct {
+	Reason string
+}
+
+func (e *JoinError) Error() string {
+	return "join rejected: " + e.Reason
+}
+
+// Client is a joined chat session.
+type Client struct {
+	conn   protocol.Conn
+	name   string
+	frames chan *protocol.Frame
+	done   chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+	err    error
+}
+
+// Dial connects to a TCP chat server and joins as name.
+func Dial(addr string, name string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return Join(protocol.NewLineConn(conn), name)
+}
+
+// DialWebSocket connects to a WebSocket chat server at a ws:// URL and joins
+// as name.
+func DialWebSocket(url string, name string) (*Client, error) {
+	conn, err := protocol.DialWebSocket(url)
+	if err != nil {
+		return nil, err
+	}
+	return Join(conn, name)
+}
+
+// Join joins as name over an established connection and waits for the
+// server to accept. The connection is closed if the join fails.
+func Join(conn protocol.Conn, name string) (*Client, error) {
+	if err := conn.WriteFrame(&protocol.Frame{Type: protocol.Join, Name: name}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// Frames for others may arrive before the acknowledgement; keep them
+	var pending []*protocol.Frame
+	for {
+		frame, err := conn.ReadFrame()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if frame.Type == protocol.Error {
+			conn.Close()
+			return nil, &JoinError{Reason: frame.Error}
+		}
+		if frame.Type == protocol.Join && frame.Name == name {
+			break
+		}
+		pending = append(pending, frame)
+	}
+
+	c := &Client{
+		conn:   conn,
+		name:   name,
+		frames: make(chan *protocol.Frame, 64),
+		done:   make(chan struct{}),
+	}
+	go c.read(pending)
+	return c, nil
+}
+
+// read delivers incoming frames until the connection ends
+func (c *Client) read(pending []*protocol.Frame) {
+	defer close(c.done)
+	defer close(c.frames)
+	for _, frame := range pending {
+		c.frames <- frame
+	}
+	for {
+		frame, err := c.conn.ReadFrame()
+		var frameErr *protocol.FrameError
+		if errors.As(err, &frameErr) {
+			continue
+		}
+		if err != nil {
+			c.mu.Lock()
+			if !c.closed {
+				c.err = err
+			}
+			c.mu.Unlock()
+			return
+		}
+		c.frames <- frame
+	}
+}
+
+// Name returns the name the client joined with.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Send sends a chat message to the room.
+func (c *Client) Send(body string) error {
+	return c.write(&protocol.Frame{Type: protocol.Message, Body: body})
+}
+
+// SendDirect sends a direct message to another user of the room.
+func (c *Client) SendDirect(to string, body string) error {
+	return c.write(&protocol.Frame{Type: protocol.Message, To: to, Body: body})
+}
+
+// write sends a frame unless the client was closed
+func (c *Client) write(frame *protocol.Frame) error {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	return c.conn.WriteFrame(frame)
+}
+
+// Frames returns the frames received from the server: messages, the joins and
+// leaves of others, and errors. The channel is closed when the connection
+// ends and must be drained, or the client stops reading.
+func (c *Client) Frames() <-chan *protocol.Frame {
+	return c.frames
+}
+
+// Err returns why the connection ended, or nil if it ended through Close.
+// It is only meaningful once Frames has been closed.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close leaves the room and closes the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	c.conn.WriteFrame(&protocol.Frame{Type: protocol.Leave})
+	err := c.conn.Close()
+	// Unblock the reader if nobody drains the frames
+	go func() {
+		for range c.frames {
+		}
+	}()
+	<-c.done
+	return err
+}
@@ -0,0 +1,392 @@
+package chat_room
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBotBudget is how many messages a bot may post per
+// DefaultBotBudgetPeriod unless SetBudget says otherwise.
+const DefaultBotBudget = 5
+
+// DefaultBotBudgetPeriod is the period DefaultBotBudget applies to.
+const DefaultBotBudgetPeriod = 10 * time.Second
+
+// ErrRateLimited is returned when a bot has used up its message budget.
+var ErrRateLimited = errors.New("bot message budget exhausted")
+
+// BotHandler reacts to a trigger of a bot, usually by replying through the
+// event.
+type BotHandler func(event *BotEvent) error
+
+// BotEvent describes what set off a trigger and lets the handler reply.
+type BotEvent struct {
+	Bot     *Bot
+	Room    *ChatRoom
+	Message Message  // The message that matched; zero for a schedule
+	Match   []string // Submatches of a pattern trigger
+	Args    []string // Arguments of a command trigger
+}
+
+// Reply posts a message from the bot to the room.
+func (e *BotEvent) Reply(body string) error {
+	return e.Bot.post(e.Room, Message{Kind: ChatMessage, Body: body})
+}
+
+// ReplyPrivately sends a direct message from the bot to the sender of the
+// message that set off the trigger.
+func (e *BotEvent) ReplyPrivately(body string) error {
+	if e.Message.Sender == "" {
+		return ErrNoRecipient
+	}
+	return e.Bot.post(e.Room, Message{Kind: DirectMessage, To: e.Message.Sender, Body: body})
+}
+
+// State returns the state store of the bot.
+func (e *BotEvent) State() *BotState {
+	return e.Bot.state
+}
+
+// BotState is the key-value store of a bot, safe for concurrent use.
+type BotState struct {
+	mutex  sync.Mutex
+	values map[string]any
+}
+
+// Get returns the value stored under key.
+func (s *BotState) Get(key string) (any, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// Set stores a value under key.
+func (s *BotState) Set(key string, value any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values[key] = value
+}
+
+// Delete removes the value stored under key.
+func (s *BotState) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.values, key)
+}
+
+// Update replaces the value under key with what update returns, atomically.
+// update gets the current value, and whether there is one; returning nil
+// deletes the key.
+func (s *BotState) Update(key string, update func(value any, ok bool) any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.values[key]
+	if value = update(value, ok); value == nil {
+		delete(s.values, key)
+		return
+	}
+	s.values[key] = value
+}
+
+// triggerKind identifies what a trigger reacts to
+type triggerKind int
+
+const (
+	onPattern triggerKind = iota
+	onMention
+	onCommand
+	onSchedule
+)
+
+// trigger pairs a condition with its handler
+type trigger struct {
+	kind     triggerKind
+	pattern  *regexp.Regexp
+	command  Command
+	interval time.Duration
+	handler  BotHandler
+}
+
+// Bot is an automated member of chat rooms. Instead of implementing User,
+// register triggers with handlers: patterns and mentions are matched
+// against the messages the bot receives, commands are added to the
+// Commands of the rooms the bot is in, and schedules run while the bot is
+// in a room. Replies go through the room and count against the budget of
+// the bot. Handlers may run concurrently; keep state in the State store.
+type Bot struct {
+	name  string
+	state *BotState
+
+	mutex     sync.Mutex
+	triggers  []trigger
+	mediator  ChatMediator         // Room that Send talks to: the last one joined
+	rooms     map[string]*ChatRoom // Rooms the bot is in, by name
+	schedules map[string]chan struct{}
+	commands  map[string][]string // Names the bot registered, by room it is set up in
+	budget    int
+	period    time.Duration
+	tokens    float64 // Messages the bot may still post
+	refilled  time.Time
+	running   sync.WaitGroup // Schedule goroutines
+}
+
+// NewBot creates a bot with an empty state and the default budget.
+func NewBot(name string) *Bot {
+	return &Bot{
+		name:      name,
+		state:     &BotState{values: make(map[string]any)},
+		rooms:     make(map[string]*ChatRoom),
+		schedules: make(map[string]chan struct{}),
+		commands:  make(map[string][]string),
+		budget:    DefaultBotBudget,
+		period:    DefaultBotBudgetPeriod,
+		tokens:    DefaultBotBudget,
+		refilled:  time.Now(),
+	}
+}
+
+// SetBudget lets the bot post up to messages per period; the budget refills
+// steadily over the period. A zero period lifts the limit.
+func (b *Bot) SetBudget(messages int, period time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.budget = max(messages, 0)
+	b.period = period
+	b.tokens = float64(b.budget)
+	b.refilled = time.Now()
+}
+
+// State returns the state store of the bot.
+func (b *Bot) State() *BotState {
+	return b.state
+}
+
+// OnPattern calls handler for messages whose body matches pattern.
+func (b *Bot) OnPattern(pattern string, handler BotHandler) {
+	b.addTrigger(trigger{kind: onPattern, pattern: regexp.MustCompile(pattern), handler: handler})
+}
+
+// OnMention calls handler for messages that @mention the bot, and for
+// direct messages to it.
+func (b *Bot) OnMention(handler BotHandler) {
+	b.addTrigger(trigger{kind: onMention, handler: handler})
+}
+
+// OnCommand adds a slash command to the rooms the bot joins. The Handler of
+// the command is ignored; handler runs instead, with the arguments in
+// event.Args.
+func (b *Bot) OnCommand(command Command, handler BotHandler) {
+	b.addTrigger(trigger{kind: onCommand, command: command, handler: handler})
+}
+
+// Every calls handler at each interval in each room the bot is in. It
+// panics if interval is not positive, rather than when the bot joins a room.
+func (b *Bot) Every(interval time.Duration, handler BotHandler) {
+	if interval <= 0 {
+		panic(fmt.Sprintf("chat_room: Every interval must be positive, got %v", interval))
+	}
+	b.addTrigger(trigger{kind: onSchedule, interval: interval, handler: handler})
+}
+
+// addTrigger registers a trigger; add them before the bot joins a room
+func (b *Bot) addTrigger(t trigger) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.triggers = append(b.triggers, t)
+}
+
+// GetName returns the bot's name.
+func (b *Bot) GetName() string {
+	return b.name
+}
+
+// SetMediator records a room the bot joined. Required by ChatRoom.Join.
+func (b *Bot) SetMediator(mediator ChatMediator) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.mediator = mediator
+	if room, ok := mediator.(*ChatRoom); ok {
+		b.rooms[room.name] = room // The room is locked, so no Name()
+	}
+}
+
+// Send posts a message to the last room the bot joined.
+func (b *Bot) Send(message string) {
+	b.mutex.Lock()
+	mediator := b.mediator
+	b.mutex.Unlock()
+	if mediator != nil {
+		mediator.SendMessage(message, b)
+	}
+}
+
+// Receive ignores plain messages; rooms deliver structured ones to
+// ReceiveMessage.
+func (b *Bot) Receive(message string, senderName string) {}
+
+// ReceiveMessage runs the pattern and mention triggers matching a message.
+// Replayed history and system messages are ignored.
+func (b *Bot) ReceiveMessage(msg Message) {
+	if msg.Replayed || msg.Kind == SystemMessage || msg.Sender == b.name {
+		return
+	}
+	b.mutex.Lock()
+	room := b.rooms[msg.Room]
+	triggers := b.triggers
+	b.mutex.Unlock()
+	if room == nil {
+		return
+	}
+
+	for _, t := range triggers {
+		event := &BotEvent{Bot: b, Room: room, Message: msg}
+		switch t.kind {
+		case onPattern:
+			event.Match = t.pattern.FindStringSubmatch(msg.Body)
+			if event.Match == nil {
+				continue
+			}
+		case onMention:
+			if !msg.Mentioned && !(msg.Kind == DirectMessage && msg.To == b.name) {
+				continue
+			}
+		default:
+			continue
+		}
+		if err := t.handler(event); err != nil {
+			log.Printf("--- Bot %s failed in %s: %v ---", b.name, room.label(), err)
+		}
+	}
+}
+
+// OnRoomEvent adds the commands and starts the schedules of the bot when it
+// joins a room, and undoes that when it leaves.
+func (b *Bot) OnRoomEvent(event RoomEvent) {
+	switch {
+	case event.Kind == MemberJoined && event.User == b.name:
+		b.start(event.Room)
+	case event.Kind == RoomDeleted, event.Kind == RoomClosed, event.Kind == MemberLeft && event.User == b.name:
+		b.stop(event.Room)
+	}
+}
+
+// start sets the bot up in a room it joined
+func (b *Bot) start(name string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	room := b.rooms[name]
+	if _, ok := b.commands[name]; room == nil || ok {
+		return
+	}
+	done := make(chan struct{})
+	b.schedules[name] = done
+	registered := []string{}
+
+	for _, t := range b.triggers {
+		switch t.kind {
+		case onCommand:
+			command := t.command
+			handler := t.handler
+			command.Handler = func(room *ChatRoom, sender User, args []string) error {
+				line := strings.TrimSpace("/" + command.Name + " " + strings.Join(args, " "))
+				msg := Message{Room: room.Name(), Sender: sender.GetName(), Body: line, Timestamp: time.Now()}
+				return handler(&BotEvent{Bot: b, Room: room, Message: msg, Args: args})
+			}
+			if err := room.Commands().Register(command); err != nil {
+				// Another owner keeps the name, and its command stays when the bot leaves
+				log.Printf("--- Bot %s cannot add /%s to %s: %v ---", b.name, command.Name, room.label(), err)
+				continue
+			}
+			registered = append(registered, command.Name)
+		case onSchedule:
+			b.running.Add(1)
+			go b.schedule(room, t, done)
+		}
+	}
+	b.commands[name] = registered
+}
+
+// schedule runs a schedule trigger in a room until done is closed
+func (b *Bot) schedule(room *ChatRoom, t trigger, done chan struct{}) {
+	defer b.running.Done()
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := t.handler(&BotEvent{Bot: b, Room: room}); err != nil {
+				log.Printf("--- Bot %s failed in %s: %v ---", b.name, room.label(), err)
+			}
+		}
+	}
+}
+
+// stop removes the bot's commands and schedules from a room it left
+func (b *Bot) stop(name string) {
+	b.mutex.Lock()
+	room := b.rooms[name]
+	done := b.schedules[name]
+	registered := b.commands[name]
+	delete(b.rooms, name)
+	delete(b.schedules, name)
+	delete(b.commands, name)
+	if room != nil && b.mediator == ChatMediator(room) {
+		b.mediator = nil
+	}
+	b.mutex.Unlock()
+	if done != nil { // Already closed if Stop ran
+		close(done)
+	}
+	for _, command := range registered {
+		room.Commands().Unregister(command)
+	}
+}
+
+// Stop stops the schedules of the bot in every room and waits for running
+// handlers to return. The bot stays in its rooms, and its commands with it
+// until it leaves.
+func (b *Bot) Stop() {
+	b.mutex.Lock()
+	for name, done := range b.schedules {
+		close(done)
+		delete(b.schedules, name)
+	}
+	b.mutex.Unlock()
+	b.running.Wait()
+}
+
+// post sends a message from the bot to a room if the budget allows it
+func (b *Bot) post(room *ChatRoom, msg Message) error {
+	if !b.spend() {
+		log.Printf("--- Bot %s is over its budget in %s. ---", b.name, room.label())
+		return ErrRateLimited
+	}
+	return room.Post(msg, b)
+}
+
+// spend takes one message from the budget, refilled in proportion to the
+// time since the last refill
+func (b *Bot) spend() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.period <= 0 {
+		return true
+	}
+	now := time.Now()
+	b.tokens += float64(b.budget) * float64(now.Sub(b.refilled)) / float64(b.period)
+	b.tokens = min(b.tokens, float64(b.budget))
+	b.refilled = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
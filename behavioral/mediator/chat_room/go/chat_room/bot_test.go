@@ -0,0 +1,289 @@
+package chat_room_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cr "mediator_pattern_chat_room_go/chat_room"
+)
+
+func TestBot_Triggers(t *testing.T) {
+	lobby := cr.NewLobby()
+	alice := NewStructuredUser("Alice")
+	bot := cr.NewBot("Helper")
+	bot.OnPattern(`^(\d+) \+ (\d+)$`, func(event *cr.BotEvent) error {
+		return event.Reply(event.Match[1] + " plus " + event.Match[2])
+	})
+	bot.OnMention(func(event *cr.BotEvent) error {
+		return event.ReplyPrivately("You called, " + event.Message.Sender + "?")
+	})
+	var room *cr.ChatRoom
+	captureOutput(func() {
+		room, _ = lobby.CreateRoom("general", cr.RoomOptions{})
+		room.Join(alice)
+		room.Join(bot)
+		room.Post(cr.Message{Body: "2 + 3"}, alice)
+		room.Post(cr.Message{Body: "hi @Helper"}, alice)
+		room.Post(cr.Message{Kind: cr.DirectMessage, To: "Helper", Body: "psst"}, alice)
+		room.Post(cr.Message{Body: "nothing to see"}, alice)
+		room.Announce("@Helper system messages are ignored")
+		room.Flush()
+	})
+
+	var replies []string
+	for _, msg := range alice.Received() {
+		if msg.Sender == "Helper" {
+			replies = append(replies, msg.Kind.String()+": "+msg.Body)
+		}
+	}
+	expected := []string{"chat: 2 plus 3", "dm: You called, Alice?", "dm: You called, Alice?"}
+	if !slices.Equal(replies, expected) {
+		t.Errorf("Expected replies %q, got %q", expected, replies)
+	}
+}
+
+func TestBot_Commands(t *testing.T) {
+	lobby := cr.NewLobby()
+	alice := NewStructuredUser("Alice")
+	bot := cr.NewBot("Greeter")
+	bot.OnCommand(cr.Command{Name: "greet", Usage: "<name>"}, func(event *cr.BotEvent) error {
+		if len(event.Args) != 1 {
+			return cr.ErrUsage
+		}
+		return event.Reply("Hello, " + event.Args[0] + "! (asked by " + event.Message.Sender + ")")
+	})
+	var room *cr.ChatRoom
+	captureOutput(func() {
+		room, _ = lobby.CreateRoom("general", cr.RoomOptions{})
+		room.Join(alice)
+		room.Join(bot)
+		if err := room.RunCommand("/greet Bob", alice); err != nil {
+			t.Errorf("Expected /greet to run, got %v", err)
+		}
+		if err := room.RunCommand("/greet", alice); !errors.Is(err, cr.ErrUsage) {
+			t.Errorf("Expected ErrUsage, got %v", err)
+		}
+		room.Leave(bot)
+		if err := room.RunCommand("/greet Bob", alice); !errors.Is(err, cr.ErrUnknownCommand) {
+			t.Errorf("Expected /greet to leave with the bot, got %v", err)
+		}
+		room.Flush()
+	})
+
+	if got := said(alice); !slices.Equal(got, []string{"Hello, Bob! (asked by Alice)"}) {
+		t.Errorf("Expected the greeting, got %q", got)
+	}
+	if _, ok := room.Commands().Lookup("greet"); ok {
+		t.Errorf("Expected /greet to be unregistered")
+	}
+}
+
+func TestBot_CommandsAfterStop(t *testing.T) {
+	lobby := cr.NewLobby()
+	alice := NewStructuredUser("Alice")
+	bot := cr.NewBot("Greeter")
+	bot.OnCommand(cr.Command{Name: "greet"}, func(event *cr.BotEvent) error {
+		return event.Reply("Hello!")
+	})
+	var room *cr.ChatRoom
+	captureOutput(func() {
+		room, _ = lobby.CreateRoom("general", cr.RoomOptions{})
+		room.Join(alice)
+		room.Join(bot)
+	})
+
+	// Stop ends the schedules only; leaving still takes the commands along
+	bot.Stop()
+	if _, ok := room.Commands().Lookup("greet"); !ok {
+		t.Errorf("Expected /greet to stay while the bot is in the room")
+	}
+	captureOutput(func() { room.Leave(bot) })
+	if _, ok := room.Commands().Lookup("greet"); ok {
+		t.Errorf("Expected /greet to be unregistered when the bot left after Stop")
+	}
+	output := captureOutput(func() { room.Join(bot) })
+	if strings.Contains(output, "cannot add") {
+		t.Errorf("Expected the bot to register its commands again, got %q", output)
+	}
+	if _, ok := room.Commands().Lookup("greet"); !ok {
+		t.Errorf("Expected /greet to be back once the bot rejoined")
+	}
+	bot.Stop()
+}
+
+func TestBot_LeavesOtherCommandsAlone(t *testing.T) {
+	lobby := cr.NewLobby()
+	bot := cr.NewBot("Helper")
+	bot.OnCommand(cr.Command{Name: "help"}, func(event *cr.BotEvent) error { return nil })
+	var room *cr.ChatRoom
+	captureOutput(func() {
+		room, _ = lobby.CreateRoom("general", cr.RoomOptions{})
+		room.Join(bot) // /help is a built-in, so the bot cannot add its own
+		room.Leave(bot)
+	})
+	if _, ok := room.Commands().Lookup("help"); !ok {
+		t.Errorf("Expected the built-in /help to stay when the bot left")
+	}
+}
+
+func TestFlush_EndlessConversation(t *testing.T) {
+	lobby := cr.NewLobby()
+	var room *cr.ChatRoom
+	captureOutput(func() {
+		room, _ = lobby.CreateRoom("general", cr.RoomOptions{})
+		for _, names := range [][2]string{{"Ping", "pong"}, {"Pong", "ping"}} {
+			bot := cr.NewBot(names[0])
+			bot.SetBudget(0, 0)
+			bot.OnPattern("^"+names[1]+"$", func(event *cr.BotEvent) error {
+				return event.Reply(strings.ToLower(names[0]))
+			})
+			room.Join(bot)
+		}
+	})
+
+	flushed := make(chan struct{})
+	captureOutput(func() {
+		room.Post(cr.Message{Body: "ping"}, nil)
+		go func() {
+			room.Flush()
+			close(flushed)
+		}()
+		select {
+		case <-flushed:
+		case <-time.After(5 * time.Second):
+			t.Error("Expected Flush to give up on bots answering each other")
+		}
+		room.Close() // Refuses their next reply, which ends the conversation
+	})
+}
+
+func TestBot_Schedule(t *testing.T) {
+	lobby := cr.NewLobby()
+	alice := NewStructuredUser("Alice")
+	bot := cr.NewBot("Clock")
+	var ticks atomic.Int64
+	bot.Every(5*time.Millisecond, func(event *cr.BotEvent) error {
+		ticks.Add(1)
+		return nil
+	})
+	var room *cr.ChatRoom
+	captureOutput(func() {
+		room, _ = lobby.CreateRoom("general", cr.RoomOptions{})
+		room.Join(alice)
+		room.Join(bot)
+	})
+
+	if !waitFor(func() bool { return ticks.Load() >= 3 }) {
+		t.Fatalf("Expected the schedule to run, got %d ticks", ticks.Load())
+	}
+	captureOutput(func() { room.Leave(bot) })
+	bot.Stop() // Waits for a tick in progress
+	stopped := ticks.Load()
+	time.Sleep(20 * time.Millisecond)
+	if ticks.Load() != stopped {
+		t.Errorf("Expected the schedule to stop once the bot left")
+	}
+}
+
+func TestBot_ScheduleRejectsNonPositiveInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected Every(%v) to panic", interval)
+				}
+			}()
+			cr.NewBot("Clock").Every(interval, func(event *cr.BotEvent) error { return nil })
+		}()
+	}
+}
+
+func TestBot_Budget(t *testing.T) {
+	alice := NewStructuredUser("Alice")
+	bot := cr.NewBot("Chatty")
+	bot.SetBudget(2, 100*time.Millisecond)
+	var results []error
+	bot.OnPattern(`^ping$`, func(event *cr.BotEvent) error {
+		err := event.Reply("pong")
+		results = append(results, err) // Handlers of one room run in order
+		return err
+	})
+	room := cr.NewChatRoom()
+	captureOutput(func() {
+		room.Join(alice)
+		room.Join(bot)
+		for range 3 {
+			room.Post(cr.Message{Body: "ping"}, alice)
+		}
+		room.Flush()
+	})
+	if len(results) != 3 || results[0] != nil || results[1] != nil || !errors.Is(results[2], cr.ErrRateLimited) {
+		t.Errorf("Expected two replies and ErrRateLimited, got %v", results)
+	}
+
+	time.Sleep(60 * time.Millisecond) // Refills one message
+	captureOutput(func() {
+		room.Post(cr.Message{Body: "ping"}, alice)
+		room.Flush()
+	})
+	if len(results) != 4 || results[3] != nil {
+		t.Errorf("Expected the budget to refill, got %v", results)
+	}
+	if got := said(alice); len(got) != 3 {
+		t.Errorf("Expected 3 pongs, got %q", got)
+	}
+}
+
+func TestBotState(t *testing.T) {
+	bot := cr.NewBot("Counter")
+	state := bot.State()
+	for range 3 {
+		state.Update("count", func(value any, ok bool) any {
+			count, _ := value.(int)
+			return count + 1
+		})
+	}
+	if value, ok := state.Get("count"); !ok || value != 3 {
+		t.Errorf("Expected count 3, got %v", value)
+	}
+	state.Update("count", func(any, bool) any { return nil })
+	if _, ok := state.Get("count"); ok {
+		t.Errorf("Expected returning nil to delete the key")
+	}
+	state.Set("name", "x")
+	state.Delete("name")
+	if _, ok := state.Get("name"); ok {
+		t.Errorf("Expected Delete to remove the key")
+	}
+}
+
+func TestBot_IgnoresReplayedHistory(t *testing.T) {
+	history, err := cr.OpenHistory(t.TempDir() + "/general.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	alice := NewStructuredUser("Alice")
+	bot := cr.NewBot("Echo")
+	var heard []string
+	bot.OnPattern(`.`, func(event *cr.BotEvent) error {
+		heard = append(heard, event.Message.Body)
+		return nil
+	})
+	room := cr.NewChatRoom()
+	room.SetHistory(history, 10)
+	captureOutput(func() {
+		room.Join(alice)
+		room.Post(cr.Message{Body: "before"}, alice)
+		room.Join(bot)
+		room.Post(cr.Message{Body: "after"}, alice)
+		room.Flush()
+	})
+	if strings.Join(heard, ",") != "after" {
+		t.Errorf("Expected the bot to only hear [after], got %q", heard)
+	}
+}
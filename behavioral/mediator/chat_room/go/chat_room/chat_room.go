@@ -1,9 +1,29 @@
 package chat_room
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrUserExists is returned by Join when the name is already taken.
+	ErrUserExists = errors.New("user already in the chat room")
+	// ErrNotInRoom is returned when a user is not a member of the room.
+	ErrNotInRoom = errors.New("user not in the chat room")
+	// ErrWrongPassword is returned when joining a private room fails.
+	ErrWrongPassword = errors.New("wrong room password")
+	// ErrRoomFull is returned when a room has reached its member cap.
+	ErrRoomFull = errors.New("chat room is full")
+	// ErrRoomDeleted is returned when joining or posting to a room that was
+	// deleted.
+	ErrRoomDeleted = errors.New("chat room was deleted")
+	// ErrRoomClosed is returned when joining or posting to a room after Close.
+	ErrRoomClosed = errors.New("chat room is closed")
 )
 
 // ChatMediator defines the interface for the chat room mediator.
@@ -23,10 +43,41 @@ type User interface {
 
 // --- Concrete Mediator: ChatRoom ---
 
-// ChatRoom implements the ChatMediator interface.
+// ChatRoom implements the ChatMediator interface. Every member gets a
+// mailbox and a delivery goroutine, so SendMessage never waits for a slow
+// Receive; each member receives messages in the order they were sent.
 type ChatRoom struct {
-	users map[string]User
-	mutex sync.RWMutex // Use RWMutex for better performance if reads are frequent
+	users     map[string]User
+	mailboxes map[string]*mailbox
+	mutex     sync.RWMutex // Use RWMutex for better performance if reads are frequent
+
+	mailboxSize  int
+	overflow     OverflowPolicy
+	blockTimeout time.Duration
+	dropped      atomic.Int64
+	deliveries   sync.WaitGroup // Delivery goroutines, including those of departed members
+	closed       bool
+	lastID       atomic.Uint64 // ID of the last message posted
+	posting      sync.Mutex    // Keeps IDs in the order messages reach the history
+	history      *History
+	replay       int // Messages of the history replayed to new members
+	search       *SearchIndex
+
+	// Moderation, by member name, so it outlives leaving and rejoining
+	roles         map[string]Role
+	mutes         map[string]time.Time // End of each mute, zero if it does not expire
+	bans          map[string]time.Time // End of each ban, zero if it does not expire
+	filters       []Filter
+	moderationLog []ModerationEntry
+
+	commands *Commands // Slash commands run by RunCommand
+
+	// Set for rooms created by a Lobby
+	name       string
+	topic      string
+	password   string
+	maxMembers int // 0 means no cap
+	deleted    bool
 }
 
 // NewChatRoom creates a new ChatRoom instance.
@@ -34,95 +85,603 @@ func NewChatRoom() *ChatRoom {
 	// Initialize logger to behave like Python's print (no timestamp/prefix)
 	log.SetFlags(0)
 	return &ChatRoom{
-		users: make(map[string]User),
+		users:        make(map[string]User),
+		mailboxes:    make(map[string]*mailbox),
+		roles:        make(map[string]Role),
+		mutes:        make(map[string]time.Time),
+		bans:         make(map[string]time.Time),
+		commands:     DefaultCommands(),
+		mailboxSize:  DefaultMailboxSize,
+		blockTimeout: DefaultBlockTimeout,
 	}
 }
 
-// AddUser adds a user to the chat room.
-func (cr *ChatRoom) AddUser(user User) {
+// SetMailboxSize sets how many undelivered messages a member may have before
+// the overflow policy applies. It affects members who join afterwards.
+func (cr *ChatRoom) SetMailboxSize(size int) {
 	cr.mutex.Lock()
 	defer cr.mutex.Unlock()
+	cr.mailboxSize = size
+}
 
-	userName := user.GetName()
-	if _, exists := cr.users[userName]; !exists {
-		// Add user if not already present
-		cr.users[userName] = user
-		user.SetMediator(cr) // Set the mediator for the user
-		log.Printf("--- %s added to the chat room. ---", userName) // Match Python output
-	} else {
+// SetOverflowPolicy sets what happens when a mailbox is full, and how long
+// BlockWithTimeout waits. It affects members who join afterwards.
+func (cr *ChatRoom) SetOverflowPolicy(policy OverflowPolicy, timeout time.Duration) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.overflow = policy
+	cr.blockTimeout = timeout
+}
+
+// SetHistory makes the room record its messages in history and replay the
+// last replay messages to members who join. Message IDs continue from the
+// newest message in the history.
+func (cr *ChatRoom) SetHistory(history *History, replay int) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.history = history
+	cr.replay = replay
+	if last := history.LastID(); last > cr.lastID.Load() {
+		cr.lastID.Store(last)
+	}
+}
+
+// SetSearchIndex makes the room add every message it posts to index. The
+// messages already in the history of the room are indexed first.
+func (cr *ChatRoom) SetSearchIndex(index *SearchIndex) error {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	if cr.history != nil {
+		if err := index.AddHistory(cr.history); err != nil {
+			return err
+		}
+	}
+	cr.search = index
+	return nil
+}
+
+// searchSource is what the search index tells the room's messages apart
+// by: its history, which AddHistory indexes too, or else the room itself.
+// Callers must hold the lock.
+func (cr *ChatRoom) searchSource() any {
+	if cr.history != nil {
+		return cr.history
+	}
+	return cr
+}
+
+// History returns the history of the room, or nil if it keeps none.
+func (cr *ChatRoom) History() *History {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	return cr.history
+}
+
+// Dropped returns how many messages were discarded because of full mailboxes.
+func (cr *ChatRoom) Dropped() int {
+	return int(cr.dropped.Load())
+}
+
+// Name returns the name of the room, empty unless it was created by a Lobby.
+func (cr *ChatRoom) Name() string {
+	return cr.name
+}
+
+// Topic returns the topic of the room.
+func (cr *ChatRoom) Topic() string {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	return cr.topic
+}
+
+// SetTopic changes the topic of the room.
+func (cr *ChatRoom) SetTopic(topic string) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.topic = topic
+}
+
+// Members returns the names of the users in the room, sorted.
+func (cr *ChatRoom) Members() []string {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	names := make([]string, 0, len(cr.users))
+	for name := range cr.users {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// label names the room in log messages
+func (cr *ChatRoom) label() string {
+	if cr.name == "" {
+		return "the chat room"
+	}
+	return "room " + cr.name
+}
+
+// AddUser adds a user to the chat room.
+func (cr *ChatRoom) AddUser(user User) {
+	if err := cr.Join(user); err != nil {
 		// Log if the user is already present
-		log.Printf("--- %s is already in the chat room. Cannot add again. ---", userName) // Match Python output
+		log.Printf("--- %s is already in the chat room. Cannot add again. ---", user.GetName()) // Match Python output
+	}
+}
+
+// Join adds a user to the chat room, failing with ErrUserExists when the
+// name is taken.
+func (cr *ChatRoom) Join(user User) error {
+	return cr.Enter(user, "")
+}
+
+// Enter adds a user to the chat room if the password matches the one of the
+// room and the room is not full. Members implementing RoomObserver are told
+// about the new member.
+func (cr *ChatRoom) Enter(user User, password string) error {
+	userName := user.GetName()
+	backlog, upTo := cr.backlog(userName)
+	cr.mutex.Lock()
+
+	switch _, exists := cr.users[userName]; {
+	case cr.deleted:
+		cr.mutex.Unlock()
+		return ErrRoomDeleted
+	case cr.closed:
+		cr.mutex.Unlock()
+		return ErrRoomClosed
+	case exists:
+		cr.mutex.Unlock()
+		return ErrUserExists
+	case cr.banned(userName):
+		cr.mutex.Unlock()
+		return ErrBanned
+	case password != cr.password:
+		cr.mutex.Unlock()
+		return ErrWrongPassword
+	case cr.maxMembers > 0 && len(cr.users) >= cr.maxMembers:
+		cr.mutex.Unlock()
+		return ErrRoomFull
+	}
+	cr.users[userName] = user
+	box := newMailbox(user, cr.mailboxSize, cr.overflow, cr.blockTimeout, &cr.dropped, &cr.deliveries)
+	cr.mailboxes[userName] = box
+	user.SetMediator(cr)                                        // Set the mediator for the user
+	log.Printf("--- %s added to %s. ---", userName, cr.label()) // Match Python output
+	// Still under the lock, so no message is both replayed and delivered
+	cr.replayTo(box, userName, backlog, upTo)
+	observers := cr.observers()
+	cr.mutex.Unlock()
+
+	notify(observers, RoomEvent{Kind: MemberJoined, Room: cr.name, User: userName})
+	return nil
+}
+
+// backlog reads the last messages of the history that a new member may
+// see, oldest first, and the ID of the last message posted when it did. It
+// reads without holding the lock, so a slow disk doesn't hold up the room.
+func (cr *ChatRoom) backlog(userName string) ([]Message, uint64) {
+	cr.mutex.RLock()
+	// Replaying must not overflow the mailbox
+	history, limit := cr.history, min(cr.replay, cr.mailboxSize)
+	// Under the posting lock, every message up to upTo has reached the history
+	cr.posting.Lock()
+	upTo := cr.lastID.Load()
+	cr.posting.Unlock()
+	cr.mutex.RUnlock()
+	if history == nil || limit <= 0 {
+		return nil, upTo
+	}
+
+	var replay []Message
+	before := upTo + 1
+	for len(replay) < limit {
+		page, err := history.Before(before, limit)
+		if err != nil || len(page) == 0 {
+			break
+		}
+		before = page[0].ID
+		for i := len(page) - 1; i >= 0 && len(replay) < limit; i-- {
+			if page[i].VisibleTo(userName) {
+				replay = append(replay, page[i])
+			}
+		}
+	}
+	slices.Reverse(replay)
+	return replay, upTo
+}
+
+// replayTo queues the backlog to a new member, with the messages posted
+// since it was read. The caller holds the lock.
+func (cr *ChatRoom) replayTo(box *mailbox, userName string, backlog []Message, upTo uint64) {
+	limit := min(cr.replay, cr.mailboxSize)
+	if cr.history == nil || limit <= 0 {
+		return
+	}
+	// Few messages are posted meanwhile, and those are in the tail of the history
+	for upTo < cr.lastID.Load() {
+		page, err := cr.history.After(upTo, limit)
+		if err != nil || len(page) == 0 {
+			break
+		}
+		upTo = page[len(page)-1].ID
+		for _, msg := range page {
+			if msg.VisibleTo(userName) {
+				backlog = append(backlog, msg)
+			}
+		}
+		backlog = backlog[max(len(backlog)-limit, 0):]
+	}
+	for _, msg := range backlog {
+		msg.Replayed = true
+		msg.Mentioned = msg.MentionsUser(userName)
+		box.put(msg)
 	}
 }
 
 // RemoveUser removes a user from the chat room.
 func (cr *ChatRoom) RemoveUser(user User) {
+	if err := cr.Leave(user); err != nil {
+		// Log if the user is not found
+		log.Printf("--- %s is not in %s. Cannot remove. ---", user.GetName(), cr.label()) // Match Python output
+	}
+}
+
+// Leave removes a user from the chat room, failing with ErrNotInRoom when
+// the user is not a member. The remaining members and the user itself are
+// told if they implement RoomObserver.
+func (cr *ChatRoom) Leave(user User) error {
+	return cr.leave(user.GetName(), nil)
+}
+
+// leave removes a member by name. Given a mailbox, it only does so while
+// the member still owns it, so whoever rejoined under the name stays.
+func (cr *ChatRoom) leave(userName string, box *mailbox) error {
+	cr.mutex.Lock()
+
+	member, exists := cr.users[userName]
+	if !exists || (box != nil && cr.mailboxes[userName] != box) {
+		cr.mutex.Unlock()
+		return ErrNotInRoom
+	}
+	delete(cr.users, userName)
+	cr.mailboxes[userName].close() // Messages already queued are still delivered
+	delete(cr.mailboxes, userName)
+	log.Printf("--- %s removed from %s. ---", userName, cr.label()) // Match Python output
+	observers := append(cr.observers(), asObservers(member)...)
+	cr.mutex.Unlock()
+
+	notify(observers, RoomEvent{Kind: MemberLeft, Room: cr.name, User: userName})
+	return nil
+}
+
+// remove empties a deleted room and returns its former members
+func (cr *ChatRoom) remove() []RoomObserver {
 	cr.mutex.Lock()
 	defer cr.mutex.Unlock()
+	observers := cr.observers()
+	for _, box := range cr.mailboxes {
+		box.close()
+	}
+	cr.users = make(map[string]User)
+	cr.mailboxes = make(map[string]*mailbox)
+	cr.deleted = true
+	return observers
+}
 
-	userName := user.GetName()
-	if _, exists := cr.users[userName]; exists {
-		delete(cr.users, userName)
-		log.Printf("--- %s removed from the chat room. ---", userName) // Match Python output
-	} else {
-		// Log if the user is not found
-		log.Printf("--- %s is not in the chat room. Cannot remove. ---", userName) // Match Python output
+// observers returns the members that want room events. The caller holds
+// the lock.
+func (cr *ChatRoom) observers() []RoomObserver {
+	var observers []RoomObserver
+	for _, user := range cr.users {
+		observers = append(observers, asObservers(user)...)
 	}
+	return observers
 }
 
-// SendMessage broadcasts a message to all users except the sender.
+// SendMessage broadcasts a message to all users except the sender. It
+// returns once the message is queued in their mailboxes; members whose
+// mailbox overflows under the Disconnect policy are removed from the room.
 func (cr *ChatRoom) SendMessage(message string, sender User) {
+	cr.Post(Message{Kind: ChatMessage, Body: message}, sender)
+}
+
+// Announce sends a system message to every member.
+func (cr *ChatRoom) Announce(body string) error {
+	return cr.Post(Message{Kind: SystemMessage, Body: body}, nil)
+}
+
+// refusing returns why the room takes no more messages, if it doesn't. The
+// caller holds the lock.
+func (cr *ChatRoom) refusing() error {
+	switch {
+	case cr.deleted:
+		return ErrRoomDeleted
+	case cr.closed:
+		return ErrRoomClosed
+	}
+	return nil
+}
+
+// Post routes a structured message: a direct message goes to its recipient
+// only, anything else to every member but the sender. The sender is nil for
+// system messages. Post fills in the ID, timestamp, room, sender and
+// mentions of the message.
+func (cr *ChatRoom) Post(msg Message, sender User) error {
+	if sender != nil {
+		msg.Sender = sender.GetName()
+	}
+	if msg.Kind == DirectMessage && msg.To == "" {
+		return ErrNoRecipient
+	}
+
+	if sender != nil {
+		cr.mutex.RLock()
+		err := cr.refusing()
+		cr.mutex.RUnlock()
+		if err != nil {
+			return err
+		}
+		screened, err := cr.screen(msg)
+		if err != nil {
+			cr.refuse(msg, err)
+			return err
+		}
+		msg = screened
+	}
+
 	cr.mutex.RLock() // Use RLock for reading the user map
-	defer cr.mutex.RUnlock()
+	if err := cr.refusing(); err != nil {
+		cr.mutex.RUnlock()
+		return err
+	}
+	var recipients []*mailbox
+	if msg.To != "" {
+		box, ok := cr.mailboxes[msg.To]
+		if !ok {
+			cr.mutex.RUnlock()
+			return ErrNotInRoom
+		}
+		recipients = append(recipients, box)
+	} else {
+		for name, box := range cr.mailboxes {
+			// Ensure we don't send the message back to the sender
+			if name != msg.Sender {
+				recipients = append(recipients, box)
+			}
+		}
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	msg.Room = cr.name
+	msg.Mentions = ParseMentions(msg.Body)
+	msg.Mentioned, msg.Replayed = false, false
 
-	senderName := sender.GetName()
-	// Log sender and message here, similar to Python
-	log.Printf("--- %s sends message: '%s' ---", senderName, message)
+	var historyErr error
+	cr.posting.Lock()
+	msg.ID = cr.lastID.Add(1)
+	if cr.history != nil {
+		historyErr = cr.history.Append(msg)
+	}
+	if cr.search != nil {
+		cr.search.add(cr.searchSource(), msg)
+	}
+	cr.posting.Unlock()
+	logPost(msg)
+	// Queue without the lock, so a blocking mailbox can't hold up joins
+	cr.mutex.RUnlock()
+
+	for _, box := range recipients {
+		personal := msg
+		personal.Mentioned = msg.MentionsUser(box.user.GetName())
+		if err := box.put(personal); err != nil {
+			if cr.leave(box.user.GetName(), box) == nil {
+				log.Printf("--- %s disconnected from %s: %v. ---", box.user.GetName(), cr.label(), err)
+			}
+		}
+	}
+	// The message was delivered even if it could not be recorded
+	return historyErr
+}
 
-	for name, user := range cr.users {
-		// Ensure we don't send the message back to the sender
-		if name != senderName {
-			user.Receive(message, senderName) // Send synchronously
+// logPost logs a message the way SendMessage always has
+func logPost(msg Message) {
+	switch msg.Kind {
+	case ChatMessage:
+		// Log sender and message here, similar to Python
+		log.Printf("--- %s sends message: '%s' ---", msg.Sender, msg.Body)
+	case SystemMessage:
+		if msg.To != "" {
+			log.Printf("--- System message to %s: '%s' ---", msg.To, msg.Body)
+			return
 		}
+		log.Printf("--- System message: '%s' ---", msg.Body)
+	case ActionMessage:
+		log.Printf("--- %s ---", msg.Text())
+	case DirectMessage:
+		log.Printf("--- %s sends a direct message to %s. ---", msg.Sender, msg.To)
 	}
 }
 
+// maxFlushRounds bounds how many rounds of replies Flush waits for
+const maxFlushRounds = 32
+
+// Flush waits until the messages queued for the current members have been
+// delivered, including those members such as bots post in response. It
+// stops after maxFlushRounds rounds of replies, so members answering each
+// other forever cannot hold it up.
+func (cr *ChatRoom) Flush() {
+	for round, busy := 0, true; busy && round < maxFlushRounds; round++ {
+		cr.mutex.RLock()
+		boxes := make([]*mailbox, 0, len(cr.mailboxes))
+		for _, box := range cr.mailboxes {
+			boxes = append(boxes, box)
+		}
+		cr.mutex.RUnlock()
+
+		busy = false
+		for _, box := range boxes {
+			if box.flush() {
+				busy = true // A delivery may have queued more messages
+			}
+		}
+	}
+}
+
+// Close stops the room: later messages and joins are refused, and Close
+// returns once every mailbox, including those of members who already left,
+// has been drained. Members implementing RoomObserver are then told that
+// the room was closed.
+func (cr *ChatRoom) Close() error {
+	cr.mutex.Lock()
+	var observers []RoomObserver
+	if !cr.closed {
+		observers = cr.observers()
+	}
+	cr.closed = true
+	for _, box := range cr.mailboxes {
+		box.close()
+	}
+	cr.mutex.Unlock()
+
+	cr.deliveries.Wait()
+	notify(observers, RoomEvent{Kind: RoomClosed, Room: cr.name})
+	return nil
+}
+
 // --- Concrete Colleague: ChatUser ---
 
 // ChatUser implements the User interface.
 type ChatUser struct {
-	mediator ChatMediator
+	mediator ChatMediator            // Room that Send talks to: the last one joined
+	rooms    map[string]ChatMediator // Named rooms the user is in, for SendTo
 	name     string
+	mutex    sync.Mutex
 }
 
 // NewChatUser creates a new ChatUser instance.
 func NewChatUser(name string) *ChatUser {
 	return &ChatUser{
-		name: name,
+		name:  name,
+		rooms: make(map[string]ChatMediator),
 	}
 }
 
 // SetMediator sets the mediator for the user. Required by ChatRoom.AddUser.
 func (cu *ChatUser) SetMediator(mediator ChatMediator) {
+	cu.mutex.Lock()
+	defer cu.mutex.Unlock()
 	cu.mediator = mediator
+	if name := roomName(mediator); name != "" {
+		cu.rooms[name] = mediator
+	}
+}
+
+// roomName returns the name of a named room, or an empty string
+func roomName(mediator ChatMediator) string {
+	if room, ok := mediator.(interface{ Name() string }); ok {
+		return room.Name()
+	}
+	return ""
+}
+
+// OnRoomEvent forgets a room once the user left it or it was deleted or
+// closed.
+func (cu *ChatUser) OnRoomEvent(event RoomEvent) {
+	if event.Kind == MemberJoined || (event.Kind == MemberLeft && event.User != cu.GetName()) {
+		return
+	}
+	cu.mutex.Lock()
+	defer cu.mutex.Unlock()
+	if room, ok := cu.rooms[event.Room]; ok {
+		delete(cu.rooms, event.Room)
+		if cu.mediator == room {
+			cu.mediator = nil
+		}
+	} else if event.Room == "" && cu.mediator != nil && roomName(cu.mediator) == "" {
+		cu.mediator = nil // Left an unnamed room
+	}
+}
+
+// Rooms returns the names of the named rooms the user is in, sorted.
+func (cu *ChatUser) Rooms() []string {
+	cu.mutex.Lock()
+	defer cu.mutex.Unlock()
+	names := make([]string, 0, len(cu.rooms))
+	for name := range cu.rooms {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// SendTo sends a message to one of the named rooms the user is in.
+func (cu *ChatUser) SendTo(room string, message string) error {
+	cu.mutex.Lock()
+	mediator, ok := cu.rooms[room]
+	cu.mutex.Unlock()
+	if !ok {
+		return ErrNotInRoom
+	}
+	Dispatch(mediator, message, cu)
+	return nil
 }
 
 // GetName returns the user's name.
 func (cu *ChatUser) GetName() string {
+	cu.mutex.Lock()
+	defer cu.mutex.Unlock()
 	return cu.name
 }
 
+// SetName changes the user's name. Required by ChatRoom.Rename.
+func (cu *ChatUser) SetName(name string) {
+	cu.mutex.Lock()
+	defer cu.mutex.Unlock()
+	cu.name = name
+}
+
 // Send sends a message via the mediator.
 func (cu *ChatUser) Send(message string) {
-	if cu.mediator == nil {
+	cu.mutex.Lock()
+	mediator := cu.mediator
+	cu.mutex.Unlock()
+	if mediator == nil {
 		// Use fmt.Printf here as log might not be configured in all contexts
 		// Match python's implicit behavior (no output if mediator not set) or add specific error handling if desired
 		// For now, let's keep it simple and just return if no mediator
 		// fmt.Printf("'%s' cannot send message: Mediator not set.\n", cu.name) // Optional: uncomment for debugging
 		return
 	}
-	// The mediator now logs the sending action in SendMessage
-	cu.mediator.SendMessage(message, cu)
+	// The mediator now logs the sending action in SendMessage; commands
+	// like "/who" are run instead of sent
+	Dispatch(mediator, message, cu)
+}
+
+// SendDirect sends a direct message to another member of the current room.
+func (cu *ChatUser) SendDirect(to string, message string) error {
+	return cu.post(Message{Kind: DirectMessage, To: to, Body: message})
+}
+
+// SendAction tells the current room what the user does, like "/me waves".
+func (cu *ChatUser) SendAction(action string) error {
+	return cu.post(Message{Kind: ActionMessage, Body: action})
+}
+
+// post sends a structured message to the current room
+func (cu *ChatUser) post(msg Message) error {
+	cu.mutex.Lock()
+	mediator := cu.mediator
+	cu.mutex.Unlock()
+	if mediator == nil {
+		return ErrNotInRoom
+	}
+	messages, ok := mediator.(MessageMediator)
+	if !ok {
+		return fmt.Errorf("%s messages are not supported by the mediator", msg.Kind)
+	}
+	return messages.Post(msg, cu)
 }
 
 // Receive receives a message from the mediator.
@@ -130,8 +689,27 @@ func (u *ChatUser) Receive(message string, senderName string) {
 	u.logReceive(message, senderName)
 }
 
+// ReceiveMessage receives a structured message from the mediator.
+func (u *ChatUser) ReceiveMessage(msg Message) {
+	name := u.GetName()
+	mention := ""
+	if msg.Mentioned {
+		mention = " [mentioned]"
+	}
+	switch msg.Kind {
+	case SystemMessage:
+		fmt.Printf("%s received system message: %s\n", name, msg.Body)
+	case ActionMessage:
+		fmt.Printf("%s sees: %s%s\n", name, msg.Text(), mention)
+	case DirectMessage:
+		fmt.Printf("%s received direct message: %s (from %s)%s\n", name, msg.Body, msg.Sender, mention)
+	default:
+		fmt.Printf("%s received: %s (from %s)%s\n", name, msg.Body, msg.Sender, mention)
+	}
+}
+
 // logReceive handles the actual logging for Receive.
 func (u *ChatUser) logReceive(message string, senderName string) {
 	// Match Python output format exactly using fmt.Printf for direct stdout
-	fmt.Printf("%s received: %s (from %s)\n", u.name, message, senderName)
+	fmt.Printf("%s received: %s (from %s)\n", u.GetName(), message, senderName)
 }
@@ -181,6 +181,7 @@ func TestChatRoom_RemoveUser(t *testing.T) {
 	msg := "Are you still there Alice?"
 	captureOutput(func() {
 		 user2.Send(msg)
+		 mediator.Flush()
 	})
 
 	if user1.ReceiveCallCount() > 0 {
@@ -224,6 +225,7 @@ func TestChatRoom_SendMessage(t *testing.T) {
 	message := "Hello Team!"
 	captureOutput(func() {
 		alice.Send(message) // Alice sends a message
+		mediator.Flush()    // Wait for the mailboxes to deliver it
 	})
 
 	// Check if Bob and Charlie received the message, but Alice didn't
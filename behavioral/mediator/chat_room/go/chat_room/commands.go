@@ -0,0 +1,349 @@
+package chat_room
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var (
+	// ErrUnknownCommand is returned when no command of that name is
+	// registered.
+	ErrUnknownCommand = errors.New("unknown command")
+	// ErrCommandExists is returned when registering a command name twice.
+	ErrCommandExists = errors.New("command already registered")
+	// ErrUsage is returned by command handlers given the wrong arguments; the
+	// sender is shown the usage of the command.
+	ErrUsage = errors.New("wrong command arguments")
+	// ErrInvalidName is returned when renaming a member to an unusable name.
+	ErrInvalidName = errors.New("invalid name")
+	// ErrRenameUnsupported is returned when renaming a user that does not
+	// implement Renamer.
+	ErrRenameUnsupported = errors.New("user cannot be renamed")
+)
+
+// CommandHandler runs a command sent by a member of room. args are the
+// words following the command name.
+type CommandHandler func(room *ChatRoom, sender User, args []string) error
+
+// Command is a slash command, like "/msg Bob hello".
+type Command struct {
+	Name    string // Without the slash; matched ignoring case
+	Usage   string // Arguments, like "<name> <message>"
+	Help    string // One-line description for /help
+	Role    Role   // Lowest role allowed to run the command
+	Handler CommandHandler
+}
+
+// Commands is a registry of slash commands. It is safe for concurrent use
+// and may be shared by several rooms.
+type Commands struct {
+	mutex    sync.RWMutex
+	commands map[string]Command
+}
+
+// NewCommands creates an empty registry.
+func NewCommands() *Commands {
+	return &Commands{commands: make(map[string]Command)}
+}
+
+// DefaultCommands creates a registry holding the built-in commands: /help,
+// /me, /msg, /nick, /topic and /who.
+func DefaultCommands() *Commands {
+	commands := NewCommands()
+	for _, cmd := range builtinCommands() {
+		commands.Register(cmd)
+	}
+	return commands
+}
+
+// Register adds a command. The name must not be taken.
+func (c *Commands) Register(cmd Command) error {
+	cmd.Name = strings.ToLower(cmd.Name)
+	if cmd.Name == "" || strings.ContainsFunc(cmd.Name, unicode.IsSpace) || cmd.Handler == nil {
+		return fmt.Errorf("command %q needs a name without spaces and a handler", cmd.Name)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, exists := c.commands[cmd.Name]; exists {
+		return ErrCommandExists
+	}
+	c.commands[cmd.Name] = cmd
+	return nil
+}
+
+// Unregister removes a command, so that it can be replaced.
+func (c *Commands) Unregister(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.commands, strings.ToLower(name))
+}
+
+// Lookup returns the command of that name.
+func (c *Commands) Lookup(name string) (Command, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	cmd, ok := c.commands[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// List returns the registered commands, sorted by name.
+func (c *Commands) List() []Command {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	list := make([]Command, 0, len(c.commands))
+	for _, cmd := range c.commands {
+		list = append(list, cmd)
+	}
+	slices.SortFunc(list, func(a, b Command) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
+
+// CommandRunner is implemented by mediators that run slash commands.
+type CommandRunner interface {
+	RunCommand(line string, sender User) error
+}
+
+// IsCommand reports whether a line typed by a user is a slash command. A
+// line starting with "//" is an escaped message starting with "/".
+func IsCommand(line string) bool {
+	return len(line) > 1 && line[0] == '/' && line[1] != '/'
+}
+
+// ParseCommand splits a command line into the command name, without the
+// slash, and its arguments.
+func ParseCommand(line string) (name string, args []string) {
+	fields := strings.Fields(strings.TrimPrefix(line, "/"))
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return strings.ToLower(fields[0]), fields[1:]
+}
+
+// Dispatch handles a line typed by a user: commands go to the mediator if
+// it is a CommandRunner, anything else is sent as a message.
+func Dispatch(mediator ChatMediator, line string, sender User) {
+	if runner, ok := mediator.(CommandRunner); ok {
+		if IsCommand(line) {
+			runner.RunCommand(line, sender)
+			return
+		}
+		line = strings.TrimPrefix(line, "/") // "//path" sends "/path"
+	}
+	mediator.SendMessage(line, sender)
+}
+
+// --- Commands of a ChatRoom ---
+
+// Commands returns the registry of the room, where teams register their
+// own commands.
+func (cr *ChatRoom) Commands() *Commands {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	return cr.commands
+}
+
+// SetCommands replaces the registry of the room, for instance with one
+// shared by several rooms.
+func (cr *ChatRoom) SetCommands(commands *Commands) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.commands = commands
+}
+
+// RunCommand runs a command line from a member. Nothing is broadcast:
+// unknown commands, missing permissions and failures are explained to the
+// sender in a private system message, and returned.
+func (cr *ChatRoom) RunCommand(line string, sender User) error {
+	name, args := ParseCommand(line)
+	cmd, ok := cr.Commands().Lookup(name)
+	if !ok {
+		cr.tell(sender.GetName(), fmt.Sprintf("Unknown command /%s. Type /help for the list of commands.", name))
+		return fmt.Errorf("%w: /%s", ErrUnknownCommand, name)
+	}
+
+	cr.mutex.RLock()
+	role := cr.roleOf(sender)
+	cr.mutex.RUnlock()
+	if role < Member {
+		return ErrNotInRoom
+	}
+	if role < cmd.Role {
+		cr.tell(sender.GetName(), fmt.Sprintf("You need to be %s to use /%s.", cmd.Role, cmd.Name))
+		return ErrPermissionDenied
+	}
+
+	log.Printf("--- %s runs /%s in %s. ---", sender.GetName(), cmd.Name, cr.label())
+	err := cmd.Handler(cr, sender, args)
+	var blocked *BlockedError
+	switch {
+	case err == nil, errors.Is(err, ErrMuted), errors.As(err, &blocked):
+		// Blocked messages were already explained
+	case errors.Is(err, ErrUsage):
+		cr.tell(sender.GetName(), strings.TrimSpace(fmt.Sprintf("Usage: /%s %s", cmd.Name, cmd.Usage)))
+	default:
+		cr.tell(sender.GetName(), fmt.Sprintf("/%s failed: %v.", cmd.Name, err))
+	}
+	return err
+}
+
+// Renamer is implemented by users whose name can change, for /nick.
+type Renamer interface {
+	SetName(name string)
+}
+
+// Rename changes the name of a member, which must implement Renamer. The
+// role and mute of the member follow the new name; names that are taken or
+// banned are refused.
+func (cr *ChatRoom) Rename(user User, name string) error {
+	renamer, ok := user.(Renamer)
+	if !ok {
+		return ErrRenameUnsupported
+	}
+	if name == "" || strings.ContainsFunc(name, unicode.IsSpace) || strings.ContainsAny(name[:1], "/@") {
+		return ErrInvalidName
+	}
+
+	cr.mutex.Lock()
+	old := user.GetName()
+	_, member := cr.users[old]
+	_, taken := cr.users[name]
+	switch {
+	case !member:
+		cr.mutex.Unlock()
+		return ErrNotInRoom
+	case taken:
+		cr.mutex.Unlock()
+		return ErrUserExists
+	case cr.banned(name):
+		cr.mutex.Unlock()
+		return ErrBanned
+	}
+	cr.users[name] = user
+	delete(cr.users, old)
+	cr.mailboxes[name] = cr.mailboxes[old]
+	delete(cr.mailboxes, old)
+	if role, ok := cr.roles[old]; ok {
+		cr.roles[name] = role
+		delete(cr.roles, old)
+	}
+	if end, ok := cr.mutes[old]; ok {
+		cr.mutes[name] = end
+		delete(cr.mutes, old)
+	}
+	renamer.SetName(name)
+	log.Printf("--- %s is now known as %s in %s. ---", old, name, cr.label())
+	cr.mutex.Unlock()
+
+	cr.Announce(fmt.Sprintf("%s is now known as %s.", old, name))
+	return nil
+}
+
+// builtinCommands returns the commands of DefaultCommands
+func builtinCommands() []Command {
+	return []Command{
+		{Name: "help", Usage: "[command]", Help: "List the commands, or explain one", Handler: runHelp},
+		{Name: "me", Usage: "<action>", Help: "Describe what you do", Handler: runMe},
+		{Name: "msg", Usage: "<name> <message>", Help: "Send a direct message", Handler: runMsg},
+		{Name: "nick", Usage: "<name>", Help: "Change your name", Handler: runNick},
+		{Name: "topic", Usage: "<topic>", Help: "Change the topic of the room", Role: Moderator, Handler: runTopic},
+		{Name: "who", Help: "List the members of the room", Handler: runWho},
+	}
+}
+
+func runHelp(room *ChatRoom, sender User, args []string) error {
+	if len(args) > 1 {
+		return ErrUsage
+	}
+	if len(args) == 1 {
+		name := strings.TrimPrefix(args[0], "/")
+		cmd, ok := room.Commands().Lookup(name)
+		if !ok {
+			return fmt.Errorf("%w: /%s", ErrUnknownCommand, name)
+		}
+		room.tell(sender.GetName(), describe(cmd))
+		return nil
+	}
+
+	role := room.Role(sender.GetName())
+	lines := []string{"Commands:"}
+	for _, cmd := range room.Commands().List() {
+		if cmd.Role <= role {
+			lines = append(lines, describe(cmd))
+		}
+	}
+	room.tell(sender.GetName(), strings.Join(lines, "\n"))
+	return nil
+}
+
+// describe renders a command for /help
+func describe(cmd Command) string {
+	line := "/" + cmd.Name
+	if cmd.Usage != "" {
+		line += " " + cmd.Usage
+	}
+	if cmd.Help != "" {
+		line += " - " + cmd.Help
+	}
+	if cmd.Role > Member {
+		line += " (" + cmd.Role.String() + ")"
+	}
+	return line
+}
+
+func runMe(room *ChatRoom, sender User, args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	return room.Post(Message{Kind: ActionMessage, Body: strings.Join(args, " ")}, sender)
+}
+
+func runMsg(room *ChatRoom, sender User, args []string) error {
+	if len(args) < 2 {
+		return ErrUsage
+	}
+	return room.Post(Message{Kind: DirectMessage, To: args[0], Body: strings.Join(args[1:], " ")}, sender)
+}
+
+func runNick(room *ChatRoom, sender User, args []string) error {
+	if len(args) != 1 {
+		return ErrUsage
+	}
+	// A user has one name for all its rooms, and the other rooms would not
+	// know about the change
+	if member, ok := sender.(interface{ Rooms() []string }); ok && len(member.Rooms()) > 1 {
+		return errors.New("leave your other rooms before changing your name")
+	}
+	return room.Rename(sender, args[0])
+}
+
+func runTopic(room *ChatRoom, sender User, args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	topic := strings.Join(args, " ")
+	room.SetTopic(topic)
+	return room.Announce(fmt.Sprintf("%s changed the topic to: %s", sender.GetName(), topic))
+}
+
+func runWho(room *ChatRoom, sender User, args []string) error {
+	if len(args) != 0 {
+		return ErrUsage
+	}
+	members := room.Members()
+	for i, name := range members {
+		if role := room.Role(name); role > Member {
+			members[i] += " (" + role.String() + ")"
+		}
+	}
+	text := fmt.Sprintf("%d in the room: %s.", len(members), strings.Join(members, ", "))
+	if topic := room.Topic(); topic != "" {
+		text = "Topic: " + topic + "\n" + text
+	}
+	room.tell(sender.GetName(), text)
+	return nil
+}
@@ -0,0 +1,250 @@
+package chat_room_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	cr "mediator_pattern_chat_room_go/chat_room"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		line    string
+		command bool
+		name    string
+		args    []string
+	}{
+		{"/who", true, "who", nil},
+		{"/MSG  Bob   hello there ", true, "msg", []string{"Bob", "hello", "there"}},
+		{"//etc/hosts", false, "", nil},
+		{"/", false, "", nil},
+		{"hello /who", false, "", nil},
+	}
+	for _, tt := range tests {
+		if got := cr.IsCommand(tt.line); got != tt.command {
+			t.Errorf("IsCommand(%q): expected %t, got %t", tt.line, tt.command, got)
+		}
+		if !tt.command {
+			continue
+		}
+		name, args := cr.ParseCommand(tt.line)
+		if name != tt.name || !slices.Equal(args, tt.args) {
+			t.Errorf("ParseCommand(%q): expected %q %q, got %q %q", tt.line, tt.name, tt.args, name, args)
+		}
+	}
+}
+
+func TestChatUser_SendRunsCommands(t *testing.T) {
+	lobby := cr.NewLobby()
+	alice := cr.NewChatUser("Alice")
+	bob := NewStructuredUser("Bob")
+	output := captureOutput(func() {
+		room, _ := lobby.CreateRoom("general", cr.RoomOptions{Topic: "Patterns"})
+		room.Join(alice)
+		room.Join(bob)
+		alice.Send("/who")
+		alice.Send("/me waves")
+		alice.Send("//etc/hosts is a path")
+		room.Flush()
+	})
+
+	if !strings.Contains(output, "Alice received system message: Topic: Patterns\n2 in the room: Alice, Bob.") {
+		t.Errorf("Expected Alice to get the member list, got output:\n%s", output)
+	}
+	received := bob.Received()
+	if len(received) != 2 {
+		t.Fatalf("Expected Bob to receive 2 messages, got %+v", received)
+	}
+	if received[0].Kind != cr.ActionMessage || received[0].Body != "waves" {
+		t.Errorf("Expected /me to send an action, got %+v", received[0])
+	}
+	if received[1].Kind != cr.ChatMessage || received[1].Body != "/etc/hosts is a path" {
+		t.Errorf("Expected // to send the rest as a message, got %+v", received[1])
+	}
+}
+
+func TestChatRoom_RunCommand(t *testing.T) {
+	room, owner, _, bob, carol := moderatedRoom(t)
+
+	captureOutput(func() {
+		if err := room.RunCommand("/msg Carol psst, over here", bob); err != nil {
+			t.Errorf("Expected /msg to succeed, got %v", err)
+		}
+		if err := room.RunCommand("/dance", bob); !errors.Is(err, cr.ErrUnknownCommand) {
+			t.Errorf("Expected ErrUnknownCommand, got %v", err)
+		}
+		if err := room.RunCommand("/topic New topic", bob); !errors.Is(err, cr.ErrPermissionDenied) {
+			t.Errorf("Expected a member not to set the topic, got %v", err)
+		}
+		if err := room.RunCommand("/msg Carol", bob); !errors.Is(err, cr.ErrUsage) {
+			t.Errorf("Expected ErrUsage, got %v", err)
+		}
+		if err := room.RunCommand("/msg Nobody hi", bob); !errors.Is(err, cr.ErrNotInRoom) {
+			t.Errorf("Expected ErrNotInRoom for an absent recipient, got %v", err)
+		}
+		if err := room.RunCommand("/topic Design patterns", owner); err != nil {
+			t.Errorf("Expected the owner to set the topic, got %v", err)
+		}
+		if err := room.RunCommand("/who", cr.NewChatUser("Eve")); !errors.Is(err, cr.ErrNotInRoom) {
+			t.Errorf("Expected ErrNotInRoom for a stranger, got %v", err)
+		}
+		room.Flush()
+	})
+
+	expected := []string{
+		"Unknown command /dance. Type /help for the list of commands.",
+		"You need to be moderator to use /topic.",
+		"Usage: /msg <name> <message>",
+		"/msg failed: user not in the chat room.",
+		"Olivia changed the topic to: Design patterns",
+	}
+	if got := systemNotices(bob); !slices.Equal(got, expected) {
+		t.Errorf("Expected Bob's notices to be %q, got %q", expected, got)
+	}
+	if got := systemNotices(carol); len(got) != 1 || got[0] != expected[len(expected)-1] {
+		t.Errorf("Expected Carol to only get the topic change, got %q", got)
+	}
+	received := carol.Received()
+	if received[0].Kind != cr.DirectMessage || received[0].Sender != "Bob" || received[0].Body != "psst, over here" {
+		t.Errorf("Expected Carol to get the direct message, got %+v", received[0])
+	}
+	if topic := room.Topic(); topic != "Design patterns" {
+		t.Errorf("Expected the topic to change, got %q", topic)
+	}
+}
+
+func TestChatRoom_Help(t *testing.T) {
+	room, owner, _, bob, _ := moderatedRoom(t)
+	captureOutput(func() {
+		room.RunCommand("/help", bob)
+		room.RunCommand("/help", owner)
+		room.RunCommand("/help /msg", bob)
+		room.Flush()
+	})
+
+	notices := systemNotices(bob)
+	if len(notices) != 2 {
+		t.Fatalf("Expected 2 notices, got %q", notices)
+	}
+	if strings.Contains(notices[0], "/topic") || !strings.Contains(notices[0], "/who - List the members of the room") {
+		t.Errorf("Expected the help of a member to list the commands they may use, got %q", notices[0])
+	}
+	if notices[1] != "/msg <name> <message> - Send a direct message" {
+		t.Errorf("Expected the help of /msg, got %q", notices[1])
+	}
+	var ownerHelp string
+	for _, notice := range systemNotices(owner) {
+		if strings.HasPrefix(notice, "Commands:") {
+			ownerHelp = notice
+		}
+	}
+	if !strings.Contains(ownerHelp, "/topic <topic> - Change the topic of the room (moderator)") {
+		t.Errorf("Expected the help of the owner to list /topic, got %q", ownerHelp)
+	}
+}
+
+func TestChatRoom_Nick(t *testing.T) {
+	lobby := cr.NewLobby()
+	alice := cr.NewChatUser("Alice")
+	bob := NewStructuredUser("Bob")
+	var room *cr.ChatRoom
+	captureOutput(func() {
+		room, _ = lobby.CreateRoom("general", cr.RoomOptions{Owner: "Alice"})
+		room.Join(alice)
+		room.Join(bob)
+
+		if err := room.RunCommand("/nick Bob", alice); !errors.Is(err, cr.ErrUserExists) {
+			t.Errorf("Expected a taken name to be refused, got %v", err)
+		}
+		if err := room.RunCommand("/nick @Al", alice); !errors.Is(err, cr.ErrInvalidName) {
+			t.Errorf("Expected an invalid name to be refused, got %v", err)
+		}
+		if err := room.RunCommand("/nick Al", alice); err != nil {
+			t.Errorf("Expected the rename to succeed, got %v", err)
+		}
+		if err := room.RunCommand("/nick Robert", bob); !errors.Is(err, cr.ErrRenameUnsupported) {
+			t.Errorf("Expected ErrRenameUnsupported for a user without SetName, got %v", err)
+		}
+		alice.Send("Call me Al")
+		room.Flush()
+	})
+
+	if name := alice.GetName(); name != "Al" {
+		t.Errorf("Expected Alice to be renamed Al, got %q", name)
+	}
+	if members := room.Members(); !slices.Equal(members, []string{"Al", "Bob"}) {
+		t.Errorf("Expected members [Al Bob], got %v", members)
+	}
+	if role := room.Role("Al"); role != cr.Owner {
+		t.Errorf("Expected the role to follow the new name, got %v", role)
+	}
+	if got := said(bob); len(got) != 1 || got[0] != "Call me Al" || bob.Received()[len(bob.Received())-1].Sender != "Al" {
+		t.Errorf("Expected Bob to get a message from Al, got %+v", bob.Received())
+	}
+	if notices := systemNotices(bob); !slices.Contains(notices, "Alice is now known as Al.") {
+		t.Errorf("Expected the rename to be announced, got %q", notices)
+	}
+
+	// A user has one name across rooms, so /nick is refused in several rooms
+	captureOutput(func() {
+		lobby.CreateRoom("random", cr.RoomOptions{})
+		lobby.Join("random", alice, "")
+		if err := room.RunCommand("/nick Alice", alice); err == nil {
+			t.Errorf("Expected /nick to be refused while in several rooms")
+		}
+		room.Flush()
+	})
+}
+
+func TestCommands_Register(t *testing.T) {
+	room, _, moderator, bob, carol := moderatedRoom(t)
+	var calls [][]string
+	err := room.Commands().Register(cr.Command{
+		Name:  "Slow",
+		Usage: "<seconds>",
+		Help:  "Limit how often members may post",
+		Role:  cr.Moderator,
+		Handler: func(room *cr.ChatRoom, sender cr.User, args []string) error {
+			calls = append(calls, append([]string{sender.GetName()}, args...))
+			return room.Announce("Slow mode is on")
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected the command to register, got %v", err)
+	}
+	if err := room.Commands().Register(cr.Command{Name: "who", Handler: func(*cr.ChatRoom, cr.User, []string) error { return nil }}); !errors.Is(err, cr.ErrCommandExists) {
+		t.Errorf("Expected ErrCommandExists, got %v", err)
+	}
+	if err := room.Commands().Register(cr.Command{Name: "broken"}); err == nil {
+		t.Errorf("Expected a command without a handler to be refused")
+	}
+
+	captureOutput(func() {
+		if err := room.RunCommand("/slow 10", bob); !errors.Is(err, cr.ErrPermissionDenied) {
+			t.Errorf("Expected a member not to run /slow, got %v", err)
+		}
+		if err := room.RunCommand("/SLOW 10", moderator); err != nil {
+			t.Errorf("Expected the moderator to run /slow, got %v", err)
+		}
+		room.Flush()
+	})
+	if len(calls) != 1 || !slices.Equal(calls[0], []string{"Mona", "10"}) {
+		t.Errorf("Expected one call by Mona with [10], got %v", calls)
+	}
+	if got := systemNotices(carol); !slices.Contains(got, "Slow mode is on") {
+		t.Errorf("Expected the announcement, got %q", got)
+	}
+
+	// Rooms may share a registry
+	shared := cr.NewCommands()
+	other := cr.NewChatRoom()
+	other.SetCommands(shared)
+	captureOutput(func() {
+		other.Join(bob)
+		if err := other.RunCommand("/who", bob); !errors.Is(err, cr.ErrUnknownCommand) {
+			t.Errorf("Expected an empty registry to know no commands, got %v", err)
+		}
+	})
+}
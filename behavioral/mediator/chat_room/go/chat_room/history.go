@@ -0,0 +1,338 @@
+package chat_room
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTailSize is how many recent messages a History keeps in memory.
+const DefaultTailSize = 100
+
+// ErrHistoryClosed is returned when using a History after Close.
+var ErrHistoryClosed = errors.New("history closed")
+
+// record locates a message in the log file
+type record struct {
+	id     uint64
+	offset int64
+	size   int64 // Including the newline
+	time   time.Time
+}
+
+// History is the append-only message log of a room, one JSON document per
+// line. Recent messages are also kept in memory. A record torn by a crash
+// in the middle of a write is discarded when the log is opened.
+type History struct {
+	path string
+	file *os.File
+
+	mutex    sync.Mutex
+	records  []record  // Every message in the file, in ID order
+	tail     []Message // The last messages, at most tailSize
+	tailSize int
+	size     int64
+	maxAge   time.Duration // 0 means no age limit
+	maxBytes int64         // 0 means no size limit
+}
+
+// OpenHistory opens or creates the log at path, dropping a torn final
+// record.
+func OpenHistory(path string) (*History, error) {
+	os.Remove(path + ".compact") // Left over by a crash during Compact
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	h := &History{path: path, file: file, tailSize: DefaultTailSize}
+	if err := h.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// load indexes the file, truncating it after the last complete record
+func (h *History) load() error {
+	reader := bufio.NewReader(io.NewSectionReader(h.file, 0, math.MaxInt64))
+	var offset int64
+	var lastID uint64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break // Anything without a newline was torn by a crash
+		}
+		if err != nil {
+			return err
+		}
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil || msg.ID <= lastID {
+			if _, err := reader.Peek(1); err == io.EOF {
+				break // A torn final record
+			}
+			return fmt.Errorf("corrupt history record at offset %d in %s", offset, h.path)
+		}
+		h.records = append(h.records, record{id: msg.ID, offset: offset, size: int64(len(line)), time: msg.Timestamp})
+		h.pushTail(msg)
+		offset += int64(len(line))
+		lastID = msg.ID
+	}
+	if err := h.file.Truncate(offset); err != nil {
+		return err
+	}
+	h.size = offset
+	return nil
+}
+
+// SetTailSize sets how many recent messages are kept in memory.
+func (h *History) SetTailSize(size int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.tailSize = max(size, 0)
+	if len(h.tail) > h.tailSize {
+		h.tail = h.tail[len(h.tail)-h.tailSize:]
+	}
+}
+
+// SetRetention limits the log to messages younger than maxAge and to about
+// maxBytes; zero disables a limit. Old messages are dropped by Compact,
+// which Append runs whenever the log outgrows maxBytes or its oldest message
+// is a quarter past maxAge; the slack keeps a steady stream of messages
+// from rewriting the log on every Append.
+func (h *History) SetRetention(maxAge time.Duration, maxBytes int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.maxAge = maxAge
+	h.maxBytes = maxBytes
+}
+
+// pushTail adds a message to the in-memory tail
+func (h *History) pushTail(msg Message) {
+	h.tail = append(h.tail, msg)
+	if len(h.tail) > h.tailSize {
+		h.tail = h.tail[len(h.tail)-h.tailSize:]
+	}
+}
+
+// Append writes a message to the log. IDs must increase.
+func (h *History) Append(msg Message) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.file == nil {
+		return ErrHistoryClosed
+	}
+	if n := len(h.records); n > 0 && msg.ID <= h.records[n-1].id {
+		return fmt.Errorf("message ID %d is not after %d", msg.ID, h.records[n-1].id)
+	}
+
+	msg.Mentioned, msg.Replayed = false, false // Per recipient, not part of the message
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := h.file.Write(data); err != nil {
+		h.file.Truncate(h.size) // Don't leave a partial record behind
+		return err
+	}
+	h.records = append(h.records, record{id: msg.ID, offset: h.size, size: int64(len(data)), time: msg.Timestamp})
+	h.size += int64(len(data))
+	h.pushTail(msg)
+
+	oversized := h.maxBytes > 0 && h.size > h.maxBytes
+	expired := h.maxAge > 0 && h.records[0].time.Before(time.Now().Add(-h.maxAge-h.maxAge/4))
+	if oversized || expired {
+		return h.compact()
+	}
+	return nil
+}
+
+// LastID returns the ID of the newest message, or 0 for an empty log.
+func (h *History) LastID() uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.records) == 0 {
+		return 0
+	}
+	return h.records[len(h.records)-1].id
+}
+
+// Len returns the number of messages in the log.
+func (h *History) Len() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.records)
+}
+
+// Last returns up to n of the newest messages, oldest first.
+func (h *History) Last(n int) ([]Message, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.read(max(len(h.records)-max(n, 0), 0), len(h.records))
+}
+
+// Before returns up to limit messages older than the message id, oldest
+// first: the page just before it.
+func (h *History) Before(id uint64, limit int) ([]Message, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	end := h.search(id)
+	return h.read(max(end-max(limit, 0), 0), end)
+}
+
+// After returns up to limit messages newer than the message id, oldest
+// first: the page just after it.
+func (h *History) After(id uint64, limit int) ([]Message, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	start := h.search(id + 1)
+	return h.read(start, min(start+max(limit, 0), len(h.records)))
+}
+
+// search returns the index of the first record with an ID of at least id
+func (h *History) search(id uint64) int {
+	return sort.Search(len(h.records), func(i int) bool { return h.records[i].id >= id })
+}
+
+// read returns the messages of records[start:end], from the tail when it
+// holds them
+func (h *History) read(start, end int) ([]Message, error) {
+	if h.file == nil {
+		return nil, ErrHistoryClosed
+	}
+	if start >= end {
+		return nil, nil
+	}
+	if inTail := len(h.records) - len(h.tail); start >= inTail {
+		return append([]Message(nil), h.tail[start-inTail:end-inTail]...), nil
+	}
+
+	first, last := h.records[start], h.records[end-1]
+	data := make([]byte, last.offset+last.size-first.offset)
+	if _, err := h.file.ReadAt(data, first.offset); err != nil {
+		return nil, err
+	}
+	messages := make([]Message, 0, end-start)
+	for _, line := range bytes.SplitAfter(data, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// Compact applies the retention limits by rewriting the log without the
+// expired messages. The new log replaces the old one atomically, so a
+// crash leaves one of them intact.
+func (h *History) Compact() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.file == nil {
+		return ErrHistoryClosed
+	}
+	return h.compact()
+}
+
+// compact rewrites the log. The caller holds the lock.
+func (h *History) compact() error {
+	drop := 0
+	if h.maxAge > 0 {
+		cutoff := time.Now().Add(-h.maxAge)
+		for drop < len(h.records) && h.records[drop].time.Before(cutoff) {
+			drop++
+		}
+	}
+	if h.maxBytes > 0 {
+		// Trim to three quarters of the limit, so that appending does not
+		// compact again right away
+		target := h.maxBytes * 3 / 4
+		for drop < len(h.records) && h.size-h.records[drop].offset > target {
+			drop++
+		}
+	}
+	if drop == 0 {
+		return nil
+	}
+
+	var kept []byte
+	if drop < len(h.records) {
+		kept = make([]byte, h.size-h.records[drop].offset)
+		if _, err := h.file.ReadAt(kept, h.records[drop].offset); err != nil {
+			return err
+		}
+	}
+	temp := h.path + ".compact"
+	if err := writeFileSync(temp, kept); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	if err := os.Rename(temp, h.path); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	file, err := os.OpenFile(h.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	h.file.Close()
+	h.file = file
+
+	shift := int64(0)
+	if drop < len(h.records) {
+		shift = h.records[drop].offset
+	}
+	h.records = append([]record(nil), h.records[drop:]...)
+	for i := range h.records {
+		h.records[i].offset -= shift
+	}
+	h.size = int64(len(kept))
+	if len(h.tail) > len(h.records) {
+		h.tail = h.tail[len(h.tail)-len(h.records):]
+	}
+	return nil
+}
+
+// writeFileSync writes a file and flushes it to disk
+func writeFileSync(name string, data []byte) error {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Close flushes the log to disk and closes it.
+func (h *History) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Sync()
+	if closeErr := h.file.Close(); err == nil {
+		err = closeErr
+	}
+	h.file = nil
+	return err
+}
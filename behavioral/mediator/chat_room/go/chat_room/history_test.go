@@ -0,0 +1,243 @@
+package chat_room_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	cr "mediator_pattern_chat_room_go/chat_room"
+)
+
+// openHistory opens a history in a temporary directory
+func openHistory(t *testing.T, path string) *cr.History {
+	t.Helper()
+	history, err := cr.OpenHistory(path)
+	if err != nil {
+		t.Fatalf("Failed to open history: %v", err)
+	}
+	t.Cleanup(func() { history.Close() })
+	return history
+}
+
+// appendMessages appends messages with the given IDs, one minute apart
+func appendMessages(t *testing.T, history *cr.History, start time.Time, ids ...uint64) {
+	t.Helper()
+	for _, id := range ids {
+		msg := cr.Message{ID: id, Timestamp: start.Add(time.Duration(id) * time.Minute), Sender: "Alice", Body: fmt.Sprintf("message %d", id)}
+		if err := history.Append(msg); err != nil {
+			t.Fatalf("Failed to append message %d: %v", id, err)
+		}
+	}
+}
+
+func ids(messages []cr.Message) []uint64 {
+	var result []uint64
+	for _, msg := range messages {
+		result = append(result, msg.ID)
+	}
+	return result
+}
+
+func TestHistory_Pagination(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "general.log")
+	history := openHistory(t, path)
+	history.SetTailSize(3) // Older pages come from the file
+	appendMessages(t, history, time.Now(), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+
+	tests := []struct {
+		name     string
+		query    func() ([]cr.Message, error)
+		expected []uint64
+	}{
+		{"Last from the tail", func() ([]cr.Message, error) { return history.Last(2) }, []uint64{9, 10}},
+		{"Last from the file", func() ([]cr.Message, error) { return history.Last(4) }, []uint64{7, 8, 9, 10}},
+		{"Last more than all", func() ([]cr.Message, error) { return history.Last(50) }, []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{"Before", func() ([]cr.Message, error) { return history.Before(6, 3) }, []uint64{3, 4, 5}},
+		{"Before the start", func() ([]cr.Message, error) { return history.Before(3, 5) }, []uint64{1, 2}},
+		{"After", func() ([]cr.Message, error) { return history.After(6, 3) }, []uint64{7, 8, 9}},
+		{"After the end", func() ([]cr.Message, error) { return history.After(10, 3) }, nil},
+	}
+	for _, tt := range tests {
+		messages, err := tt.query()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if got := ids(messages); !slices.Equal(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+
+	// The log survives reopening
+	history.Close()
+	reopened := openHistory(t, path)
+	if messages, _ := reopened.Before(4, 10); !slices.Equal(ids(messages), []uint64{1, 2, 3}) || messages[2].Body != "message 3" {
+		t.Errorf("Expected messages 1 to 3 after reopening, got %v", messages)
+	}
+	if err := reopened.Append(cr.Message{ID: 10}); err == nil {
+		t.Error("Expected appending a non-increasing ID to fail")
+	}
+}
+
+func TestHistory_CrashDuringWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "general.log")
+	history := openHistory(t, path)
+	appendMessages(t, history, time.Now(), 1, 2, 3)
+	history.Close()
+	intact, _ := os.Stat(path)
+
+	// The process dies halfway through writing message 4
+	record, _ := json.Marshal(cr.Message{ID: 4, Body: "never finished"})
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.Write(record[:len(record)/2])
+	file.Close()
+
+	recovered := openHistory(t, path)
+	if recovered.Len() != 3 || recovered.LastID() != 3 {
+		t.Errorf("Expected the 3 complete messages, got %d up to ID %d", recovered.Len(), recovered.LastID())
+	}
+	if info, _ := os.Stat(path); info.Size() != intact.Size() {
+		t.Errorf("Expected the torn record to be truncated, size %d instead of %d", info.Size(), intact.Size())
+	}
+	appendMessages(t, recovered, time.Now(), 4)
+	recovered.Close()
+	if messages, _ := openHistory(t, path).Last(10); !slices.Equal(ids(messages), []uint64{1, 2, 3, 4}) {
+		t.Errorf("Expected messages 1 to 4 after recovery, got %v", ids(messages))
+	}
+}
+
+func TestHistory_CorruptRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "general.log")
+	history := openHistory(t, path)
+	appendMessages(t, history, time.Now(), 1)
+	history.Close()
+
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.WriteString("garbage\n")
+	file.Close()
+	history = openHistory(t, path)
+	appendMessages(t, history, time.Now(), 2) // Garbage was the final record, so it is dropped
+	history.Close()
+
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, append([]byte("garbage\n"), data...), 0o644)
+	if _, err := cr.OpenHistory(path); err == nil {
+		t.Error("Expected a corrupt record before the end to be reported")
+	}
+}
+
+func TestHistory_Retention(t *testing.T) {
+	t.Run("Age", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "general.log")
+		history := openHistory(t, path)
+		start := time.Now().Add(-10 * time.Minute) // Message n is 10-n minutes old
+		appendMessages(t, history, start, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+
+		history.SetRetention(5*time.Minute+30*time.Second, 0)
+		if err := history.Compact(); err != nil {
+			t.Fatal(err)
+		}
+		messages, _ := history.Last(100)
+		if !slices.Equal(ids(messages), []uint64{5, 6, 7, 8, 9}) {
+			t.Errorf("Expected messages younger than 5.5 minutes, got %v", ids(messages))
+		}
+		history.Close()
+		if messages, _ := openHistory(t, path).After(0, 100); !slices.Equal(ids(messages), []uint64{5, 6, 7, 8, 9}) {
+			t.Errorf("Expected the compacted log on disk, got %v", ids(messages))
+		}
+	})
+
+	t.Run("AgeOnAppend", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "general.log")
+		history := openHistory(t, path)
+		start := time.Now().Add(-10 * time.Minute)
+		appendMessages(t, history, start, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+		history.SetRetention(5*time.Minute+30*time.Second, 0)
+		// The next append finds message 1 long expired, without any Compact
+		appendMessages(t, history, start, 10)
+		messages, _ := history.Last(100)
+		if !slices.Equal(ids(messages), []uint64{5, 6, 7, 8, 9, 10}) {
+			t.Errorf("Expected Append to drop expired messages, got %v", ids(messages))
+		}
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "general.log")
+		history := openHistory(t, path)
+		history.SetTailSize(5)
+		history.SetRetention(0, 2000)
+		for id := uint64(1); id <= 100; id++ {
+			appendMessages(t, history, time.Now(), id)
+			if info, _ := os.Stat(path); info.Size() > 2000 {
+				t.Fatalf("Expected the log to stay under 2000 bytes, got %d after message %d", info.Size(), id)
+			}
+		}
+		messages, _ := history.After(0, 100)
+		if len(messages) == 0 || messages[len(messages)-1].ID != 100 || messages[0].ID != uint64(101-len(messages)) {
+			t.Errorf("Expected the newest messages up to 100 to be kept, got %v", ids(messages))
+		}
+		if before, _ := history.Before(messages[2].ID, 2); !slices.Equal(ids(before), ids(messages[:2])) {
+			t.Errorf("Expected paging to work after compaction, got %v", ids(before))
+		}
+	})
+}
+
+func TestChatRoom_ReplayOnJoin(t *testing.T) {
+	dir := t.TempDir()
+	lobby := cr.NewLobby()
+	lobby.SetHistoryDir(dir, 3)
+	alice := cr.NewChatUser("Alice")
+	bob := cr.NewChatUser("Bob")
+	carol := NewStructuredUser("Carol")
+
+	captureOutput(func() {
+		room, err := lobby.CreateRoom("general", cr.RoomOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		room.AddUser(alice)
+		room.AddUser(bob)
+		alice.Send("one")
+		alice.Send("two, @Carol")
+		alice.SendDirect("Bob", "secret")
+		bob.Send("three")
+		room.AddUser(carol)
+		alice.Send("four")
+		room.Flush()
+	})
+
+	received := carol.Received()
+	expected := []string{"one", "two, @Carol", "three", "four"}
+	var bodies []string
+	for _, msg := range received {
+		bodies = append(bodies, msg.Body)
+	}
+	// The direct message to Bob is not replayed to Carol
+	if !slices.Equal(bodies, expected) {
+		t.Fatalf("Expected the last 3 visible messages and then the new one, got %v", bodies)
+	}
+	if !received[2].Replayed || !received[1].Mentioned || received[3].Replayed {
+		t.Errorf("Expected replayed messages to be flagged, got %+v", received)
+	}
+
+	// A recreated room continues its log and its IDs
+	captureOutput(func() {
+		lobby.DeleteRoom("general")
+		room, err := lobby.CreateRoom("general", cr.RoomOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		dave := NewStructuredUser("Dave")
+		room.AddUser(dave)
+		room.AddUser(alice)
+		alice.Send("five")
+		room.Flush()
+		got := dave.Received()
+		if len(got) != 4 || got[2].Body != "four" || got[3].ID != 6 {
+			t.Errorf("Expected 3 replayed messages, then message 6, got %+v", got)
+		}
+	})
+}
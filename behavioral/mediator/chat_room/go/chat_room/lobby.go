@@ -0,0 +1,266 @@
+package chat_room
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrRoomExists is returned when creating a room under a taken name.
+	ErrRoomExists = errors.New("chat room already exists")
+	// ErrNoSuchRoom is returned when a room name is unknown to the lobby.
+	ErrNoSuchRoom = errors.New("no such chat room")
+)
+
+// EventKind identifies what happened in a room.
+type EventKind int
+
+const (
+	// MemberJoined is sent to every member, including the one who joined.
+	MemberJoined EventKind = iota
+	// MemberLeft is sent to the remaining members and to the one who left.
+	MemberLeft
+	// RoomDeleted is sent to the members of a room deleted from its lobby.
+	RoomDeleted
+	// RoomClosed is sent to the members of a room once Close has delivered
+	// what was queued for them.
+	RoomClosed
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case MemberJoined:
+		return "joined"
+	case MemberLeft:
+		return "left"
+	case RoomDeleted:
+		return "deleted"
+	case RoomClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// RoomEvent describes a membership change in a room.
+type RoomEvent struct {
+	Kind EventKind
+	Room string // Empty for rooms not created by a Lobby
+	User string // Empty for RoomDeleted and RoomClosed
+}
+
+// RoomObserver is implemented by users that want the membership events of
+// the rooms they are in. Events are delivered after the room is unlocked,
+// so observers may call back into the room.
+type RoomObserver interface {
+	OnRoomEvent(event RoomEvent)
+}
+
+// asObservers returns the user as an observer, if it is one
+func asObservers(user User) []RoomObserver {
+	if observer, ok := user.(RoomObserver); ok {
+		return []RoomObserver{observer}
+	}
+	return nil
+}
+
+// notify delivers an event to observers
+func notify(observers []RoomObserver, event RoomEvent) {
+	for _, observer := range observers {
+		observer.OnRoomEvent(event)
+	}
+}
+
+// RoomOptions configures a room created by a Lobby.
+type RoomOptions struct {
+	Topic      string
+	Password   string // Required to join when not empty
+	MaxMembers int    // 0 means no cap
+	Owner      string // Member name granted the Owner role
+}
+
+// RoomInfo summarizes a room for listings.
+type RoomInfo struct {
+	Name       string
+	Topic      string
+	Members    int
+	MaxMembers int
+	Private    bool // Whether a password is needed to join
+}
+
+// --- Lobby: Registry of Mediators ---
+
+// Lobby creates, lists and deletes named chat rooms. A user may be in any
+// number of rooms; names are only unique within a room.
+type Lobby struct {
+	rooms map[string]*ChatRoom
+	mutex sync.RWMutex
+
+	historyDir string // Where room logs are kept, empty for none
+	replay     int
+	search     *SearchIndex
+}
+
+// NewLobby creates an empty Lobby.
+func NewLobby() *Lobby {
+	return &Lobby{
+		rooms: make(map[string]*ChatRoom),
+	}
+}
+
+// SetHistoryDir makes rooms created afterwards keep their history in a log
+// file in dir, named after the room, and replay the last replay messages to
+// new members. A room recreated under the same name picks up its old log.
+func (l *Lobby) SetHistoryDir(dir string, replay int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.historyDir = dir
+	l.replay = replay
+}
+
+// SetSearchIndex makes rooms created afterwards add their messages to
+// index, including those of their history.
+func (l *Lobby) SetSearchIndex(index *SearchIndex) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.search = index
+}
+
+// CreateRoom creates a room, failing with ErrRoomExists when the name is
+// taken.
+func (l *Lobby) CreateRoom(name string, options RoomOptions) (*ChatRoom, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("room name must not be empty")
+	}
+	if options.MaxMembers < 0 {
+		return nil, errors.New("member cap must not be negative")
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, exists := l.rooms[name]; exists {
+		return nil, ErrRoomExists
+	}
+	room := NewChatRoom()
+	room.name = name
+	room.topic = options.Topic
+	room.password = options.Password
+	room.maxMembers = options.MaxMembers
+	if options.Owner != "" {
+		room.roles[options.Owner] = Owner
+	}
+	if l.historyDir != "" {
+		history, err := OpenHistory(filepath.Join(l.historyDir, url.PathEscape(name)+".log"))
+		if err != nil {
+			return nil, err
+		}
+		room.SetHistory(history, l.replay)
+	}
+	if l.search != nil {
+		if err := room.SetSearchIndex(l.search); err != nil {
+			room.history.Close()
+			return nil, err
+		}
+	}
+	l.rooms[name] = room
+	log.Printf("--- Room %s created. ---", name)
+	return room, nil
+}
+
+// Room returns the room with the given name.
+func (l *Lobby) Room(name string) (*ChatRoom, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	room, ok := l.rooms[name]
+	if !ok {
+		return nil, ErrNoSuchRoom
+	}
+	return room, nil
+}
+
+// Rooms lists the rooms, sorted by name.
+func (l *Lobby) Rooms() []RoomInfo {
+	l.mutex.RLock()
+	rooms := make([]*ChatRoom, 0, len(l.rooms))
+	for _, room := range l.rooms {
+		rooms = append(rooms, room)
+	}
+	l.mutex.RUnlock()
+
+	infos := make([]RoomInfo, 0, len(rooms))
+	for _, room := range rooms {
+		room.mutex.RLock()
+		infos = append(infos, RoomInfo{
+			Name:       room.name,
+			Topic:      room.topic,
+			Members:    len(room.users),
+			MaxMembers: room.maxMembers,
+			Private:    room.password != "",
+		})
+		room.mutex.RUnlock()
+	}
+	slices.SortFunc(infos, func(a, b RoomInfo) int { return strings.Compare(a.Name, b.Name) })
+	return infos
+}
+
+// Join adds a user to a room, giving the room password if it has one.
+func (l *Lobby) Join(roomName string, user User, password string) error {
+	room, err := l.Room(roomName)
+	if err != nil {
+		return err
+	}
+	return room.Enter(user, password)
+}
+
+// Leave removes a user from a room.
+func (l *Lobby) Leave(roomName string, user User) error {
+	room, err := l.Room(roomName)
+	if err != nil {
+		return err
+	}
+	return room.Leave(user)
+}
+
+// RoomsOf returns the names of the rooms a user name is in, sorted.
+func (l *Lobby) RoomsOf(userName string) []string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	var names []string
+	for name, room := range l.rooms {
+		room.mutex.RLock()
+		_, member := room.users[userName]
+		room.mutex.RUnlock()
+		if member {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// DeleteRoom removes a room from the lobby. Its members are dropped and, if
+// they implement RoomObserver, told that the room was deleted. The room
+// refuses new members afterwards.
+func (l *Lobby) DeleteRoom(name string) error {
+	l.mutex.Lock()
+	room, ok := l.rooms[name]
+	if !ok {
+		l.mutex.Unlock()
+		return ErrNoSuchRoom
+	}
+	delete(l.rooms, name)
+	l.mutex.Unlock()
+
+	observers := room.remove()
+	if history := room.History(); history != nil {
+		history.Close() // The log file is kept
+	}
+	log.Printf("--- Room %s deleted. ---", name)
+	notify(observers, RoomEvent{Kind: RoomDeleted, Room: name})
+	return nil
+}
@@ -0,0 +1,253 @@
+package chat_room_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	cr "mediator_pattern_chat_room_go/chat_room"
+)
+
+// ObservingUser is a MockUser that records room events.
+type ObservingUser struct {
+	*MockUser
+	events []cr.RoomEvent
+	lock   sync.Mutex
+}
+
+func NewObservingUser(name string) *ObservingUser {
+	return &ObservingUser{MockUser: NewMockUser(name)}
+}
+
+func (ou *ObservingUser) OnRoomEvent(event cr.RoomEvent) {
+	ou.lock.Lock()
+	defer ou.lock.Unlock()
+	ou.events = append(ou.events, event)
+}
+
+func (ou *ObservingUser) Events() []cr.RoomEvent {
+	ou.lock.Lock()
+	defer ou.lock.Unlock()
+	return slices.Clone(ou.events)
+}
+
+func TestLobby_CreateListDelete(t *testing.T) {
+	lobby := cr.NewLobby()
+	captureOutput(func() {
+		lobby.CreateRoom("general", cr.RoomOptions{Topic: "Anything goes"})
+		lobby.CreateRoom("staff", cr.RoomOptions{Password: "secret", MaxMembers: 5})
+	})
+
+	if _, err := lobby.CreateRoom("general", cr.RoomOptions{}); err != cr.ErrRoomExists {
+		t.Errorf("Expected ErrRoomExists, got %v", err)
+	}
+	if _, err := lobby.CreateRoom("  ", cr.RoomOptions{}); err == nil {
+		t.Error("Expected an error for an empty room name")
+	}
+
+	expected := []cr.RoomInfo{
+		{Name: "general", Topic: "Anything goes"},
+		{Name: "staff", MaxMembers: 5, Private: true},
+	}
+	if rooms := lobby.Rooms(); !slices.Equal(rooms, expected) {
+		t.Errorf("Expected rooms %v, got %v", expected, rooms)
+	}
+
+	captureOutput(func() {
+		if err := lobby.DeleteRoom("staff"); err != nil {
+			t.Errorf("Unexpected error deleting a room: %v", err)
+		}
+	})
+	if err := lobby.DeleteRoom("staff"); err != cr.ErrNoSuchRoom {
+		t.Errorf("Expected ErrNoSuchRoom, got %v", err)
+	}
+	if rooms := lobby.Rooms(); len(rooms) != 1 || rooms[0].Name != "general" {
+		t.Errorf("Expected only general to remain, got %v", rooms)
+	}
+}
+
+func TestLobby_JoinRules(t *testing.T) {
+	lobby := cr.NewLobby()
+	captureOutput(func() {
+		lobby.CreateRoom("staff", cr.RoomOptions{Password: "secret", MaxMembers: 2})
+	})
+
+	tests := []struct {
+		user     string
+		password string
+		expected error
+	}{
+		{"Alice", "guess", cr.ErrWrongPassword},
+		{"Alice", "secret", nil},
+		{"Alice", "secret", cr.ErrUserExists},
+		{"Bob", "secret", nil},
+		{"Charlie", "secret", cr.ErrRoomFull},
+	}
+	for _, tt := range tests {
+		var err error
+		captureOutput(func() {
+			err = lobby.Join("staff", NewMockUser(tt.user), tt.password)
+		})
+		if err != tt.expected {
+			t.Errorf("Join(%s, %q): expected %v, got %v", tt.user, tt.password, tt.expected, err)
+		}
+	}
+
+	if err := lobby.Join("nowhere", NewMockUser("Alice"), ""); err != cr.ErrNoSuchRoom {
+		t.Errorf("Expected ErrNoSuchRoom, got %v", err)
+	}
+	if err := lobby.Leave("staff", NewMockUser("Charlie")); err != cr.ErrNotInRoom {
+		t.Errorf("Expected ErrNotInRoom, got %v", err)
+	}
+}
+
+func TestLobby_NamesAreUniquePerRoom(t *testing.T) {
+	lobby := cr.NewLobby()
+	captureOutput(func() {
+		lobby.CreateRoom("general", cr.RoomOptions{})
+		lobby.CreateRoom("random", cr.RoomOptions{})
+	})
+
+	alice := cr.NewChatUser("Alice")
+	otherAlice := NewMockUser("Alice")
+	bob := NewMockUser("Bob")
+	carol := NewMockUser("Carol")
+	captureOutput(func() {
+		for _, join := range []struct {
+			room string
+			user cr.User
+		}{{"general", alice}, {"random", alice}, {"random", otherAlice}, {"general", bob}, {"random", carol}} {
+			if err := lobby.Join(join.room, join.user, ""); err != nil && join.user != otherAlice {
+				t.Errorf("Unexpected error joining %s: %v", join.room, err)
+			}
+		}
+	})
+
+	if rooms := alice.Rooms(); !slices.Equal(rooms, []string{"general", "random"}) {
+		t.Errorf("Expected Alice in general and random, got %v", rooms)
+	}
+	if rooms := lobby.RoomsOf("Alice"); !slices.Equal(rooms, []string{"general", "random"}) {
+		t.Errorf("Expected the lobby to find Alice in general and random, got %v", rooms)
+	}
+
+	// Messages go only to the room they are sent to
+	captureOutput(func() {
+		if err := alice.SendTo("general", "Hi general"); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		alice.SendTo("random", "Hi random")
+		for _, room := range []string{"general", "random"} {
+			room, _ := lobby.Room(room)
+			room.Flush()
+		}
+	})
+	if bob.ReceiveCallCount() != 1 || !bob.WasCalledWith("Hi general", "Alice") {
+		t.Errorf("Expected Bob to receive only the message to general")
+	}
+	if carol.ReceiveCallCount() != 1 || !carol.WasCalledWith("Hi random", "Alice") {
+		t.Errorf("Expected Carol to receive only the message to random")
+	}
+	if otherAlice.ReceiveCallCount() != 0 {
+		t.Errorf("Rejected duplicate Alice should not receive messages")
+	}
+	if err := alice.SendTo("staff", "Hi"); err != cr.ErrNotInRoom {
+		t.Errorf("Expected ErrNotInRoom, got %v", err)
+	}
+}
+
+func TestLobby_MembershipEvents(t *testing.T) {
+	lobby := cr.NewLobby()
+	alice := NewObservingUser("Alice")
+	bob := NewObservingUser("Bob")
+	var general *cr.ChatRoom
+	captureOutput(func() {
+		general, _ = lobby.CreateRoom("general", cr.RoomOptions{})
+		lobby.CreateRoom("random", cr.RoomOptions{})
+		lobby.Join("general", alice, "")
+		lobby.Join("random", alice, "")
+		lobby.Join("general", bob, "")
+		lobby.Leave("general", bob)
+	})
+
+	expected := []cr.RoomEvent{
+		{Kind: cr.MemberJoined, Room: "general", User: "Alice"},
+		{Kind: cr.MemberJoined, Room: "random", User: "Alice"},
+		{Kind: cr.MemberJoined, Room: "general", User: "Bob"},
+		{Kind: cr.MemberLeft, Room: "general", User: "Bob"},
+	}
+	if events := alice.Events(); !slices.Equal(events, expected) {
+		t.Errorf("Expected Alice's events %v, got %v", expected, events)
+	}
+	expected = []cr.RoomEvent{
+		{Kind: cr.MemberJoined, Room: "general", User: "Bob"},
+		{Kind: cr.MemberLeft, Room: "general", User: "Bob"},
+	}
+	if events := bob.Events(); !slices.Equal(events, expected) {
+		t.Errorf("Expected Bob's events %v, got %v", expected, events)
+	}
+
+	// Deleting a room notifies its members and closes it
+	captureOutput(func() {
+		lobby.DeleteRoom("general")
+	})
+	events := alice.Events()
+	if last := events[len(events)-1]; last != (cr.RoomEvent{Kind: cr.RoomDeleted, Room: "general"}) {
+		t.Errorf("Expected Alice to be told general was deleted, got %v", last)
+	}
+	if len(bob.Events()) != 2 {
+		t.Errorf("Bob already left general and should not be notified")
+	}
+	if len(general.Members()) != 0 {
+		t.Errorf("Expected a deleted room to have no members, got %v", general.Members())
+	}
+	if err := general.Join(bob); !errors.Is(err, cr.ErrRoomDeleted) {
+		t.Errorf("Expected ErrRoomDeleted, got %v", err)
+	}
+	if err := general.Post(cr.Message{Body: "anyone?"}, nil); !errors.Is(err, cr.ErrRoomDeleted) {
+		t.Errorf("Expected posting to a deleted room to fail with ErrRoomDeleted, got %v", err)
+	}
+}
+
+func TestChatUser_ForgetsDeletedRooms(t *testing.T) {
+	lobby := cr.NewLobby()
+	alice := cr.NewChatUser("Alice")
+	captureOutput(func() {
+		lobby.CreateRoom("general", cr.RoomOptions{})
+		lobby.CreateRoom("random", cr.RoomOptions{})
+		lobby.Join("general", alice, "")
+		lobby.Join("random", alice, "")
+		lobby.DeleteRoom("random")
+	})
+
+	if rooms := alice.Rooms(); !slices.Equal(rooms, []string{"general"}) {
+		t.Errorf("Expected Alice to be left in general, got %v", rooms)
+	}
+	// Send used the last room joined, which is gone now
+	output := captureOutput(func() {
+		alice.Send("Anyone?")
+	})
+	if output != "" {
+		t.Errorf("Expected no output when the current room was deleted, got: %s", output)
+	}
+}
+
+func TestChatUser_ForgetsClosedRooms(t *testing.T) {
+	lobby := cr.NewLobby()
+	alice := cr.NewChatUser("Alice")
+	var random *cr.ChatRoom
+	captureOutput(func() {
+		lobby.CreateRoom("general", cr.RoomOptions{})
+		random, _ = lobby.CreateRoom("random", cr.RoomOptions{})
+		lobby.Join("general", alice, "")
+		lobby.Join("random", alice, "")
+		random.Close()
+	})
+
+	if rooms := alice.Rooms(); !slices.Equal(rooms, []string{"general"}) {
+		t.Errorf("Expected Alice to be left in general, got %v", rooms)
+	}
+	if err := alice.SendTo("random", "Anyone?"); !errors.Is(err, cr.ErrNotInRoom) {
+		t.Errorf("Expected ErrNotInRoom for a closed room, got %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package chat_room
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMailboxSize is how many undelivered messages a user may have
+// before the overflow policy applies.
+const DefaultMailboxSize = 256
+
+// DefaultBlockTimeout is how long BlockWithTimeout waits for space.
+const DefaultBlockTimeout = time.Second
+
+// errMailboxFull reports an overflow under the Disconnect policy
+var errMailboxFull = errors.New("mailbox full")
+
+// OverflowPolicy decides what happens when a message arrives for a user
+// whose mailbox is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest undelivered message to make room.
+	DropOldest OverflowPolicy = iota
+	// Disconnect removes the user from the room.
+	Disconnect
+	// BlockWithTimeout makes the sender wait for space, dropping the new
+	// message if none frees up in time.
+	BlockWithTimeout
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop oldest"
+	case Disconnect:
+		return "disconnect"
+	case BlockWithTimeout:
+		return "block with timeout"
+	}
+	return "unknown"
+}
+
+// mailbox queues the messages of one user and delivers them in order from
+// its own goroutine, so a slow Receive only holds up its own user
+type mailbox struct {
+	user     User
+	capacity int
+	policy   OverflowPolicy
+	timeout  time.Duration
+	dropped  *atomic.Int64   // Shared by the mailboxes of a room
+	running  *sync.WaitGroup // Delivery goroutines of a room
+
+	mutex   sync.Mutex
+	changed *sync.Cond // Signals a new message, free space or a finished delivery
+	queue   []Message
+	busy    bool // A message is being delivered
+	closed  bool
+}
+
+// newMailbox creates a mailbox and starts its delivery goroutine, which is
+// counted in running until the mailbox is closed and drained
+func newMailbox(user User, capacity int, policy OverflowPolicy, timeout time.Duration, dropped *atomic.Int64, running *sync.WaitGroup) *mailbox {
+	m := &mailbox{
+		user:     user,
+		capacity: max(capacity, 1),
+		policy:   policy,
+		timeout:  timeout,
+		dropped:  dropped,
+		running:  running,
+	}
+	m.changed = sync.NewCond(&m.mutex)
+	running.Add(1)
+	go m.run()
+	return m
+}
+
+// put queues a message, applying the overflow policy when the mailbox is
+// full. Messages for a closed mailbox are discarded.
+func (m *mailbox) put(msg Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var deadline time.Time
+	for !m.closed && len(m.queue) >= m.capacity {
+		switch m.policy {
+		case DropOldest:
+			m.queue = m.queue[1:]
+			m.dropped.Add(1)
+		case Disconnect:
+			return errMailboxFull
+		case BlockWithTimeout:
+			if deadline.IsZero() {
+				deadline = time.Now().Add(m.timeout)
+				timer := time.AfterFunc(m.timeout, func() {
+					m.mutex.Lock()
+					m.changed.Broadcast()
+					m.mutex.Unlock()
+				})
+				defer timer.Stop()
+			}
+			if !time.Now().Before(deadline) {
+				m.dropped.Add(1)
+				return nil
+			}
+			m.changed.Wait()
+		}
+	}
+	if m.closed {
+		return nil
+	}
+	m.queue = append(m.queue, msg)
+	m.changed.Broadcast()
+	return nil
+}
+
+// run delivers queued messages until the mailbox is closed and drained
+func (m *mailbox) run() {
+	defer m.running.Done()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for {
+		for len(m.queue) == 0 && !m.closed {
+			m.changed.Wait()
+		}
+		if len(m.queue) == 0 {
+			return
+		}
+		msg := m.queue[0]
+		m.queue = m.queue[1:]
+		m.busy = true
+		m.changed.Broadcast()
+
+		m.mutex.Unlock()
+		deliver(m.user, msg)
+		m.mutex.Lock()
+
+		m.busy = false
+		m.changed.Broadcast()
+	}
+}
+
+// flush waits until every queued message has been delivered, reporting
+// whether there was anything to wait for
+func (m *mailbox) flush() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	waited := false
+	for len(m.queue) > 0 || m.busy {
+		waited = true
+		m.changed.Wait()
+	}
+	return waited
+}
+
+// close stops accepting messages. Queued ones are still delivered before
+// the delivery goroutine exits.
+func (m *mailbox) close() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.closed = true
+	m.changed.Broadcast()
+}
@@ -0,0 +1,195 @@
+package chat_room_test
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	cr "mediator_pattern_chat_room_go/chat_room"
+)
+
+// SlowUser blocks in Receive until released.
+type SlowUser struct {
+	*MockUser
+	gate    chan struct{}
+	entered chan struct{} // Signalled when a delivery starts
+}
+
+func NewSlowUser(name string) *SlowUser {
+	return &SlowUser{MockUser: NewMockUser(name), gate: make(chan struct{}), entered: make(chan struct{}, 1)}
+}
+
+func (su *SlowUser) Receive(message string, senderName string) {
+	select {
+	case su.entered <- struct{}{}:
+	default:
+	}
+	<-su.gate
+	su.MockUser.Receive(message, senderName)
+}
+
+func (su *SlowUser) Release() {
+	close(su.gate)
+}
+
+// Messages returns the received messages in order.
+func (mu *MockUser) Messages() []string {
+	mu.mutex.Lock()
+	defer mu.mutex.Unlock()
+	return slices.Clone(mu.receivedCalls)
+}
+
+// waitFor polls until cond holds or a second has passed
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+func TestChatRoom_SlowUserDoesNotStallRoom(t *testing.T) {
+	mediator := cr.NewChatRoom()
+	alice := NewMockUser("Alice")
+	bob := NewMockUser("Bob")
+	slow := NewSlowUser("Slow")
+	defer slow.Release()
+
+	captureOutput(func() {
+		mediator.AddUser(alice)
+		mediator.AddUser(bob)
+		mediator.AddUser(slow)
+
+		done := make(chan struct{})
+		go func() {
+			alice.Send("Hello")
+			mediator.AddUser(NewMockUser("Dave"))
+			mediator.RemoveUser(bob)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("Expected SendMessage, AddUser and RemoveUser not to wait for a slow user")
+		}
+	})
+
+	if !waitFor(func() bool { return bob.WasCalledWith("Hello", "Alice") }) {
+		t.Error("Expected Bob to receive the message while Slow is blocked")
+	}
+}
+
+func TestChatRoom_PerUserOrdering(t *testing.T) {
+	mediator := cr.NewChatRoom()
+	alice := NewMockUser("Alice")
+	bob := NewMockUser("Bob")
+	var expected []string
+	captureOutput(func() {
+		mediator.AddUser(alice)
+		mediator.AddUser(bob)
+		for i := range 100 {
+			message := fmt.Sprintf("message %d", i)
+			alice.Send(message)
+			expected = append(expected, "Bob received: "+message+" (from Alice)")
+		}
+		mediator.Flush()
+	})
+
+	if got := bob.Messages(); !slices.Equal(got, expected) {
+		t.Errorf("Expected Bob to receive 100 messages in order, got %d: %v", len(got), got)
+	}
+}
+
+func TestChatRoom_OverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    cr.OverflowPolicy
+		received  []string
+		dropped   int
+		connected bool
+	}{
+		// The first message is taken out of the mailbox as Slow blocks on it
+		{"DropOldest", cr.DropOldest, []string{"m0", "m3", "m4"}, 2, true},
+		{"Disconnect", cr.Disconnect, []string{"m0", "m1", "m2"}, 0, false},
+		{"BlockWithTimeout", cr.BlockWithTimeout, []string{"m0", "m1", "m2"}, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mediator := cr.NewChatRoom()
+			mediator.SetMailboxSize(2)
+			mediator.SetOverflowPolicy(tt.policy, 10*time.Millisecond)
+			alice := NewMockUser("Alice")
+			slow := NewSlowUser("Slow")
+
+			captureOutput(func() {
+				mediator.AddUser(alice)
+				mediator.AddUser(slow)
+				alice.Send("m0")
+				<-slow.entered
+				for _, message := range []string{"m1", "m2", "m3", "m4"} {
+					alice.Send(message)
+				}
+				slow.Release()
+				mediator.Close()
+			})
+
+			var expected []string
+			for _, message := range tt.received {
+				expected = append(expected, "Slow received: "+message+" (from Alice)")
+			}
+			if got := slow.Messages(); !slices.Equal(got, expected) {
+				t.Errorf("Expected %v, got %v", expected, got)
+			}
+			if mediator.Dropped() != tt.dropped {
+				t.Errorf("Expected %d dropped messages, got %d", tt.dropped, mediator.Dropped())
+			}
+			if connected := slices.Contains(mediator.Members(), "Slow"); connected != tt.connected {
+				t.Errorf("Expected Slow connected: %t, got %t", tt.connected, connected)
+			}
+		})
+	}
+}
+
+func TestChatRoom_CloseDrainsMailboxes(t *testing.T) {
+	mediator := cr.NewChatRoom()
+	alice := NewMockUser("Alice")
+	slow := NewSlowUser("Slow")
+
+	var closed sync.WaitGroup
+	captureOutput(func() {
+		mediator.AddUser(alice)
+		mediator.AddUser(slow)
+		for i := range 5 {
+			alice.Send(fmt.Sprintf("m%d", i))
+		}
+		closed.Add(1)
+		go func() {
+			defer closed.Done()
+			mediator.Close()
+		}()
+		time.Sleep(10 * time.Millisecond)
+		if slow.ReceiveCallCount() != 0 {
+			t.Error("Expected nothing to be delivered before Slow is released")
+		}
+		slow.Release()
+		closed.Wait()
+	})
+
+	if slow.ReceiveCallCount() != 5 {
+		t.Errorf("Expected Close to deliver all 5 queued messages, got %d", slow.ReceiveCallCount())
+	}
+	if err := mediator.Join(NewMockUser("Late")); err != cr.ErrRoomClosed {
+		t.Errorf("Expected ErrRoomClosed, got %v", err)
+	}
+	output := captureOutput(func() {
+		alice.Send("after close")
+	})
+	if output != "" {
+		t.Errorf("Expected messages after Close to be ignored, got: %s", output)
+	}
+}
@@ -0,0 +1,116 @@
+package chat_room
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// ErrNoRecipient is returned when a direct message names no recipient.
+var ErrNoRecipient = errors.New("direct message without a recipient")
+
+// MessageKind tells how a message is meant to be shown.
+type MessageKind int
+
+const (
+	// ChatMessage is an ordinary message to everyone in the room.
+	ChatMessage MessageKind = iota
+	// SystemMessage is an announcement from the room itself.
+	SystemMessage
+	// ActionMessage describes what the sender does, like "/me waves".
+	ActionMessage
+	// DirectMessage goes to a single member of the room.
+	DirectMessage
+)
+
+func (k MessageKind) String() string {
+	switch k {
+	case ChatMessage:
+		return "chat"
+	case SystemMessage:
+		return "system"
+	case ActionMessage:
+		return "action"
+	case DirectMessage:
+		return "dm"
+	}
+	return "unknown"
+}
+
+// Message is a structured chat message. The room fills in ID, Timestamp,
+// Room, Sender and Mentions when the message is posted; recipients get a
+// copy with Mentioned set if they were @mentioned. Recipients share the
+// Mentions slice and Metadata map and must not modify them.
+type Message struct {
+	ID        uint64 // Increases with every message posted in a room
+	Timestamp time.Time
+	Room      string
+	Sender    string // Empty for system messages
+	Kind      MessageKind
+	Body      string
+	To        string            // Recipient of a direct message
+	Mentions  []string          // Names @mentioned in the body, in order
+	Mentioned bool              // Whether the recipient was @mentioned
+	Replayed  bool              // Sent before the recipient joined, from the room history
+	Metadata  map[string]string // Free-form annotations
+}
+
+// Text renders the message as the plain string given to User.Receive.
+func (m Message) Text() string {
+	if m.Kind == ActionMessage {
+		return "* " + m.Sender + " " + m.Body
+	}
+	return m.Body
+}
+
+// VisibleTo reports whether a member may see the message: messages to a
+// single member, such as direct messages, are only visible to their sender
+// and recipient.
+func (m Message) VisibleTo(name string) bool {
+	return m.To == "" || m.Sender == name || m.To == name
+}
+
+// MentionsUser reports whether name is @mentioned in the message.
+func (m Message) MentionsUser(name string) bool {
+	return slices.Contains(m.Mentions, name)
+}
+
+// mentionPattern matches @name, where a name is made of letters, digits,
+// underscores, dots and dashes and does not end with a dot
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]*[\w-])`)
+
+// ParseMentions returns the distinct names @mentioned in a body, in order
+// of first appearance. E-mail addresses are not mentions.
+func ParseMentions(body string) []string {
+	var names []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		if !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// MessageReceiver is implemented by users that want structured messages.
+// The room calls ReceiveMessage instead of Receive for them; other users
+// get Receive(msg.Text(), msg.Sender).
+type MessageReceiver interface {
+	ReceiveMessage(msg Message)
+}
+
+// MessageMediator is a ChatMediator that also routes structured messages,
+// such as direct messages and actions.
+type MessageMediator interface {
+	ChatMediator
+	Post(msg Message, sender User) error
+}
+
+// deliver hands a message to a user through the richest path it supports
+func deliver(user User, msg Message) {
+	if receiver, ok := user.(MessageReceiver); ok {
+		receiver.ReceiveMessage(msg)
+		return
+	}
+	user.Receive(msg.Text(), msg.Sender)
+}
@@ -0,0 +1,150 @@
+package chat_room_test
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	cr "mediator_pattern_chat_room_go/chat_room"
+)
+
+// StructuredUser is a MockUser that takes the structured receive path.
+type StructuredUser struct {
+	*MockUser
+	messages []cr.Message
+	lock     sync.Mutex
+}
+
+func NewStructuredUser(name string) *StructuredUser {
+	return &StructuredUser{MockUser: NewMockUser(name)}
+}
+
+func (su *StructuredUser) ReceiveMessage(msg cr.Message) {
+	su.lock.Lock()
+	defer su.lock.Unlock()
+	su.messages = append(su.messages, msg)
+}
+
+func (su *StructuredUser) Received() []cr.Message {
+	su.lock.Lock()
+	defer su.lock.Unlock()
+	return slices.Clone(su.messages)
+}
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected []string
+	}{
+		{"hello", nil},
+		{"@Alice hi", []string{"Alice"}},
+		{"hi @Bob and @Alice, and @Bob again", []string{"Bob", "Alice"}},
+		{"ask @bob.smith.", []string{"bob.smith"}},
+		{"mail alice@example.com", nil},
+		{"(@Carol) @@Dave", []string{"Carol"}},
+	}
+	for _, tt := range tests {
+		if got := cr.ParseMentions(tt.body); !slices.Equal(got, tt.expected) {
+			t.Errorf("ParseMentions(%q): expected %v, got %v", tt.body, tt.expected, got)
+		}
+	}
+}
+
+func TestChatRoom_StructuredMessages(t *testing.T) {
+	lobby := cr.NewLobby()
+	alice := cr.NewChatUser("Alice")
+	bob := NewStructuredUser("Bob")
+	carol := NewMockUser("Carol") // Only has the string path
+	var room *cr.ChatRoom
+	captureOutput(func() {
+		room, _ = lobby.CreateRoom("general", cr.RoomOptions{})
+		room.AddUser(alice)
+		room.AddUser(bob)
+		room.AddUser(carol)
+
+		alice.Send("Hi @Bob")
+		alice.SendAction("waves")
+		room.Announce("Maintenance at noon")
+		room.Flush()
+	})
+
+	received := bob.Received()
+	if len(received) != 3 {
+		t.Fatalf("Expected Bob to receive 3 messages, got %d", len(received))
+	}
+	chat, action, system := received[0], received[1], received[2]
+	if chat.Kind != cr.ChatMessage || chat.Room != "general" || chat.Sender != "Alice" || chat.Body != "Hi @Bob" {
+		t.Errorf("Unexpected chat message: %+v", chat)
+	}
+	if !chat.Mentioned || !slices.Equal(chat.Mentions, []string{"Bob"}) {
+		t.Errorf("Expected Bob to be flagged as mentioned, got %+v", chat)
+	}
+	if chat.Timestamp.IsZero() || !(chat.ID < action.ID && action.ID < system.ID) {
+		t.Errorf("Expected timestamps and increasing IDs, got %d, %d, %d", chat.ID, action.ID, system.ID)
+	}
+	if action.Kind != cr.ActionMessage || action.Text() != "* Alice waves" || action.Mentioned {
+		t.Errorf("Unexpected action message: %+v", action)
+	}
+	if system.Kind != cr.SystemMessage || system.Sender != "" {
+		t.Errorf("Unexpected system message: %+v", system)
+	}
+
+	// The string path renders the same messages as text
+	for _, expected := range [][2]string{{"Hi @Bob", "Alice"}, {"* Alice waves", "Alice"}, {"Maintenance at noon", ""}} {
+		if !carol.WasCalledWith(expected[0], expected[1]) {
+			t.Errorf("Expected Carol to receive %q from %q", expected[0], expected[1])
+		}
+	}
+}
+
+func TestChatRoom_DirectMessages(t *testing.T) {
+	room := cr.NewChatRoom()
+	alice := cr.NewChatUser("Alice")
+	bob := NewStructuredUser("Bob")
+	carol := NewStructuredUser("Carol")
+	output := captureOutput(func() {
+		room.AddUser(alice)
+		room.AddUser(bob)
+		room.AddUser(carol)
+		if err := alice.SendDirect("Bob", "Lunch, @Bob?"); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err := alice.SendDirect("Dave", "Hello?"); err != cr.ErrNotInRoom {
+			t.Errorf("Expected ErrNotInRoom for an unknown recipient, got %v", err)
+		}
+		if err := room.Post(cr.Message{Kind: cr.DirectMessage, Body: "To whom?"}, alice); err != cr.ErrNoRecipient {
+			t.Errorf("Expected ErrNoRecipient, got %v", err)
+		}
+		room.Flush()
+	})
+
+	if got := bob.Received(); len(got) != 1 || got[0].Kind != cr.DirectMessage || got[0].To != "Bob" || !got[0].Mentioned {
+		t.Errorf("Expected Bob to receive one direct message mentioning him, got %+v", got)
+	}
+	if got := carol.Received(); len(got) != 0 {
+		t.Errorf("Expected Carol to receive nothing, got %+v", got)
+	}
+	if strings.Contains(output, "Lunch") {
+		t.Errorf("Expected the body of a direct message to stay out of the log, got: %s", output)
+	}
+}
+
+func TestChatUser_ReceiveMessage(t *testing.T) {
+	user := cr.NewChatUser("Bob")
+	tests := []struct {
+		msg      cr.Message
+		expected string
+	}{
+		{cr.Message{Kind: cr.ChatMessage, Sender: "Alice", Body: "Hi"}, "Bob received: Hi (from Alice)\n"},
+		{cr.Message{Kind: cr.ChatMessage, Sender: "Alice", Body: "Hi @Bob", Mentioned: true}, "Bob received: Hi @Bob (from Alice) [mentioned]\n"},
+		{cr.Message{Kind: cr.ActionMessage, Sender: "Alice", Body: "waves"}, "Bob sees: * Alice waves\n"},
+		{cr.Message{Kind: cr.DirectMessage, Sender: "Alice", To: "Bob", Body: "psst"}, "Bob received direct message: psst (from Alice)\n"},
+		{cr.Message{Kind: cr.SystemMessage, Body: "Closing soon"}, "Bob received system message: Closing soon\n"},
+	}
+	for _, tt := range tests {
+		if output := captureOutput(func() { user.ReceiveMessage(tt.msg) }); output != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, output)
+		}
+	}
+}
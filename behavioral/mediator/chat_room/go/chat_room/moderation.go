@@ -0,0 +1,440 @@
+package chat_room
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrPermissionDenied is returned when a member's role does not allow a
+	// moderation action.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrBanned is returned when a banned user tries to join.
+	ErrBanned = errors.New("banned from the chat room")
+	// ErrMuted is returned when a muted member tries to send a message.
+	ErrMuted = errors.New("muted in the chat room")
+)
+
+// Role is what a member may do in a room. Higher roles include the rights
+// of lower ones.
+type Role int
+
+const (
+	// Member may chat.
+	Member Role = iota
+	// Moderator may also mute, kick and ban members.
+	Moderator
+	// Owner may also moderate moderators and change roles.
+	Owner
+)
+
+func (r Role) String() string {
+	switch r {
+	case Member:
+		return "member"
+	case Moderator:
+		return "moderator"
+	case Owner:
+		return "owner"
+	}
+	return "unknown"
+}
+
+// ModerationAction identifies an entry of the moderation log.
+type ModerationAction int
+
+const (
+	ActionSetRole ModerationAction = iota
+	ActionMute
+	ActionUnmute
+	ActionKick
+	ActionBan
+	ActionUnban
+	ActionBlock // A filter or a mute blocked a message
+)
+
+func (a ModerationAction) String() string {
+	switch a {
+	case ActionSetRole:
+		return "set role"
+	case ActionMute:
+		return "mute"
+	case ActionUnmute:
+		return "unmute"
+	case ActionKick:
+		return "kick"
+	case ActionBan:
+		return "ban"
+	case ActionUnban:
+		return "unban"
+	case ActionBlock:
+		return "block"
+	}
+	return "unknown"
+}
+
+// ModerationEntry records a moderation action.
+type ModerationEntry struct {
+	Time   time.Time
+	Room   string
+	Action ModerationAction
+	Actor  string // Empty for actions taken by the room, such as filters
+	Target string
+	Until  time.Time // End of a mute or ban; zero if it does not expire
+	Reason string
+}
+
+// BlockedError explains why a message was not delivered. The sender also
+// gets the explanation as a system message.
+type BlockedError struct {
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return "message blocked: " + e.Reason
+}
+
+// --- Filters ---
+
+// Filter inspects a message before it is delivered. It may return a changed
+// message, or an error explaining why the message is blocked.
+type Filter interface {
+	Filter(msg Message) (Message, error)
+}
+
+// FilterFunc adapts a function to the Filter interface.
+type FilterFunc func(msg Message) (Message, error)
+
+func (f FilterFunc) Filter(msg Message) (Message, error) {
+	return f(msg)
+}
+
+// ProfanityFilter blocks messages containing any of the words, ignoring case.
+func ProfanityFilter(words ...string) Filter {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	pattern := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return FilterFunc(func(msg Message) (Message, error) {
+		if len(words) > 0 && pattern.MatchString(msg.Body) {
+			return msg, errors.New("it contains a word that is not allowed here")
+		}
+		return msg, nil
+	})
+}
+
+// linkPattern matches web addresses
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkFilter blocks messages containing links.
+func LinkFilter() Filter {
+	return FilterFunc(func(msg Message) (Message, error) {
+		if linkPattern.MatchString(msg.Body) {
+			return msg, errors.New("links are not allowed here")
+		}
+		return msg, nil
+	})
+}
+
+// MaxLengthFilter blocks messages longer than limit characters.
+func MaxLengthFilter(limit int) Filter {
+	return FilterFunc(func(msg Message) (Message, error) {
+		if length := utf8.RuneCountInString(msg.Body); length > limit {
+			return msg, fmt.Errorf("it is %d characters long, the limit is %d", length, limit)
+		}
+		return msg, nil
+	})
+}
+
+// --- Moderation of a ChatRoom ---
+
+// AddFilter appends a filter to the pipeline messages from members go
+// through, in the order filters were added.
+func (cr *ChatRoom) AddFilter(filter Filter) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.filters = append(cr.filters, filter)
+}
+
+// Grant gives a member name a role without a permission check, to set up
+// the owner of a room.
+func (cr *ChatRoom) Grant(name string, role Role) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.grant(name, role)
+}
+
+// grant sets a role. The caller holds the lock.
+func (cr *ChatRoom) grant(name string, role Role) {
+	if role == Member {
+		delete(cr.roles, name)
+		return
+	}
+	cr.roles[name] = role
+}
+
+// Role returns the role of a member name.
+func (cr *ChatRoom) Role(name string) Role {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	return cr.roles[name]
+}
+
+// SetRole changes the role of target. Only owners may change roles.
+func (cr *ChatRoom) SetRole(actor User, target string, role Role) error {
+	cr.mutex.Lock()
+	if cr.roleOf(actor) != Owner {
+		cr.mutex.Unlock()
+		return ErrPermissionDenied
+	}
+	cr.grant(target, role)
+	cr.mutex.Unlock()
+
+	cr.record(ModerationEntry{Action: ActionSetRole, Actor: actor.GetName(), Target: target, Reason: role.String()})
+	cr.tell(target, fmt.Sprintf("%s made you %s.", actor.GetName(), role))
+	return nil
+}
+
+// roleOf returns the role of a member, or -1 for someone not in the room.
+// The caller holds the lock.
+func (cr *ChatRoom) roleOf(user User) Role {
+	if _, ok := cr.users[user.GetName()]; !ok {
+		return -1
+	}
+	return cr.roles[user.GetName()]
+}
+
+// authorize checks that actor may moderate target: a moderator at least,
+// and above the target. The caller holds the lock.
+func (cr *ChatRoom) authorize(actor User, target string) error {
+	role := cr.roleOf(actor)
+	if role < Moderator || role <= cr.roles[target] {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// until returns the end of a sanction lasting d, or zero for no expiry
+func until(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// active reports whether a sanction ending at end is still in force; ok is
+// false when there is no sanction
+func active(end time.Time, ok bool) bool {
+	return ok && (end.IsZero() || time.Now().Before(end))
+}
+
+// Mute stops target from sending messages for d; zero mutes until Unmute.
+func (cr *ChatRoom) Mute(actor User, target string, d time.Duration, reason string) error {
+	cr.mutex.Lock()
+	if err := cr.authorize(actor, target); err != nil {
+		cr.mutex.Unlock()
+		return err
+	}
+	if _, ok := cr.users[target]; !ok {
+		cr.mutex.Unlock()
+		return ErrNotInRoom
+	}
+	end := until(d)
+	cr.mutes[target] = end
+	cr.mutex.Unlock()
+
+	cr.record(ModerationEntry{Action: ActionMute, Actor: actor.GetName(), Target: target, Until: end, Reason: reason})
+	cr.tell(target, explain(fmt.Sprintf("You were muted by %s", actor.GetName()), end, reason))
+	return nil
+}
+
+// Unmute lets target send messages again.
+func (cr *ChatRoom) Unmute(actor User, target string) error {
+	cr.mutex.Lock()
+	if err := cr.authorize(actor, target); err != nil {
+		cr.mutex.Unlock()
+		return err
+	}
+	delete(cr.mutes, target)
+	cr.mutex.Unlock()
+
+	cr.record(ModerationEntry{Action: ActionUnmute, Actor: actor.GetName(), Target: target})
+	cr.tell(target, fmt.Sprintf("%s unmuted you.", actor.GetName()))
+	return nil
+}
+
+// Kick removes target from the room, telling them why first.
+func (cr *ChatRoom) Kick(actor User, target string, reason string) error {
+	cr.mutex.Lock()
+	if err := cr.authorize(actor, target); err != nil {
+		cr.mutex.Unlock()
+		return err
+	}
+	user, ok := cr.users[target]
+	cr.mutex.Unlock()
+	if !ok {
+		return ErrNotInRoom
+	}
+
+	cr.record(ModerationEntry{Action: ActionKick, Actor: actor.GetName(), Target: target, Reason: reason})
+	cr.tell(target, explain(fmt.Sprintf("You were kicked by %s", actor.GetName()), time.Time{}, reason))
+	cr.Leave(user) // Queued messages, including the explanation, are still delivered
+	return nil
+}
+
+// Ban keeps the name target out of the room for d, or until Unban if d is
+// zero, and kicks them if they are in the room.
+func (cr *ChatRoom) Ban(actor User, target string, d time.Duration, reason string) error {
+	cr.mutex.Lock()
+	if err := cr.authorize(actor, target); err != nil {
+		cr.mutex.Unlock()
+		return err
+	}
+	end := until(d)
+	cr.bans[target] = end
+	user, present := cr.users[target]
+	cr.mutex.Unlock()
+
+	cr.record(ModerationEntry{Action: ActionBan, Actor: actor.GetName(), Target: target, Until: end, Reason: reason})
+	if present {
+		cr.tell(target, explain(fmt.Sprintf("You were banned by %s", actor.GetName()), end, reason))
+		cr.Leave(user)
+	}
+	return nil
+}
+
+// Unban lets the name target join again.
+func (cr *ChatRoom) Unban(actor User, target string) error {
+	cr.mutex.Lock()
+	if err := cr.authorize(actor, target); err != nil {
+		cr.mutex.Unlock()
+		return err
+	}
+	delete(cr.bans, target)
+	cr.mutex.Unlock()
+
+	cr.record(ModerationEntry{Action: ActionUnban, Actor: actor.GetName(), Target: target})
+	return nil
+}
+
+// IsBanned reports whether the name is banned from the room.
+func (cr *ChatRoom) IsBanned(name string) bool {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	return cr.banned(name)
+}
+
+// banned reports whether a ban on the name is in force, forgetting one that
+// expired. The caller holds the write lock.
+func (cr *ChatRoom) banned(name string) bool {
+	end, ok := cr.bans[name]
+	if ok && !active(end, ok) {
+		delete(cr.bans, name)
+	}
+	return active(end, ok)
+}
+
+// IsMuted reports whether the member name is muted.
+func (cr *ChatRoom) IsMuted(name string) bool {
+	return cr.muted(name)
+}
+
+// muted reports whether a mute on the name is in force, forgetting one that
+// expired. Only expired mutes take the write lock, so checking each message
+// doesn't serialize posting.
+func (cr *ChatRoom) muted(name string) bool {
+	cr.mutex.RLock()
+	end, ok := cr.mutes[name]
+	cr.mutex.RUnlock()
+	if !ok || active(end, ok) {
+		return ok
+	}
+	cr.mutex.Lock()
+	if end, ok := cr.mutes[name]; ok && !active(end, ok) {
+		delete(cr.mutes, name)
+	}
+	cr.mutex.Unlock()
+	return false
+}
+
+// ModerationLog returns the moderation actions taken in the room, oldest
+// first.
+func (cr *ChatRoom) ModerationLog() []ModerationEntry {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	return slices.Clone(cr.moderationLog)
+}
+
+// record adds an entry to the moderation log
+func (cr *ChatRoom) record(entry ModerationEntry) {
+	entry.Time = time.Now()
+	entry.Room = cr.name
+	cr.mutex.Lock()
+	cr.moderationLog = append(cr.moderationLog, entry)
+	cr.mutex.Unlock()
+
+	actor := entry.Actor
+	if actor == "" {
+		actor = "The room"
+	}
+	log.Printf("--- Moderation in %s: %s applied %s to %s. ---", cr.label(), actor, entry.Action, entry.Target)
+}
+
+// explain words a sanction for the member it applies to
+func explain(what string, end time.Time, reason string) string {
+	if !end.IsZero() {
+		what += " until " + end.Format(time.Kitchen)
+	}
+	if reason != "" {
+		what += ": " + reason
+	}
+	return what + "."
+}
+
+// tell sends a system message to a single member
+func (cr *ChatRoom) tell(name string, body string) {
+	cr.Post(Message{Kind: SystemMessage, To: name, Body: body}, nil)
+}
+
+// refuse logs a blocked message and tells the sender why it was blocked
+func (cr *ChatRoom) refuse(msg Message, err error) {
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) {
+		cr.record(ModerationEntry{Action: ActionBlock, Target: msg.Sender, Reason: "muted"})
+		cr.tell(msg.Sender, "You are muted; your message was not sent.")
+		return
+	}
+	cr.record(ModerationEntry{Action: ActionBlock, Target: msg.Sender, Reason: blocked.Reason})
+	cr.tell(msg.Sender, "Your message was not sent: "+blocked.Reason+".")
+}
+
+// screen applies mutes and filters to a message from a member, returning the
+// message to deliver or why it is blocked. Filters run without the lock, so
+// a slow filter doesn't hold up the room.
+func (cr *ChatRoom) screen(msg Message) (Message, error) {
+	if cr.muted(msg.Sender) {
+		return msg, ErrMuted
+	}
+	cr.mutex.RLock()
+	filters := cr.filters
+	cr.mutex.RUnlock()
+	for _, filter := range filters {
+		filtered, err := filter.Filter(msg)
+		if err != nil {
+			var blocked *BlockedError
+			if !errors.As(err, &blocked) {
+				blocked = &BlockedError{Reason: err.Error()}
+			}
+			return msg, blocked
+		}
+		msg = filtered
+	}
+	return msg, nil
+}
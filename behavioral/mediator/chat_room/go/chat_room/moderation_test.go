@@ -0,0 +1,345 @@
+package chat_room_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	cr "mediator_pattern_chat_room_go/chat_room"
+)
+
+// moderatedRoom returns a room owned by Olivia, moderated by Mona, with
+// members Bob and Carol.
+func moderatedRoom(t *testing.T) (room *cr.ChatRoom, owner, moderator, bob, carol *StructuredUser) {
+	t.Helper()
+	lobby := cr.NewLobby()
+	owner = NewStructuredUser("Olivia")
+	moderator = NewStructuredUser("Mona")
+	bob = NewStructuredUser("Bob")
+	carol = NewStructuredUser("Carol")
+	captureOutput(func() {
+		room, _ = lobby.CreateRoom("general", cr.RoomOptions{Owner: "Olivia"})
+		for _, user := range []*StructuredUser{owner, moderator, bob, carol} {
+			if err := room.Join(user); err != nil {
+				t.Fatalf("Expected %s to join, got %v", user.GetName(), err)
+			}
+		}
+		if err := room.SetRole(owner, "Mona", cr.Moderator); err != nil {
+			t.Fatalf("Expected the owner to make Mona a moderator, got %v", err)
+		}
+	})
+	return room, owner, moderator, bob, carol
+}
+
+// systemNotices returns the bodies of the system messages a user got
+func systemNotices(user *StructuredUser) []string {
+	var bodies []string
+	for _, msg := range user.Received() {
+		if msg.Kind == cr.SystemMessage {
+			bodies = append(bodies, msg.Body)
+		}
+	}
+	return bodies
+}
+
+// said returns the bodies of the chat messages a user got
+func said(user *StructuredUser) []string {
+	var bodies []string
+	for _, msg := range user.Received() {
+		if msg.Kind == cr.ChatMessage {
+			bodies = append(bodies, msg.Body)
+		}
+	}
+	return bodies
+}
+
+func TestModeration_Permissions(t *testing.T) {
+	room, owner, moderator, bob, _ := moderatedRoom(t)
+
+	tests := []struct {
+		name   string
+		actor  cr.User
+		target string
+		err    error
+	}{
+		{"member mutes member", bob, "Carol", cr.ErrPermissionDenied},
+		{"member mutes moderator", bob, "Mona", cr.ErrPermissionDenied},
+		{"moderator mutes owner", moderator, "Olivia", cr.ErrPermissionDenied},
+		{"moderator mutes member", moderator, "Carol", nil},
+		{"owner mutes moderator", owner, "Mona", nil},
+		{"stranger mutes member", NewStructuredUser("Eve"), "Carol", cr.ErrPermissionDenied},
+	}
+	for _, tt := range tests {
+		var err error
+		captureOutput(func() { err = room.Mute(tt.actor, tt.target, 0, "") })
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+
+	captureOutput(func() {
+		if err := room.SetRole(moderator, "Bob", cr.Moderator); !errors.Is(err, cr.ErrPermissionDenied) {
+			t.Errorf("Expected a moderator not to change roles, got %v", err)
+		}
+	})
+	if role := room.Role("Olivia"); role != cr.Owner {
+		t.Errorf("Expected Olivia to be the owner, got %v", role)
+	}
+	if role := room.Role("Bob"); role != cr.Member {
+		t.Errorf("Expected Bob to be a member, got %v", role)
+	}
+}
+
+func TestModeration_MuteExpires(t *testing.T) {
+	room, _, moderator, bob, carol := moderatedRoom(t)
+
+	captureOutput(func() {
+		if err := room.Mute(moderator, "Bob", 50*time.Millisecond, "calm down"); err != nil {
+			t.Fatalf("Expected the mute to succeed, got %v", err)
+		}
+		if err := room.Post(cr.Message{Body: "muted"}, bob); !errors.Is(err, cr.ErrMuted) {
+			t.Errorf("Expected ErrMuted, got %v", err)
+		}
+		room.Flush()
+	})
+	if !room.IsMuted("Bob") {
+		t.Errorf("Expected Bob to be muted")
+	}
+	if log := room.ModerationLog(); log[len(log)-1].Action != cr.ActionBlock || log[len(log)-1].Target != "Bob" {
+		t.Errorf("Expected the blocked message to be logged, got %+v", log[len(log)-1])
+	}
+	if got := said(carol); len(got) != 0 {
+		t.Errorf("Expected Carol to get nothing from a muted Bob, got %v", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if room.IsMuted("Bob") {
+		t.Errorf("Expected the mute to have expired")
+	}
+	captureOutput(func() {
+		if err := room.Post(cr.Message{Body: "free"}, bob); err != nil {
+			t.Errorf("Expected the mute to have expired, got %v", err)
+		}
+		room.Flush()
+	})
+	if got := said(carol); len(got) != 1 || got[0] != "free" {
+		t.Errorf("Expected Carol to get [free], got %v", got)
+	}
+
+	notices := systemNotices(bob)
+	if len(notices) != 2 || !strings.HasPrefix(notices[0], "You were muted by Mona until ") || !strings.HasSuffix(notices[0], ": calm down.") {
+		t.Errorf("Expected the mute to be explained, got %v", notices)
+	} else if notices[1] != "You are muted; your message was not sent." {
+		t.Errorf("Expected the blocked message to be explained, got %q", notices[1])
+	}
+	for _, notice := range systemNotices(carol) {
+		if strings.Contains(notice, "muted") {
+			t.Errorf("Expected mute notices to be private, Carol got %q", notice)
+		}
+	}
+}
+
+func TestModeration_KickExplainsBeforeRemoving(t *testing.T) {
+	room, _, moderator, bob, _ := moderatedRoom(t)
+
+	captureOutput(func() {
+		if err := room.Kick(moderator, "Bob", "spam"); err != nil {
+			t.Fatalf("Expected the kick to succeed, got %v", err)
+		}
+		room.Close()
+	})
+	if notices := systemNotices(bob); len(notices) != 1 || notices[0] != "You were kicked by Mona: spam." {
+		t.Errorf("Expected the kick to be explained, got %v", notices)
+	}
+	for _, member := range room.Members() {
+		if member == "Bob" {
+			t.Errorf("Expected Bob to be kicked")
+		}
+	}
+	if err := room.Kick(moderator, "Bob", ""); !errors.Is(err, cr.ErrNotInRoom) {
+		t.Errorf("Expected ErrNotInRoom for a second kick, got %v", err)
+	}
+}
+
+func TestModeration_BanPreventsRejoin(t *testing.T) {
+	room, owner, _, bob, _ := moderatedRoom(t)
+
+	captureOutput(func() {
+		if err := room.Ban(owner, "Bob", time.Hour, "trolling"); err != nil {
+			t.Fatalf("Expected the ban to succeed, got %v", err)
+		}
+		if err := room.Join(bob); !errors.Is(err, cr.ErrBanned) {
+			t.Errorf("Expected ErrBanned, got %v", err)
+		}
+		if err := room.Ban(owner, "Eve", 50*time.Millisecond, ""); err != nil {
+			t.Errorf("Expected to ban a name that is not in the room, got %v", err)
+		}
+	})
+	if !room.IsBanned("Bob") || !room.IsBanned("Eve") {
+		t.Errorf("Expected Bob and Eve to be banned")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if room.IsBanned("Eve") {
+		t.Errorf("Expected Eve's ban to have expired")
+	}
+
+	captureOutput(func() {
+		if err := room.Unban(owner, "Bob"); err != nil {
+			t.Fatalf("Expected the unban to succeed, got %v", err)
+		}
+		if err := room.Join(bob); err != nil {
+			t.Errorf("Expected Bob to rejoin after the unban, got %v", err)
+		}
+	})
+}
+
+func TestModeration_Filters(t *testing.T) {
+	room, _, _, bob, carol := moderatedRoom(t)
+	room.AddFilter(cr.ProfanityFilter("darn", "heck"))
+	room.AddFilter(cr.LinkFilter())
+	room.AddFilter(cr.MaxLengthFilter(20))
+
+	tests := []struct {
+		body   string
+		reason string // Empty if the message goes through
+	}{
+		{"hello there", ""},
+		{"Oh DARN it", "it contains a word that is not allowed here"},
+		{"darned shoes", ""},
+		{"see https://example.com", "links are not allowed here"},
+		{"visit www.example.com", "links are not allowed here"},
+		{"this message is far too long", "it is 28 characters long, the limit is 20"},
+	}
+	var delivered, explained []string
+	for _, tt := range tests {
+		var err error
+		captureOutput(func() { err = room.Post(cr.Message{Body: tt.body}, bob) })
+		var blocked *cr.BlockedError
+		switch {
+		case tt.reason == "" && err != nil:
+			t.Errorf("Expected %q to go through, got %v", tt.body, err)
+		case tt.reason == "":
+			delivered = append(delivered, tt.body)
+		case !errors.As(err, &blocked) || blocked.Reason != tt.reason:
+			t.Errorf("Expected %q to be blocked because %s, got %v", tt.body, tt.reason, err)
+		default:
+			explained = append(explained, "Your message was not sent: "+tt.reason+".")
+		}
+	}
+	captureOutput(room.Flush)
+
+	if got := said(carol); strings.Join(got, "|") != strings.Join(delivered, "|") {
+		t.Errorf("Expected Carol to get %v, got %v", delivered, got)
+	}
+	if got := systemNotices(bob); strings.Join(got, "|") != strings.Join(explained, "|") {
+		t.Errorf("Expected Bob to get the explanations %v, got %v", explained, got)
+	}
+
+	blocks := 0
+	for _, entry := range room.ModerationLog() {
+		if entry.Action == cr.ActionBlock {
+			blocks++
+			if entry.Target != "Bob" || entry.Actor != "" || entry.Room != "general" {
+				t.Errorf("Unexpected block entry: %+v", entry)
+			}
+		}
+	}
+	if blocks != len(explained) {
+		t.Errorf("Expected %d block entries, got %d", len(explained), blocks)
+	}
+}
+
+func TestModeration_FilterFuncTransforms(t *testing.T) {
+	room, _, _, bob, carol := moderatedRoom(t)
+	room.AddFilter(cr.FilterFunc(func(msg cr.Message) (cr.Message, error) {
+		msg.Body = strings.ToLower(msg.Body)
+		return msg, nil
+	}))
+
+	captureOutput(func() {
+		room.Post(cr.Message{Body: "STOP SHOUTING"}, bob)
+		room.Announce("SYSTEM NOTICES ARE NOT FILTERED")
+		room.Flush()
+	})
+	if got := said(carol); len(got) != 1 || got[0] != "stop shouting" {
+		t.Errorf("Expected Carol to get [stop shouting], got %v", got)
+	}
+	if got := systemNotices(carol); len(got) != 1 || got[0] != "SYSTEM NOTICES ARE NOT FILTERED" {
+		t.Errorf("Expected the announcement to be left alone, got %v", got)
+	}
+}
+
+func TestModeration_FilterMayUseTheRoom(t *testing.T) {
+	room, _, _, bob, carol := moderatedRoom(t)
+	// Filters run without the room lock, so they may moderate
+	room.AddFilter(cr.FilterFunc(func(msg cr.Message) (cr.Message, error) {
+		room.Grant(msg.Sender, cr.Moderator)
+		return msg, nil
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		captureOutput(func() {
+			room.Post(cr.Message{Body: "promote me"}, bob)
+			room.Flush()
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a filter using the room not to deadlock")
+	}
+	if room.Role("Bob") != cr.Moderator {
+		t.Errorf("Expected the filter to make Bob a moderator, got %v", room.Role("Bob"))
+	}
+	if got := said(carol); len(got) != 1 {
+		t.Errorf("Expected Carol to get Bob's message, got %v", got)
+	}
+}
+
+func TestModeration_Log(t *testing.T) {
+	room, owner, moderator, _, _ := moderatedRoom(t)
+
+	captureOutput(func() {
+		room.Mute(moderator, "Bob", time.Minute, "noise")
+		room.Unmute(moderator, "Bob")
+		room.Kick(moderator, "Carol", "")
+		room.Ban(owner, "Carol", 0, "repeat offender")
+		room.Unban(owner, "Carol")
+	})
+
+	expected := []struct {
+		action cr.ModerationAction
+		actor  string
+		target string
+	}{
+		{cr.ActionSetRole, "Olivia", "Mona"},
+		{cr.ActionMute, "Mona", "Bob"},
+		{cr.ActionUnmute, "Mona", "Bob"},
+		{cr.ActionKick, "Mona", "Carol"},
+		{cr.ActionBan, "Olivia", "Carol"},
+		{cr.ActionUnban, "Olivia", "Carol"},
+	}
+	entries := room.ModerationLog()
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d log entries, got %d: %+v", len(expected), len(entries), entries)
+	}
+	for i, want := range expected {
+		entry := entries[i]
+		if entry.Action != want.action || entry.Actor != want.actor || entry.Target != want.target {
+			t.Errorf("Entry %d: expected %v by %s on %s, got %+v", i, want.action, want.actor, want.target, entry)
+		}
+		if entry.Time.IsZero() || entry.Room != "general" {
+			t.Errorf("Entry %d: expected a time and the room, got %+v", i, entry)
+		}
+	}
+	if entries[1].Until.IsZero() || entries[1].Reason != "noise" {
+		t.Errorf("Expected the mute entry to record its end and reason, got %+v", entries[1])
+	}
+	if !entries[4].Until.IsZero() || entries[4].Reason != "repeat offender" {
+		t.Errorf("Expected a permanent ban with its reason, got %+v", entries[4])
+	}
+}
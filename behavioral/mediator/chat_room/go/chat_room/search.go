@@ -0,0 +1,313 @@
+package chat_room
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultSearchLimit is the page size of a search that sets no Limit.
+const DefaultSearchLimit = 20
+
+// DefaultHalfLife is how much older a message must be for its score to
+// halve, unless SetHalfLife says otherwise.
+const DefaultHalfLife = 7 * 24 * time.Hour
+
+// SearchQuery selects messages from a SearchIndex. Every condition set must
+// hold.
+type SearchQuery struct {
+	// Text holds the words to find, in any order, and "quoted phrases" to
+	// find word for word. Matching ignores case and punctuation.
+	Text   string
+	Sender string
+	Room   string
+	Since  time.Time // Inclusive; zero for no lower bound
+	Until  time.Time // Exclusive; zero for no upper bound
+	// Viewer is the member searching. Messages to a single member are only
+	// found by their sender and recipient.
+	Viewer string
+	Offset int // Hits to skip, for the following pages
+	Limit  int // Hits per page; DefaultSearchLimit if zero
+}
+
+// SearchHit is a message found by a search.
+type SearchHit struct {
+	Message Message
+	Score   float64
+}
+
+// SearchResults is a page of hits, best first.
+type SearchResults struct {
+	Hits  []SearchHit
+	Total int // Hits on every page
+}
+
+// posting lists where a term appears in a message
+type posting struct {
+	doc       int
+	positions []int
+}
+
+// docKey identifies a message across rooms. The source is the room name for
+// messages given to Add, and the history or room instance otherwise, so
+// unnamed and recreated rooms don't collide.
+type docKey struct {
+	source any
+	id     uint64
+}
+
+// SearchIndex is an inverted index over chat messages, safe for concurrent
+// use. Rooms given one with SetSearchIndex add every message they post to
+// it; RebuildSearchIndex recreates one from room histories.
+type SearchIndex struct {
+	mutex    sync.RWMutex
+	docs     []Message
+	lengths  []int                // Words in each message
+	postings map[string][]posting // By term, in document order
+	seen     map[docKey]struct{}
+	halfLife time.Duration
+}
+
+// NewSearchIndex creates an empty index.
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings: make(map[string][]posting),
+		seen:     make(map[docKey]struct{}),
+		halfLife: DefaultHalfLife,
+	}
+}
+
+// RebuildSearchIndex creates an index holding every message of the
+// histories.
+func RebuildSearchIndex(histories ...*History) (*SearchIndex, error) {
+	index := NewSearchIndex()
+	for _, history := range histories {
+		if err := index.AddHistory(history); err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+// SetHalfLife sets how fast older messages lose rank; zero ranks by text
+// alone.
+func (idx *SearchIndex) SetHalfLife(halfLife time.Duration) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	idx.halfLife = halfLife
+}
+
+// Len returns the number of indexed messages.
+func (idx *SearchIndex) Len() int {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	return len(idx.docs)
+}
+
+// Add indexes a message. A message already indexed, by room and ID, is
+// ignored.
+func (idx *SearchIndex) Add(msg Message) {
+	idx.add(msg.Room, msg)
+}
+
+// add indexes a message unless one with its ID was added from source
+func (idx *SearchIndex) add(source any, msg Message) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	key := docKey{source, msg.ID}
+	if _, ok := idx.seen[key]; ok {
+		return
+	}
+	idx.seen[key] = struct{}{}
+
+	msg.Mentioned, msg.Replayed = false, false // Per recipient, not part of the message
+	doc := len(idx.docs)
+	idx.docs = append(idx.docs, msg)
+	words := tokenize(msg.Body)
+	idx.lengths = append(idx.lengths, len(words))
+	for position, word := range words {
+		list := idx.postings[word]
+		if n := len(list); n > 0 && list[n-1].doc == doc {
+			list[n-1].positions = append(list[n-1].positions, position)
+			continue
+		}
+		idx.postings[word] = append(list, posting{doc: doc, positions: []int{position}})
+	}
+}
+
+// AddHistory indexes every message of a history.
+func (idx *SearchIndex) AddHistory(history *History) error {
+	const batch = 500
+	var last uint64
+	for {
+		messages, err := history.After(last, batch)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			idx.add(history, msg)
+		}
+		if len(messages) < batch {
+			return nil
+		}
+		last = messages[len(messages)-1].ID
+	}
+}
+
+// tokenize splits text into lower-case words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// parseQuery splits query text into loose words and quoted phrases
+func parseQuery(text string) (words []string, phrases [][]string) {
+	parts := strings.Split(text, `"`)
+	for i, part := range parts {
+		tokens := tokenize(part)
+		// Odd parts are inside quotes; an unclosed quote runs to the end
+		if i%2 == 1 && len(tokens) > 1 {
+			phrases = append(phrases, tokens)
+		}
+		words = append(words, tokens...)
+	}
+	return words, phrases
+}
+
+// Search returns a page of the messages matching a query. Hits are ranked
+// by how often the words appear, weighted by their rarity, and by recency;
+// without words, the newest messages come first.
+func (idx *SearchIndex) Search(query SearchQuery) SearchResults {
+	words, phrases := parseQuery(query.Text)
+	now := time.Now()
+
+	idx.mutex.RLock()
+	var hits []SearchHit
+	for _, doc := range idx.candidates(words) {
+		msg := idx.docs[doc]
+		if !matches(msg, query) || !idx.containsPhrases(doc, phrases) {
+			continue
+		}
+		hits = append(hits, SearchHit{Message: msg, Score: idx.score(doc, words, now)})
+	}
+	idx.mutex.RUnlock()
+
+	slices.SortStableFunc(hits, func(a, b SearchHit) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return b.Message.Timestamp.Compare(a.Message.Timestamp)
+	})
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	start := min(max(query.Offset, 0), len(hits))
+	end := min(start+limit, len(hits))
+	return SearchResults{Hits: hits[start:end], Total: len(hits)}
+}
+
+// candidates returns the documents holding every word, or all documents
+// without words. The caller holds the lock.
+func (idx *SearchIndex) candidates(words []string) []int {
+	if len(words) == 0 {
+		docs := make([]int, len(idx.docs))
+		for i := range docs {
+			docs[i] = i
+		}
+		return docs
+	}
+
+	// Intersect from the shortest posting list
+	lists := make([][]posting, 0, len(words))
+	for _, word := range words {
+		lists = append(lists, idx.postings[word])
+	}
+	slices.SortFunc(lists, func(a, b []posting) int { return len(a) - len(b) })
+	var docs []int
+	for _, p := range lists[0] {
+		docs = append(docs, p.doc)
+	}
+	for _, list := range lists[1:] {
+		docs = slices.DeleteFunc(docs, func(doc int) bool { return find(list, doc) == nil })
+	}
+	return docs
+}
+
+// find returns the posting of a document in a list, or nil
+func find(list []posting, doc int) *posting {
+	i, ok := slices.BinarySearchFunc(list, doc, func(p posting, doc int) int { return p.doc - doc })
+	if !ok {
+		return nil
+	}
+	return &list[i]
+}
+
+// matches checks the conditions of a query other than its text
+func matches(msg Message, query SearchQuery) bool {
+	switch {
+	case query.Sender != "" && msg.Sender != query.Sender:
+		return false
+	case query.Room != "" && msg.Room != query.Room:
+		return false
+	case !query.Since.IsZero() && msg.Timestamp.Before(query.Since):
+		return false
+	case !query.Until.IsZero() && !msg.Timestamp.Before(query.Until):
+		return false
+	}
+	return msg.VisibleTo(query.Viewer)
+}
+
+// containsPhrases reports whether a document has every phrase word for
+// word. The caller holds the lock.
+func (idx *SearchIndex) containsPhrases(doc int, phrases [][]string) bool {
+	for _, phrase := range phrases {
+		first := find(idx.postings[phrase[0]], doc)
+		found := false
+		for _, start := range first.positions {
+			found = true
+			for offset, word := range phrase[1:] {
+				p := find(idx.postings[word], doc)
+				if !slices.Contains(p.positions, start+offset+1) {
+					found = false
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// score ranks a document for words: term frequency, normalized by length
+// and weighted by rarity, decayed by age. The caller holds the lock.
+func (idx *SearchIndex) score(doc int, words []string, now time.Time) float64 {
+	score := 1.0
+	if len(words) > 0 {
+		score = 0
+		for _, word := range slices.Compact(slices.Sorted(slices.Values(words))) {
+			list := idx.postings[word]
+			tf := float64(len(find(list, doc).positions)) / float64(idx.lengths[doc])
+			idf := math.Log(1 + float64(len(idx.docs))/float64(len(list)))
+			score += tf * idf
+		}
+	}
+	if idx.halfLife > 0 {
+		age := max(now.Sub(idx.docs[doc].Timestamp), 0)
+		score *= math.Exp2(-float64(age) / float64(idx.halfLife))
+	}
+	return score
+}
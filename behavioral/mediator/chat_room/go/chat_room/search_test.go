@@ -0,0 +1,221 @@
+package chat_room_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	cr "mediator_pattern_chat_room_go/chat_room"
+)
+
+// bodiesOf returns the bodies of search hits, in order
+func bodiesOf(results cr.SearchResults) []string {
+	var bodies []string
+	for _, hit := range results.Hits {
+		bodies = append(bodies, hit.Message.Body)
+	}
+	return bodies
+}
+
+// searchFixture indexes messages posted in two rooms of a lobby, an hour
+// apart, oldest first.
+func searchFixture(t *testing.T) (*cr.SearchIndex, time.Time) {
+	t.Helper()
+	index := cr.NewSearchIndex()
+	lobby := cr.NewLobby()
+	lobby.SetSearchIndex(index)
+	alice := NewStructuredUser("Alice")
+	bob := NewStructuredUser("Bob")
+	start := time.Now().Add(-24 * time.Hour)
+
+	posts := []struct {
+		room string
+		user *StructuredUser
+		msg  cr.Message
+	}{
+		{"general", alice, cr.Message{Body: "The build is broken again."}},
+		{"general", bob, cr.Message{Body: "Which build? The nightly build?"}},
+		{"general", alice, cr.Message{Body: "Broken tests, not the build."}},
+		{"ops", bob, cr.Message{Body: "Deploying the build to staging"}},
+		{"general", bob, cr.Message{Kind: cr.DirectMessage, To: "Alice", Body: "the build password is hunter2"}},
+		{"general", alice, cr.Message{Body: "Lunch anyone?"}},
+	}
+	captureOutput(func() {
+		for _, name := range []string{"general", "ops"} {
+			room, _ := lobby.CreateRoom(name, cr.RoomOptions{})
+			room.Join(alice)
+			room.Join(bob)
+		}
+		for i, post := range posts {
+			room, _ := lobby.Room(post.room)
+			post.msg.Timestamp = start.Add(time.Duration(i) * time.Hour)
+			if err := room.Post(post.msg, post.user); err != nil {
+				t.Fatalf("Expected %q to be posted, got %v", post.msg.Body, err)
+			}
+		}
+	})
+	return index, start
+}
+
+func TestSearchIndex_Queries(t *testing.T) {
+	index, start := searchFixture(t)
+	index.SetHalfLife(0) // Rank by text, then newest first
+
+	tests := []struct {
+		name     string
+		query    cr.SearchQuery
+		expected []string
+	}{
+		{"term", cr.SearchQuery{Text: "BROKEN"}, []string{"Broken tests, not the build.", "The build is broken again."}},
+		{"all terms", cr.SearchQuery{Text: "build staging"}, []string{"Deploying the build to staging"}},
+		{"phrase", cr.SearchQuery{Text: `"nightly build"`}, []string{"Which build? The nightly build?"}},
+		{"phrase out of order", cr.SearchQuery{Text: `"build nightly"`}, nil},
+		{"sender", cr.SearchQuery{Text: "build", Sender: "Bob"}, []string{"Which build? The nightly build?", "Deploying the build to staging"}},
+		{"room", cr.SearchQuery{Text: "build", Room: "ops"}, []string{"Deploying the build to staging"}},
+		{"time range", cr.SearchQuery{Since: start.Add(time.Hour), Until: start.Add(3 * time.Hour)}, []string{"Broken tests, not the build.", "Which build? The nightly build?"}},
+		{"private to others", cr.SearchQuery{Text: "password"}, nil},
+		{"private to recipient", cr.SearchQuery{Text: "password", Viewer: "Alice"}, []string{"the build password is hunter2"}},
+		{"no match", cr.SearchQuery{Text: "build dinner"}, nil},
+	}
+	for _, tt := range tests {
+		if got := bodiesOf(index.Search(tt.query)); !slices.Equal(got, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestSearchIndex_Ranking(t *testing.T) {
+	index, _ := searchFixture(t)
+
+	// "build" appears twice in a short message, which beats newer ones
+	results := index.Search(cr.SearchQuery{Text: "build"})
+	if len(results.Hits) == 0 || results.Hits[0].Message.Body != "Which build? The nightly build?" {
+		t.Errorf("Expected the message with two matches first, got %q", bodiesOf(results))
+	}
+	for i := 1; i < len(results.Hits); i++ {
+		if results.Hits[i].Score > results.Hits[i-1].Score {
+			t.Errorf("Expected hits sorted by score, got %+v", results.Hits)
+		}
+	}
+
+	// With equal text, the newer message ranks first
+	index.Add(cr.Message{Room: "x", ID: 1, Body: "same words", Timestamp: time.Now().Add(-48 * time.Hour)})
+	index.Add(cr.Message{Room: "x", ID: 2, Body: "same words", Timestamp: time.Now().Add(-time.Hour)})
+	results = index.Search(cr.SearchQuery{Text: "same words"})
+	if len(results.Hits) != 2 || results.Hits[0].Message.ID != 2 || results.Hits[0].Score <= results.Hits[1].Score {
+		t.Errorf("Expected the newer message to score higher, got %+v", results.Hits)
+	}
+
+	// A strong recency decay outweighs term frequency
+	index.SetHalfLife(time.Minute)
+	results = index.Search(cr.SearchQuery{Text: "build"})
+	if results.Hits[0].Message.Body != "Deploying the build to staging" {
+		t.Errorf("Expected the newest message first, got %q", bodiesOf(results))
+	}
+}
+
+func TestSearchIndex_Pagination(t *testing.T) {
+	index := cr.NewSearchIndex()
+	now := time.Now()
+	for i := 1; i <= 25; i++ {
+		index.Add(cr.Message{Room: "general", ID: uint64(i), Body: "page me", Timestamp: now.Add(time.Duration(i) * time.Second)})
+	}
+	index.Add(cr.Message{Room: "general", ID: 25, Body: "duplicate ID"})
+	if index.Len() != 25 {
+		t.Errorf("Expected a message already indexed to be ignored, got %d messages", index.Len())
+	}
+
+	first := index.Search(cr.SearchQuery{Text: "page"})
+	if first.Total != 25 || len(first.Hits) != cr.DefaultSearchLimit || first.Hits[0].Message.ID != 25 {
+		t.Errorf("Expected the first %d of 25 hits, newest first, got %d of %d", cr.DefaultSearchLimit, len(first.Hits), first.Total)
+	}
+	last := index.Search(cr.SearchQuery{Text: "page", Offset: 20, Limit: 10})
+	var ids []uint64
+	for _, hit := range last.Hits {
+		ids = append(ids, hit.Message.ID)
+	}
+	if !slices.Equal(ids, []uint64{5, 4, 3, 2, 1}) {
+		t.Errorf("Expected the last page to hold IDs 5 to 1, got %v", ids)
+	}
+	if beyond := index.Search(cr.SearchQuery{Text: "page", Offset: 100}); len(beyond.Hits) != 0 || beyond.Total != 25 {
+		t.Errorf("Expected an empty page past the end, got %+v", beyond)
+	}
+}
+
+func TestSearchIndex_RebuildFromHistory(t *testing.T) {
+	dir := t.TempDir()
+	live := cr.NewSearchIndex()
+	lobby := cr.NewLobby()
+	lobby.SetHistoryDir(dir, 0)
+	lobby.SetSearchIndex(live)
+	alice := NewStructuredUser("Alice")
+	bob := NewStructuredUser("Bob")
+	var room *cr.ChatRoom
+	captureOutput(func() {
+		room, _ = lobby.CreateRoom("general", cr.RoomOptions{})
+		room.Join(alice)
+		room.Join(bob)
+		alice.Send("release notes are ready")
+		bob.Send("ship the release")
+		room.Post(cr.Message{Body: "unrelated"}, alice)
+	})
+
+	rebuilt, err := cr.RebuildSearchIndex(room.History())
+	if err != nil {
+		t.Fatalf("Expected the index to rebuild, got %v", err)
+	}
+	query := cr.SearchQuery{Text: "release"}
+	expected := bodiesOf(live.Search(query))
+	if len(expected) != 2 {
+		t.Fatalf("Expected the live index to find 2 messages, got %q", expected)
+	}
+	if got := bodiesOf(rebuilt.Search(query)); !slices.Equal(got, expected) {
+		t.Errorf("Expected the rebuilt index to find %q, got %q", expected, got)
+	}
+	room.History().Close()
+
+	// A lobby reopening the logs indexes their messages
+	reopened := cr.NewSearchIndex()
+	lobby = cr.NewLobby()
+	lobby.SetHistoryDir(dir, 0)
+	lobby.SetSearchIndex(reopened)
+	captureOutput(func() { room, _ = lobby.CreateRoom("general", cr.RoomOptions{}) })
+	defer room.History().Close()
+	if reopened.Len() != 3 {
+		t.Errorf("Expected 3 messages indexed from the log, got %d", reopened.Len())
+	}
+	if hits := reopened.Search(cr.SearchQuery{Text: "ship", Sender: "Bob", Room: "general"}); hits.Total != 1 {
+		t.Errorf("Expected to find Bob's message, got %+v", hits)
+	}
+}
+
+func TestSearchIndex_RoomsWithOverlappingIDs(t *testing.T) {
+	index := cr.NewSearchIndex()
+	alice := NewStructuredUser("Alice")
+	captureOutput(func() {
+		// Unnamed rooms both number their messages from 1
+		for _, body := range []string{"first room", "second room"} {
+			room := cr.NewChatRoom()
+			room.SetSearchIndex(index)
+			room.Join(alice)
+			room.Post(cr.Message{Body: body}, alice)
+		}
+
+		// So does a room created again under the name of a deleted one
+		lobby := cr.NewLobby()
+		lobby.SetSearchIndex(index)
+		for _, body := range []string{"old general", "new general"} {
+			room, _ := lobby.CreateRoom("general", cr.RoomOptions{})
+			room.Join(alice)
+			room.Post(cr.Message{Body: body}, alice)
+			lobby.DeleteRoom("general")
+		}
+	})
+
+	if hits := index.Search(cr.SearchQuery{Text: "room"}); hits.Total != 2 {
+		t.Errorf("Expected both unnamed rooms to be indexed, got %q", bodiesOf(hits))
+	}
+	if hits := index.Search(cr.SearchQuery{Text: "general"}); hits.Total != 2 {
+		t.Errorf("Expected both rooms named general to be indexed, got %q", bodiesOf(hits))
+	}
+}
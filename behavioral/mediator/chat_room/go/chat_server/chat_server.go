@@ -0,0 +1,306 @@
+// Package chat_server exposes a ChatRoom over the network. Every connection
+// becomes a RemoteUser colleague of the room, speaking the frames of the
+// protocol package over TCP or WebSocket.
+package chat_server
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"mediator_pattern_chat_room_go/chat_room"
+	"mediator_pattern_chat_room_go/protocol"
+)
+
+// ErrServerClosed is returned by Serve once Close has been called.
+var ErrServerClosed = errors.New("chat server closed")
+
+// --- Concrete Colleague: RemoteUser ---
+
+// RemoteUser implements the User interface for a network client.
+type RemoteUser struct {
+	name     string
+	conn     protocol.Conn
+	mediator chat_room.ChatMediator
+}
+
+// Send sends a message from the client via the mediator, or runs it if it
+// is a command like "/who".
+func (u *RemoteUser) Send(message string) {
+	if u.mediator == nil {
+		return
+	}
+	chat_room.Dispatch(u.mediator, message, u)
+}
+
+// Receive forwards a message to the client. A failed write closes the
+// connection, which ends the session.
+func (u *RemoteUser) Receive(message string, senderName string) {
+	frame := &protocol.Frame{Type: protocol.Message, Name: senderName, Body: message}
+	if err := u.conn.WriteFrame(frame); err != nil {
+		u.conn.Close()
+	}
+}
+
+// ReceiveMessage forwards a structured message to the client.
+func (u *RemoteUser) ReceiveMessage(msg chat_room.Message) {
+	frame := &protocol.Frame{
+		Type:      protocol.Message,
+		Name:      msg.Sender,
+		Body:      msg.Body,
+		Kind:      msg.Kind.String(),
+		To:        msg.To,
+		ID:        msg.ID,
+		Time:      msg.Timestamp,
+		Mentioned: msg.Mentioned,
+	}
+	if err := u.conn.WriteFrame(frame); err != nil {
+		u.conn.Close()
+	}
+}
+
+// OnRoomEvent hangs up when the room drops the user, for instance because
+// its mailbox overflowed.
+func (u *RemoteUser) OnRoomEvent(event chat_room.RoomEvent) {
+	if event.Kind == chat_room.RoomDeleted || event.Kind == chat_room.RoomClosed || (event.Kind == chat_room.MemberLeft && event.User == u.name) {
+		u.conn.Close()
+	}
+}
+
+// GetName returns the name the client joined with.
+func (u *RemoteUser) GetName() string {
+	return u.name
+}
+
+// SetMediator sets the mediator for the user. Required by ChatRoom.Join.
+func (u *RemoteUser) SetMediator(mediator chat_room.ChatMediator) {
+	u.mediator = mediator
+}
+
+// --- Server ---
+
+// Server accepts chat clients into a ChatRoom.
+type Server struct {
+	room *chat_room.ChatRoom
+
+	mu        sync.Mutex
+	users     map[string]*RemoteUser
+	conns     map[protocol.Conn]struct{}
+	listeners map[net.Listener]struct{}
+	closed    bool
+	sessions  sync.WaitGroup
+}
+
+// NewServer creates a Server admitting clients into room.
+func NewServer(room *chat_room.ChatRoom) *Server {
+	return &Server{
+		room:      room,
+		users:     make(map[string]*RemoteUser),
+		conns:     make(map[protocol.Conn]struct{}),
+		listeners: make(map[net.Listener]struct{}),
+	}
+}
+
+// ListenAndServe accepts TCP clients on addr.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts TCP clients on l until Close is called, serving each
+// connection in its own goroutine.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go s.ServeConn(protocol.NewLineConn(conn))
+	}
+}
+
+// ServeHTTP upgrades a request to a WebSocket connection and serves it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := protocol.AcceptWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	s.ServeConn(conn)
+}
+
+// track registers a connection, reporting false once the server is closed
+func (s *Server) track(conn protocol.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.sessions.Add(1)
+	return true
+}
+
+// ServeConn runs the session of one client and returns when it ends. The
+// client must join before sending messages; it may retry with another name
+// after a rejected join.
+func (s *Server) ServeConn(conn protocol.Conn) {
+	if !s.track(conn) {
+		conn.Close()
+		return
+	}
+	defer s.sessions.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	user := s.join(conn)
+	if user == nil {
+		return
+	}
+	defer s.leave(user)
+
+	for {
+		frame, err := conn.ReadFrame()
+		var frameErr *protocol.FrameError
+		if errors.As(err, &frameErr) {
+			conn.WriteFrame(&protocol.Frame{Type: protocol.Error, Error: err.Error()})
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		switch frame.Type {
+		case protocol.Message:
+			if frame.To == "" {
+				user.Send(frame.Body)
+				continue
+			}
+			err := s.room.Post(chat_room.Message{Kind: chat_room.DirectMessage, To: frame.To, Body: frame.Body}, user)
+			if err != nil {
+				conn.WriteFrame(&protocol.Frame{Type: protocol.Error, Error: "cannot message " + frame.To + ": " + err.Error()})
+			}
+		case protocol.Leave:
+			return
+		default:
+			conn.WriteFrame(&protocol.Frame{Type: protocol.Error, Error: "unexpected " + string(frame.Type) + " frame"})
+		}
+	}
+}
+
+// join reads frames until the client joins the room, returning nil if the
+// connection ends first
+func (s *Server) join(conn protocol.Conn) *RemoteUser {
+	for {
+		frame, err := conn.ReadFrame()
+		var frameErr *protocol.FrameError
+		if errors.As(err, &frameErr) {
+			conn.WriteFrame(&protocol.Frame{Type: protocol.Error, Error: err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil
+		}
+		if frame.Type != protocol.Join {
+			conn.WriteFrame(&protocol.Frame{Type: protocol.Error, Error: "join the room first"})
+			continue
+		}
+
+		user := &RemoteUser{name: frame.Name, conn: conn}
+		s.mu.Lock()
+		if err := s.room.Join(user); err != nil {
+			s.mu.Unlock()
+			reason := err.Error()
+			if errors.Is(err, chat_room.ErrUserExists) {
+				reason = "name " + frame.Name + " is taken"
+			}
+			conn.WriteFrame(&protocol.Frame{Type: protocol.Error, Error: reason})
+			continue
+		}
+		s.users[user.name] = user
+		recipients := s.clients(nil)
+		s.mu.Unlock()
+		broadcast(recipients, &protocol.Frame{Type: protocol.Join, Name: user.name})
+		log.Printf("--- %s connected from %s. ---", user.name, conn.RemoteAddr())
+		return user
+	}
+}
+
+// leave removes a user from the room and tells the other clients. A client
+// that rejoined under the name of an ending session is left alone.
+func (s *Server) leave(user *RemoteUser) {
+	s.mu.Lock()
+	if s.users[user.name] != user {
+		s.mu.Unlock()
+		return
+	}
+	s.room.Leave(user) // Fails if the room already dropped the user
+	delete(s.users, user.name)
+	recipients := s.clients(user)
+	s.mu.Unlock()
+	broadcast(recipients, &protocol.Frame{Type: protocol.Leave, Name: user.name})
+}
+
+// clients returns the connected clients except skip. The caller holds s.mu.
+func (s *Server) clients(skip *RemoteUser) []*RemoteUser {
+	clients := make([]*RemoteUser, 0, len(s.users))
+	for _, user := range s.users {
+		if user != skip {
+			clients = append(clients, user)
+		}
+	}
+	return clients
+}
+
+// broadcast sends a frame to clients without holding s.mu, so a slow client
+// holds up no join or leave; WriteTimeout bounds each write.
+func broadcast(clients []*RemoteUser, frame *protocol.Frame) {
+	for _, user := range clients {
+		user.conn.WriteFrame(frame)
+	}
+}
+
+// Close stops accepting clients, disconnects the connected ones and waits
+// for their sessions to end.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	conns := make([]protocol.Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+	// Outside the lock, so closing a slow client holds up nobody else
+	for _, conn := range conns {
+		conn.Close()
+	}
+	s.sessions.Wait()
+	return nil
+}
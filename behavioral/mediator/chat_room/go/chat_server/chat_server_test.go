@@ -0,0 +1,256 @@
+package chat_server_test
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"mediator_pattern_chat_room_go/chat_client"
+	cr "mediator_pattern_chat_room_go/chat_room"
+	"mediator_pattern_chat_room_go/chat_server"
+	"mediator_pattern_chat_room_go/protocol"
+)
+
+// startServer serves a new room on a loopback TCP port and over WebSocket
+func startServer(t *testing.T) (*cr.ChatRoom, string, string) {
+	t.Helper()
+	room := cr.NewChatRoom()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	server := chat_server.NewServer(room)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(l)
+	ws := httptest.NewServer(server)
+	t.Cleanup(func() {
+		server.Close()
+		ws.Close()
+	})
+	return room, l.Addr().String(), "ws" + strings.TrimPrefix(ws.URL, "http")
+}
+
+func dial(t *testing.T, addr, name string) *chat_client.Client {
+	t.Helper()
+	c, err := chat_client.Dial(addr, name)
+	if err != nil {
+		t.Fatalf("Failed to join as %s: %v", name, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// expectFrame waits for the next frame of a client and checks its type,
+// name, body and recipient
+func expectFrame(t *testing.T, c *chat_client.Client, want protocol.Frame) *protocol.Frame {
+	t.Helper()
+	select {
+	case frame, ok := <-c.Frames():
+		if !ok {
+			t.Fatalf("%s: expected %+v, connection ended: %v", c.Name(), want, c.Err())
+		}
+		got := protocol.Frame{Type: frame.Type, Name: frame.Name, Body: frame.Body, To: frame.To}
+		if got != want {
+			t.Errorf("%s: expected %+v, got %+v", c.Name(), want, *frame)
+		}
+		return frame
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s: timed out waiting for %+v", c.Name(), want)
+	}
+	return nil
+}
+
+func TestMessagesReachOtherClients(t *testing.T) {
+	_, addr, wsURL := startServer(t)
+
+	alice := dial(t, addr, "Alice")
+	bob := dial(t, addr, "Bob")
+	expectFrame(t, alice, protocol.Frame{Type: protocol.Join, Name: "Bob"})
+
+	charlie, err := chat_client.DialWebSocket(wsURL, "Charlie")
+	if err != nil {
+		t.Fatalf("Failed to join over WebSocket: %v", err)
+	}
+	defer charlie.Close()
+	expectFrame(t, alice, protocol.Frame{Type: protocol.Join, Name: "Charlie"})
+	expectFrame(t, bob, protocol.Frame{Type: protocol.Join, Name: "Charlie"})
+
+	if err := alice.Send("Hi everyone!"); err != nil {
+		t.Fatal(err)
+	}
+	want := protocol.Frame{Type: protocol.Message, Name: "Alice", Body: "Hi everyone!"}
+	expectFrame(t, bob, want)
+	expectFrame(t, charlie, want)
+
+	charlie.Send("Hello from the browser")
+	want = protocol.Frame{Type: protocol.Message, Name: "Charlie", Body: "Hello from the browser"}
+	expectFrame(t, alice, want)
+	expectFrame(t, bob, want)
+}
+
+func TestDirectMessagesAndMentions(t *testing.T) {
+	_, addr, _ := startServer(t)
+	alice := dial(t, addr, "Alice")
+	bob := dial(t, addr, "Bob")
+	charlie := dial(t, addr, "Charlie")
+	expectFrame(t, alice, protocol.Frame{Type: protocol.Join, Name: "Bob"})
+	expectFrame(t, alice, protocol.Frame{Type: protocol.Join, Name: "Charlie"})
+	expectFrame(t, bob, protocol.Frame{Type: protocol.Join, Name: "Charlie"})
+
+	alice.SendDirect("Bob", "psst")
+	frame := expectFrame(t, bob, protocol.Frame{Type: protocol.Message, Name: "Alice", Body: "psst", To: "Bob"})
+	if frame.Kind != "dm" || frame.ID == 0 || frame.Time.IsZero() {
+		t.Errorf("Expected a dm frame with an ID and a time, got %+v", *frame)
+	}
+
+	alice.SendDirect("Nobody", "hello?")
+	expectFrame(t, alice, protocol.Frame{Type: protocol.Error})
+
+	// Charlie only sees the public message, which mentions Bob
+	alice.Send("@Bob see above")
+	for _, c := range []*chat_client.Client{bob, charlie} {
+		frame := expectFrame(t, c, protocol.Frame{Type: protocol.Message, Name: "Alice", Body: "@Bob see above"})
+		if frame.Mentioned != (c == bob) {
+			t.Errorf("%s: expected mentioned to be %t", c.Name(), c == bob)
+		}
+	}
+}
+
+func TestRemoteAndLocalUsersShareTheRoom(t *testing.T) {
+	room, addr, _ := startServer(t)
+	local := &recordingUser{name: "Local", received: make(chan string, 1)}
+	room.AddUser(local)
+
+	alice := dial(t, addr, "Alice")
+	alice.Send("ping")
+	select {
+	case got := <-local.received:
+		if got != "Alice: ping" {
+			t.Errorf("Expected 'Alice: ping', got %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Local user did not receive the message")
+	}
+
+	local.Send("pong")
+	expectFrame(t, alice, protocol.Frame{Type: protocol.Message, Name: "Local", Body: "pong"})
+}
+
+func TestDuplicateNameIsRejected(t *testing.T) {
+	_, addr, wsURL := startServer(t)
+	dial(t, addr, "Alice")
+
+	_, err := chat_client.Dial(addr, "Alice")
+	var joinErr *chat_client.JoinError
+	if !errors.As(err, &joinErr) {
+		t.Fatalf("Expected a JoinError, got %v", err)
+	}
+	if _, err := chat_client.DialWebSocket(wsURL, "Alice"); !errors.As(err, &joinErr) {
+		t.Errorf("Expected a JoinError over WebSocket, got %v", err)
+	}
+}
+
+func TestLeaveIsAnnounced(t *testing.T) {
+	room, addr, wsURL := startServer(t)
+	alice := dial(t, addr, "Alice")
+	bob := dial(t, addr, "Bob")
+	expectFrame(t, alice, protocol.Frame{Type: protocol.Join, Name: "Bob"})
+
+	bob.Close()
+	expectFrame(t, alice, protocol.Frame{Type: protocol.Leave, Name: "Bob"})
+
+	// A dropped WebSocket connection counts as leaving too
+	conn, err := protocol.DialWebSocket(wsURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	charlie, err := chat_client.Join(conn, "Charlie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, alice, protocol.Frame{Type: protocol.Join, Name: "Charlie"})
+	conn.Close()
+	expectFrame(t, alice, protocol.Frame{Type: protocol.Leave, Name: "Charlie"})
+	charlie.Close()
+
+	// The name is free again once its session ended
+	if err := room.Join(&recordingUser{name: "Bob"}); err != nil {
+		t.Errorf("Expected Bob's name to be free, got %v", err)
+	}
+}
+
+func TestFramesBeforeJoin(t *testing.T) {
+	_, addr, _ := startServer(t)
+	raw, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := protocol.NewLineConn(raw)
+	defer conn.Close()
+
+	// A malformed frame and a message before joining are both answered with
+	// an error, and the session goes on
+	raw.Write([]byte("{not json}\n"))
+	conn.WriteFrame(&protocol.Frame{Type: protocol.Message, Body: "too early"})
+	for _, contains := range []string{"malformed frame", "join the room first"} {
+		frame, err := conn.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type != protocol.Error || !strings.Contains(frame.Error, contains) {
+			t.Errorf("Expected an error frame containing %q, got %+v", contains, *frame)
+		}
+	}
+
+	if _, err := chat_client.Join(conn, "Alice"); err != nil {
+		t.Errorf("Expected to join after the errors, got %v", err)
+	}
+}
+
+func TestCloseDisconnectsClients(t *testing.T) {
+	room := cr.NewChatRoom()
+	server := chat_server.NewServer(room)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(l) }()
+
+	alice := dial(t, l.Addr().String(), "Alice")
+	server.Close()
+
+	if err := <-served; err != chat_server.ErrServerClosed {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+	for range alice.Frames() {
+	}
+	if alice.Err() == nil {
+		t.Error("Expected the client to report the lost connection")
+	}
+}
+
+// recordingUser is an in-process colleague next to the remote ones
+type recordingUser struct {
+	name     string
+	mediator cr.ChatMediator
+	received chan string
+}
+
+func (u *recordingUser) Send(message string) { u.mediator.SendMessage(message, u) }
+
+func (u *recordingUser) Receive(message string, senderName string) {
+	u.received <- senderName + ": " + message
+}
+
+func (u *recordingUser) GetName() string { return u.name }
+
+func (u *recordingUser) SetMediator(mediator cr.ChatMediator) { u.mediator = mediator }
@@ -0,0 +1,43 @@
+// Command chat_server serves one chat room over TCP and WebSocket.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	cr "mediator_pattern_chat_room_go/chat_room"
+	"mediator_pattern_chat_room_go/chat_server"
+)
+
+func main() {
+	tcpAddr := flag.String("tcp", ":4000", "address for JSON Lines clients, empty to disable")
+	wsAddr := flag.String("ws", ":4001", "address for WebSocket clients, empty to disable")
+	historyPath := flag.String("history", "", "log file for the room history, empty to keep none")
+	replay := flag.Int("replay", 20, "messages of the history replayed to clients who join")
+	flag.Parse()
+
+	room := cr.NewChatRoom()
+	if *historyPath != "" {
+		history, err := cr.OpenHistory(*historyPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer history.Close()
+		room.SetHistory(history, *replay)
+	}
+	server := chat_server.NewServer(room)
+	errs := make(chan error, 2)
+	if *tcpAddr != "" {
+		log.Printf("Listening for TCP clients on %s", *tcpAddr)
+		go func() { errs <- server.ListenAndServe(*tcpAddr) }()
+	}
+	if *wsAddr != "" {
+		log.Printf("Listening for WebSocket clients on %s", *wsAddr)
+		go func() { errs <- http.ListenAndServe(*wsAddr, server) }()
+	}
+	if *tcpAddr == "" && *wsAddr == "" {
+		log.Fatal("Nothing to serve: both -tcp and -ws are empty")
+	}
+	log.Fatal(<-errs)
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"fmt"
-	// "time" // No longer needed
+	"time"
 
+	"mediator_pattern_chat_room_go/bots"
 	cr "mediator_pattern_chat_room_go/chat_room"
 )
 
@@ -30,10 +31,13 @@ func main() {
 	// 4. Users send messages through the Mediator (Match Python messages and structure)
 	fmt.Println("\n--- Users sending messages ---")
 	alice.Send("Hi everyone! How's it going?")
+	mediator.Flush()                    // Messages are delivered asynchronously; wait for them
 	fmt.Println("--------------------") // Match Python separator
 	bob.Send("Hey Alice! Doing well, thanks. Just working on the project.")
+	mediator.Flush()
 	fmt.Println("--------------------") // Match Python separator
 	charlie.Send("Hello! Project is coming along nicely.")
+	mediator.Flush()
 
 	// 5. Remove a user (Match Python structure)
 	fmt.Println("\n--- Removing a user ---")
@@ -42,11 +46,78 @@ func main() {
 	// 6. Send another message (Match Python message)
 	fmt.Println("\n--- Sending message after Bob left ---")
 	alice.Send("Okay, let's sync up later then.")
+	mediator.Flush()
 
 	// 7. Try removing a non-existent user (Match Python structure)
 	fmt.Println("\n--- Trying to remove Bob again ---")
 	mediator.RemoveUser(bob) // Attempt to remove Bob again, will print "not in room"
 
+	// 8. Several named rooms through a Lobby
+	fmt.Println("\n--- Using several rooms ---")
+	lobby := cr.NewLobby()
+	index := cr.NewSearchIndex()
+	lobby.SetSearchIndex(index)
+	lobby.CreateRoom("general", cr.RoomOptions{Topic: "Anything goes"})
+	lobby.CreateRoom("project", cr.RoomOptions{Password: "s3cret", MaxMembers: 2})
+	lobby.Join("general", alice, "")
+	lobby.Join("general", charlie, "")
+	lobby.Join("project", alice, "s3cret")
+	if err := lobby.Join("project", charlie, "guess"); err != nil {
+		fmt.Printf("Charlie cannot join project: %v\n", err)
+	}
+	for _, room := range lobby.Rooms() {
+		fmt.Printf("Room %s (%q): %d member(s), private: %t\n", room.Name, room.Topic, room.Members, room.Private)
+	}
+	general, _ := lobby.Room("general")
+	alice.SendTo("general", "Hello general, @Charlie!")
+	general.Flush()
+	charlie.SendDirect("Alice", "Can you review my change?") // Charlie's last room is general
+	general.Flush()
+	charlie.SendAction("gives a thumbs up")
+	general.Flush()
+	lobby.DeleteRoom("project")
+
+	// 9. Moderation: roles, filters and sanctions
+	fmt.Println("\n--- Moderating a room ---")
+	lobby.CreateRoom("support", cr.RoomOptions{Owner: "Alice"})
+	lobby.Join("support", alice, "")
+	lobby.Join("support", charlie, "")
+	support, _ := lobby.Room("support")
+	support.AddFilter(cr.LinkFilter())
+	charlie.SendTo("support", "Try https://example.com") // Blocked; Charlie is told why
+	support.Mute(alice, "Charlie", time.Minute, "take a break")
+	support.Flush()
+	if err := support.Kick(charlie, "Alice", ""); err != nil {
+		fmt.Printf("Charlie cannot kick Alice: %v\n", err)
+	}
+	alice.SendTo("support", "/topic Questions about patterns")
+	alice.SendTo("support", "/who")
+	support.Flush()
+	support.Kick(alice, "Charlie", "off topic")
+	support.Close()
+	fmt.Printf("Alice is now in: %v\n", alice.Rooms())
+
+	// 10. Bots answering commands in a room
+	fmt.Println("\n--- Running a poll with a bot ---")
+	pollBot := bots.NewPollBot("PollBot")
+	general.Join(pollBot)
+	alice.SendTo("general", "/poll Lunch? | Pizza | Sushi")
+	charlie.SendTo("general", "/vote 2")
+	alice.SendTo("general", "/vote 2")
+	alice.SendTo("general", "/endpoll")
+	general.Flush()
+
+	// 11. Searching what was said
+	fmt.Println("\n--- Searching the rooms ---")
+	results := index.Search(cr.SearchQuery{Text: "lunch", Viewer: "Alice"})
+	fmt.Printf("%d message(s) about lunch:\n", results.Total)
+	for _, hit := range results.Hits {
+		fmt.Printf("  [%s] %s: %s\n", hit.Message.Room, hit.Message.Sender, hit.Message.Body)
+	}
+
+	mediator.Close()
+	general.Close()
+
 	// Match Python's final print
 	fmt.Println("\n--- Chat Room Demo Complete ---")
 }
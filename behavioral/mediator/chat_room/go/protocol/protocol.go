@@ -0,0 +1,161 @@
+// Package protocol defines the frames exchanged between chat clients and the
+// chat server, and the connections that carry them: JSON Lines over TCP, or
+// one JSON document per WebSocket text message.
+package protocol
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// MaxFrameSize is the largest encoded frame a connection accepts.
+const MaxFrameSize = 1 << 20
+
+// WriteTimeout bounds every frame write, so a stalled peer fails the write
+// instead of holding up whoever is writing to it.
+const WriteTimeout = 10 * time.Second
+
+// ErrFrameTooLarge is returned when a peer sends a frame over MaxFrameSize.
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// FrameType identifies what a frame means.
+type FrameType string
+
+const (
+	// Join asks to join with a name. The server answers with a Join frame
+	// for the same name, and sends a Join frame to the others.
+	Join FrameType = "join"
+	// Leave ends a session. The server tells the others who left.
+	Leave FrameType = "leave"
+	// Message carries a chat message; the server fills in the sender. A
+	// message with To set is a direct message to that user.
+	Message FrameType = "message"
+	// Error reports a rejected request.
+	Error FrameType = "error"
+)
+
+// Frame is one protocol unit.
+type Frame struct {
+	Type      FrameType `json:"type"`
+	Name      string    `json:"name,omitempty"` // Who joins, leaves or sent a message
+	Body      string    `json:"body,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Kind      string    `json:"kind,omitempty"` // Message kind: chat, system, action or dm
+	To        string    `json:"to,omitempty"`   // Recipient of a direct message
+	ID        uint64    `json:"id,omitempty"`
+	Time      time.Time `json:"time,omitzero"`
+	Mentioned bool      `json:"mentioned,omitempty"` // The recipient was @mentioned
+}
+
+// Validate checks the fields a frame type requires.
+func (f *Frame) Validate() error {
+	switch f.Type {
+	case Join:
+		if f.Name == "" {
+			return errors.New("join frame without a name")
+		}
+	case Leave, Message:
+	case Error:
+		if f.Error == "" {
+			return errors.New("error frame without an error")
+		}
+	default:
+		return fmt.Errorf("unknown frame type %q", f.Type)
+	}
+	return nil
+}
+
+// Conn sends and receives frames. WriteFrame may be called concurrently
+// with ReadFrame and with itself; ReadFrame must not be called concurrently.
+type Conn interface {
+	ReadFrame() (*Frame, error)
+	WriteFrame(frame *Frame) error
+	Close() error
+	RemoteAddr() net.Addr
+}
+
+// FrameError reports a frame that could not be decoded or is invalid. The
+// connection remains usable.
+type FrameError struct {
+	Err error
+}
+
+func (e *FrameError) Error() string {
+	return "malformed frame: " + e.Err.Error()
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// decodeFrame parses and validates an encoded frame
+func decodeFrame(data []byte) (*Frame, error) {
+	var frame Frame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, &FrameError{Err: err}
+	}
+	if err := frame.Validate(); err != nil {
+		return nil, &FrameError{Err: err}
+	}
+	return &frame, nil
+}
+
+// lineConn carries one JSON frame per line
+type lineConn struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	writeMu sync.Mutex
+}
+
+// NewLineConn creates a Conn exchanging JSON Lines over a stream connection.
+func NewLineConn(conn net.Conn) Conn {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), MaxFrameSize)
+	return &lineConn{conn: conn, scanner: scanner}
+}
+
+// ReadFrame reads the next non-empty line as a frame. A malformed frame
+// returns a *FrameError.
+func (c *lineConn) ReadFrame() (*Frame, error) {
+	for c.scanner.Scan() {
+		line := c.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		return decodeFrame(line)
+	}
+	if err := c.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, ErrFrameTooLarge
+		}
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// WriteFrame writes a frame as one line.
+func (c *lineConn) WriteFrame(frame *Frame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	_, err = c.conn.Write(append(data, '\n'))
+	return err
+}
+
+func (c *lineConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *lineConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
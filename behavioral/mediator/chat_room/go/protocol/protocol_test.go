@@ -0,0 +1,167 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loopback returns both ends of a TCP connection. Unlike net.Pipe it
+// buffers writes, so a peer may answer a close frame nobody reads yet.
+func loopback(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+// pipes returns both ends of each connection type
+func pipes(t *testing.T) map[string][2]Conn {
+	a, b := loopback(t)
+	c, d := loopback(t)
+	return map[string][2]Conn{
+		"Lines": {NewLineConn(a), NewLineConn(b)},
+		"WebSocket": {
+			&wsConn{conn: c, reader: bufio.NewReader(c), client: true},
+			&wsConn{conn: d, reader: bufio.NewReader(d)},
+		},
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	frames := []*Frame{
+		{Type: Join, Name: "Alice"},
+		{Type: Message, Name: "Alice", Body: strings.Repeat("é", 40000)},
+		{Type: Error, Error: "name Alice is taken"},
+		{Type: Leave, Name: "Alice"},
+	}
+	for name, ends := range pipes(t) {
+		t.Run(name, func(t *testing.T) {
+			// Both directions, since only client frames are masked
+			for _, pair := range [][2]Conn{{ends[0], ends[1]}, {ends[1], ends[0]}} {
+				go func() {
+					for _, frame := range frames {
+						pair[0].WriteFrame(frame)
+					}
+				}()
+				for _, expected := range frames {
+					got, err := pair[1].ReadFrame()
+					if err != nil {
+						t.Fatalf("Unexpected error: %v", err)
+					}
+					if *got != *expected {
+						t.Errorf("Expected %+v, got type %s with %d byte body", expected.Type, got.Type, len(got.Body))
+					}
+				}
+			}
+			go ends[0].Close()
+			if _, err := ends[1].ReadFrame(); err != io.EOF {
+				t.Errorf("Expected io.EOF after close, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMalformedFrames(t *testing.T) {
+	a, b := net.Pipe()
+	conn := NewLineConn(b)
+	go func() {
+		a.Write([]byte("{\"type\":\"dance\"}\n{\"type\":\"join\"}\n\n{\"type\":\"leave\"}\n"))
+		a.Close()
+	}()
+
+	for _, expected := range []string{"unknown frame type", "join frame without a name"} {
+		_, err := conn.ReadFrame()
+		var frameErr *FrameError
+		if !errors.As(err, &frameErr) || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected a FrameError about %q, got %v", expected, err)
+		}
+	}
+	if frame, err := conn.ReadFrame(); err != nil || frame.Type != Leave {
+		t.Errorf("Expected the connection to stay usable, got %v, %v", frame, err)
+	}
+}
+
+func TestFrameTooLarge(t *testing.T) {
+	for name, ends := range pipes(t) {
+		t.Run(name, func(t *testing.T) {
+			defer ends[0].Close()
+			defer ends[1].Close()
+			go ends[0].WriteFrame(&Frame{Type: Message, Body: strings.Repeat("x", MaxFrameSize)})
+			if _, err := ends[1].ReadFrame(); err != ErrFrameTooLarge {
+				t.Errorf("Expected ErrFrameTooLarge, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFragmentLengthOverflow(t *testing.T) {
+	// A fragment followed by a continuation whose length would wrap the
+	// message size around
+	lengths := map[string]uint64{
+		"top bit set":   1<<64 - 6,
+		"over the max":  1<<63 - 1,
+		"over in total": MaxFrameSize - 5,
+	}
+	for name, length := range lengths {
+		t.Run(name, func(t *testing.T) {
+			server, client := loopback(t)
+			defer server.Close()
+			defer client.Close()
+			conn := &wsConn{conn: client, reader: bufio.NewReader(client), client: true}
+
+			frames := append([]byte{opText, 10}, "0123456789"...)
+			frames = append(frames, 0x80|opContinuation, 127)
+			frames = binary.BigEndian.AppendUint64(frames, length)
+			go server.Write(frames)
+			if _, err := conn.readMessage(); err != errProtocol && err != ErrFrameTooLarge {
+				t.Errorf("Expected the continuation to be refused, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCloseDoesNotWaitForStuckWrite(t *testing.T) {
+	a, b := net.Pipe() // Writes block until the peer reads, which it never does
+	defer b.Close()
+	conn := &wsConn{conn: a, reader: bufio.NewReader(a)}
+	go conn.WriteFrame(&Frame{Type: Message, Body: "stuck"})
+	for conn.writeMu.TryLock() {
+		conn.writeMu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		conn.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Close to return while a write is stuck")
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455, section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Expected s3pPLMBiTxaQ9kYGzzhZRbK+xOo=, got %s", got)
+	}
+}
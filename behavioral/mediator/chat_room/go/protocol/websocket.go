@@ -0,0 +1,339 @@
+package protocol
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is appended to the client key to compute the accept key
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes (RFC 6455, section 5.2)
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// closeTimeout bounds the close frame sent by Close
+const closeTimeout = time.Second
+
+// errProtocol reports a peer violating the WebSocket framing rules
+var errProtocol = errors.New("websocket protocol error")
+
+// wsConn carries one JSON frame per WebSocket text message
+type wsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	client  bool // Clients mask the frames they send; servers must not
+	writeMu sync.Mutex
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for a client key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains reports whether a comma separated header holds a token
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AcceptWebSocket completes the WebSocket handshake of an HTTP request and
+// returns a Conn over the upgraded connection. On failure an HTTP error has
+// already been written.
+func AcceptWebSocket(w http.ResponseWriter, r *http.Request) (Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported WebSocket version")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection can't be upgraded", http.StatusInternalServerError)
+		return nil, errors.New("response writer can't be hijacked")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, reader: rw.Reader}, nil
+}
+
+// DialWebSocket connects to a ws:// URL and returns a Conn over it.
+func DialWebSocket(rawURL string) (Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("unsupported scheme %q, expected ws", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake failed: %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, errors.New("websocket handshake failed: bad accept key")
+	}
+	return &wsConn{conn: conn, reader: reader, client: true}, nil
+}
+
+// readHeader reads a frame header and returns its fin bit, opcode, length
+// and masking key
+func (c *wsConn) readHeader() (bool, byte, uint64, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.reader, head[:]); err != nil {
+		return false, 0, 0, nil, err
+	}
+	fin, opcode := head[0]&0x80 != 0, head[0]&0x0F
+	if head[0]&0x70 != 0 {
+		return false, 0, 0, nil, errProtocol // Reserved bits without an extension
+	}
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		return false, 0, 0, nil, errProtocol // Only client frames are masked
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, 0, nil, err
+		}
+		if ext[0]&0x80 != 0 {
+			return false, 0, 0, nil, errProtocol // The most significant bit must be 0
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(c.reader, mask); err != nil {
+			return false, 0, 0, nil, err
+		}
+	}
+	return fin, opcode, length, mask, nil
+}
+
+// readMessage reads a complete data message, answering control frames
+func (c *wsConn) readMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		fin, opcode, length, mask, err := c.readHeader()
+		if err != nil {
+			return nil, err
+		}
+		isControl := opcode&0x8 != 0
+		if isControl && (length > 125 || !fin) {
+			return nil, errProtocol
+		}
+		// Compare without adding, which a huge length would overflow
+		if length > MaxFrameSize || length > MaxFrameSize-uint64(len(message)) {
+			c.writeFrame(opClose, closePayload(1009, "message too big"))
+			return nil, ErrFrameTooLarge
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			return nil, err
+		}
+		if mask != nil {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.writeFrame(opClose, payload[:min(len(payload), 2)])
+			return nil, io.EOF
+		case opText, opBinary:
+			if started {
+				return nil, errProtocol
+			}
+			started = true
+		case opContinuation:
+			if !started {
+				return nil, errProtocol
+			}
+		default:
+			return nil, errProtocol
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// closePayload builds the body of a close frame
+func closePayload(code uint16, reason string) []byte {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	return append(payload, reason...)
+}
+
+// writeFrame writes one unfragmented frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := c.encodeFrame(opcode, payload)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// encodeFrame builds one unfragmented frame, masking it on the client side
+func (c *wsConn) encodeFrame(opcode byte, payload []byte) []byte {
+	header := []byte{0x80 | opcode, 0}
+	length := len(payload)
+	switch {
+	case length < 126:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	data := payload
+	if c.client {
+		header[1] |= 0x80
+		mask := make([]byte, 4)
+		rand.Read(mask)
+		header = append(header, mask...)
+		data = make([]byte, length)
+		for i := range payload {
+			data[i] = payload[i] ^ mask[i%4]
+		}
+	}
+	return append(header, data...)
+}
+
+// ReadFrame reads the next text message as a frame. A malformed frame
+// returns a *FrameError; a close frame from the peer returns io.EOF.
+func (c *wsConn) ReadFrame() (*Frame, error) {
+	message, err := c.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	return decodeFrame(message)
+}
+
+// WriteFrame sends a frame as one text message.
+func (c *wsConn) WriteFrame(frame *Frame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(opText, data)
+}
+
+// Close sends a close frame and closes the connection. The close frame is
+// best effort: it is skipped rather than queued behind a stuck write, which
+// closing the connection then fails.
+func (c *wsConn) Close() error {
+	if c.writeMu.TryLock() {
+		c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+		c.conn.Write(c.encodeFrame(opClose, closePayload(1000, "")))
+		c.writeMu.Unlock()
+	}
+	return c.conn.Close()
+}
+
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
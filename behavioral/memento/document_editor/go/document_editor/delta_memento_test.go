@@ -0,0 +1,220 @@
+package document_editor
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"testing"
+)
+
+// silence discards what the document prints until the end of the test, so
+// large documents don't flood the output.
+func silence(tb testing.TB) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	tb.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
+
+// edit replaces a random range of content with a short random text.
+func edit(rng *rand.Rand, content string) string {
+	start := rng.IntN(len(content) + 1)
+	end := min(start+rng.IntN(8), len(content))
+	return content[:start] + fmt.Sprintf("<%d>", rng.IntN(1000)) + content[end:]
+}
+
+// storedBytes returns how much text a memento keeps.
+func storedBytes(m *concreteMemento) int {
+	return len(m.state) + len(m.delta.removed) + len(m.delta.inserted)
+}
+
+// TestDeltaRoundTrip checks that deltas turn one state into the other and back.
+func TestDeltaRoundTrip(t *testing.T) {
+	tests := []struct{ from, to string }{
+		{"", ""},
+		{"", "Hello"},
+		{"Hello", ""},
+		{"Hello World", "Hello World"},
+		{"Hello World", "Hello brave World"},
+		{"Hello brave World", "Hello World"},
+		{"Hello World", "Jello Word"},
+		{"aaaa", "aaaaaa"},
+		{"naïve café", "naive cafe"},
+		{strings.Repeat("x", 5000) + "middle" + strings.Repeat("y", 5000), strings.Repeat("x", 5000) + "center" + strings.Repeat("y", 5000)},
+	}
+	for _, tt := range tests {
+		d := diff(tt.from, tt.to)
+		if got := d.apply(tt.from); got != tt.to {
+			t.Errorf("Expected apply(%q) to give %q, got %q", tt.from, tt.to, got)
+		}
+		if got := d.revert(tt.to); got != tt.from {
+			t.Errorf("Expected revert(%q) to give %q, got %q", tt.to, tt.from, got)
+		}
+		if len(d.removed)+len(d.inserted) > len(tt.from)+len(tt.to) {
+			t.Errorf("Expected the delta of %q to %q to be minimal, got %+v", tt.from, tt.to, d)
+		}
+	}
+	if d := diff(strings.Repeat("x", 5000)+"middle", strings.Repeat("x", 5000)+"center"); d.offset != 5000 || d.removed != "middle" || d.inserted != "center" {
+		t.Errorf("Expected only the changed range to be stored, got %+v", d)
+	}
+}
+
+// TestKeyframeInterval checks that full states are stored every N saves only.
+func TestKeyframeInterval(t *testing.T) {
+	silence(t)
+	doc := NewDocument("start")
+	doc.SetKeyframeInterval(3)
+	var keyframes []bool
+	for i := range 7 {
+		doc.Write(fmt.Sprint(i))
+		m := doc.Save().(*concreteMemento)
+		keyframes = append(keyframes, m.keyframe)
+		if !m.keyframe && m.state != "" {
+			t.Errorf("Expected save %d to store a delta only, got state %q", i, m.state)
+		}
+	}
+	expected := []bool{true, false, false, true, false, false, true}
+	if fmt.Sprint(keyframes) != fmt.Sprint(expected) {
+		t.Errorf("Expected keyframes %v, got %v", expected, keyframes)
+	}
+}
+
+// TestDeltaMementosRestoreAnyPoint checks that every memento restores the
+// content it was saved with, in any order.
+func TestDeltaMementosRestoreAnyPoint(t *testing.T) {
+	silence(t)
+	rng := rand.New(rand.NewPCG(1, 2))
+	doc := NewDocument(strings.Repeat("lorem ipsum ", 100))
+	doc.SetKeyframeInterval(4)
+
+	var mementos []IMemento
+	var contents []string
+	for range 50 {
+		doc.SetContent(edit(rng, doc.GetContent()))
+		mementos = append(mementos, doc.Save())
+		contents = append(contents, doc.GetContent())
+	}
+	for _, i := range rng.Perm(len(mementos)) {
+		if got := mementos[i].GetState(); got != contents[i] {
+			t.Fatalf("Expected memento %d to hold its content, got %d bytes instead of %d", i, len(got), len(contents[i]))
+		}
+		doc.Restore(mementos[i])
+		if doc.GetContent() != contents[i] {
+			t.Fatalf("Expected restoring memento %d to bring its content back", i)
+		}
+	}
+
+	// Saving after restoring an old memento branches from it
+	doc.Restore(mementos[10])
+	doc.Write(" branch")
+	branch := doc.Save()
+	if got := branch.GetState(); got != contents[10]+" branch" {
+		t.Errorf("Expected the branch to build on memento 10, got %q", got[max(len(got)-20, 0):])
+	}
+	if got := mementos[11].GetState(); got != contents[11] {
+		t.Errorf("Expected the old branch to be intact")
+	}
+}
+
+// TestHistoryWithDeltaMementos checks undo and redo across keyframes.
+func TestHistoryWithDeltaMementos(t *testing.T) {
+	silence(t)
+	doc := NewDocument("")
+	doc.SetKeyframeInterval(2)
+	history := NewHistory(doc)
+	var contents []string
+	for _, word := range []string{"one", " two", " three", " four", " five"} {
+		doc.Write(word)
+		history.Save()
+		contents = append(contents, doc.GetContent())
+	}
+
+	for i := len(contents) - 2; i >= 0; i-- {
+		history.Undo()
+		if doc.GetContent() != contents[i] {
+			t.Errorf("Expected undo to give %q, got %q", contents[i], doc.GetContent())
+		}
+	}
+	history.Undo()
+	if doc.GetContent() != "" {
+		t.Errorf("Expected to undo back to the empty document, got %q", doc.GetContent())
+	}
+	for _, content := range contents {
+		history.Redo()
+		if doc.GetContent() != content {
+			t.Errorf("Expected redo to give %q, got %q", content, doc.GetContent())
+		}
+	}
+}
+
+// TestDeltaMementosSaveMemory checks that mementos store far less than a
+// copy of the document per save.
+func TestDeltaMementosSaveMemory(t *testing.T) {
+	silence(t)
+	rng := rand.New(rand.NewPCG(3, 4))
+	doc := NewDocument(strings.Repeat("0123456789", 10_000)) // 100 KB
+	doc.SetKeyframeInterval(20)
+	stored := 0
+	for range 200 {
+		doc.SetContent(edit(rng, doc.GetContent()))
+		stored += storedBytes(doc.Save().(*concreteMemento))
+	}
+	// 10 keyframes, where full snapshots would take 200 copies
+	if limit := 11 * len(doc.GetContent()); stored > limit {
+		t.Errorf("Expected at most %d bytes stored, got %d", limit, stored)
+	}
+}
+
+// editSession saves edits of a 1 MB document and returns the mementos.
+func editSession(b *testing.B, edits int) (*Document, *History) {
+	rng := rand.New(rand.NewPCG(5, 6))
+	doc := NewDocument(strings.Repeat("All work and no play. ", 1<<20/22))
+	history := NewHistory(doc)
+	for range edits {
+		doc.content = edit(rng, doc.content) // SetContent would print 1 MB
+		history.Save()
+	}
+	return doc, history
+}
+
+// BenchmarkSave1MB10kEdits saves 10k edits of a 1 MB document.
+func BenchmarkSave1MB10kEdits(b *testing.B) {
+	silence(b)
+	for b.Loop() {
+		_, history := editSession(b, 10_000)
+		stored := 0
+		for _, m := range history.history {
+			stored += storedBytes(m.(*concreteMemento))
+		}
+		b.ReportMetric(float64(stored)/(1<<20), "stored-MB")
+	}
+}
+
+// BenchmarkRestore1MB10kEdits restores random points of 10k edits of a 1 MB
+// document.
+func BenchmarkRestore1MB10kEdits(b *testing.B) {
+	silence(b)
+	doc, history := editSession(b, 10_000)
+	rng := rand.New(rand.NewPCG(7, 8))
+	for b.Loop() {
+		doc.Restore(history.history[rng.IntN(len(history.history))])
+	}
+}
+
+// BenchmarkUndoRedo1MB10kEdits undoes and redoes one step of 10k edits of a
+// 1 MB document.
+func BenchmarkUndoRedo1MB10kEdits(b *testing.B) {
+	silence(b)
+	_, history := editSession(b, 10_000)
+	for b.Loop() {
+		history.Undo()
+		history.Redo()
+	}
+}
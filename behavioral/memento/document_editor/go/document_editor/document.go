@@ -9,11 +9,15 @@ import (
 // It can create Mementos to save its state and restore from them.
 type Document struct {
 	content string
+
+	last             *concreteMemento // Memento of the state last saved or restored
+	base             string           // The state of last, which the next Save diffs against
+	keyframeInterval int
 }
 
 // NewDocument creates a new Document with optional initial content.
 func NewDocument(initialContent string) *Document {
-	d := &Document{content: initialContent}
+	d := &Document{content: initialContent, keyframeInterval: DefaultKeyframeInterval}
 	fmt.Printf("Document initialized with: '%s'\n", d.content)
 	return d
 }
@@ -25,6 +29,13 @@ func (d *Document) Write(text string) {
 	fmt.Printf("Current content: '%s'\n", d.content)
 }
 
+// SetKeyframeInterval makes every interval-th save store the full content,
+// with only the changes stored in between. 1 stores the full content on
+// every save.
+func (d *Document) SetKeyframeInterval(interval int) {
+	d.keyframeInterval = max(interval, 1)
+}
+
 // SetContent directly sets the document's content (used for restoring).
 func (d *Document) SetContent(content string) {
     d.content = content
@@ -40,11 +51,30 @@ func (d *Document) GetContent() string {
 func (d *Document) Save() IMemento {
 	fmt.Printf("Saving state: '%s'\n", d.content)
 	// We return the interface type, but create the concrete implementation
-	return newConcreteMemento(d.content)
+	m := newConcreteMemento(d.last, d.base, d.content, d.keyframeInterval)
+	d.last, d.base = m, d.content
+	return m
 }
 
-// Restore sets the document's state from a given Memento.
+// Restore sets the document's state from a given Memento. Stepping to the
+// memento saved just before or after the current one, as undo and redo do,
+// only applies one delta.
 func (d *Document) Restore(m IMemento) {
-	d.content = m.GetState()
+	cm, ok := m.(*concreteMemento)
+	switch {
+	case !ok:
+		d.content = m.GetState()
+	case cm == d.last:
+		d.content = d.base
+	case d.last != nil && cm == d.last.parent:
+		d.content = d.last.delta.revert(d.base)
+	case cm.parent != nil && cm.parent == d.last:
+		d.content = cm.delta.apply(d.base)
+	default:
+		d.content = cm.GetState()
+	}
+	if ok {
+		d.last, d.base = cm, d.content
+	}
 	fmt.Printf("Restoring state to: '%s'\n", d.content)
 }
\ No newline at end of file
@@ -3,6 +3,8 @@ package document_editor
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -14,30 +16,128 @@ type IMemento interface {
 	GetDate() time.Time
 }
 
+// DefaultKeyframeInterval is how many saves apart a Document stores its
+// full content; the saves in between only store what changed.
+const DefaultKeyframeInterval = 100
+
+// delta is a reversible edit: the text removed at offset, and the text
+// inserted in its place.
+type delta struct {
+	offset   int
+	removed  string
+	inserted string
+}
+
+// diff returns the delta turning from into to, as the single range between
+// their common prefix and suffix.
+func diff(from, to string) delta {
+	prefix := commonPrefix(from, to)
+	suffix := commonSuffix(from[prefix:], to[prefix:])
+	return delta{
+		offset: prefix,
+		// Clone, so the delta does not keep the whole strings alive
+		removed:  strings.Clone(from[prefix : len(from)-suffix]),
+		inserted: strings.Clone(to[prefix : len(to)-suffix]),
+	}
+}
+
+// compareChunk is how many bytes commonPrefix and commonSuffix compare at
+// once, which is much faster than byte by byte on large documents.
+const compareChunk = 1024
+
+// commonPrefix returns the length of the longest common prefix of a and b.
+func commonPrefix(a, b string) int {
+	n := min(len(a), len(b))
+	i := 0
+	for i+compareChunk <= n && a[i:i+compareChunk] == b[i:i+compareChunk] {
+		i += compareChunk
+	}
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// commonSuffix returns the length of the longest common suffix of a and b.
+func commonSuffix(a, b string) int {
+	n := min(len(a), len(b))
+	i := 0
+	for i+compareChunk <= n && a[len(a)-i-compareChunk:len(a)-i] == b[len(b)-i-compareChunk:len(b)-i] {
+		i += compareChunk
+	}
+	for i < n && a[len(a)-i-1] == b[len(b)-i-1] {
+		i++
+	}
+	return i
+}
+
+// apply turns the state before the edit into the state after it.
+func (d delta) apply(state string) string {
+	return state[:d.offset] + d.inserted + state[d.offset+len(d.removed):]
+}
+
+// revert turns the state after the edit back into the state before it.
+func (d delta) revert(state string) string {
+	return state[:d.offset] + d.removed + state[d.offset+len(d.inserted):]
+}
+
 // concreteMemento is the internal implementation of the Memento.
-// It stores the state of the Originator.
+// Keyframes store the full state of the Originator; other mementos store
+// the delta from the memento saved before them, and rebuild their state by
+// applying the deltas since the nearest keyframe.
 type concreteMemento struct {
-	state string
-	date  time.Time
+	parent   *concreteMemento // Memento the delta applies to; nil for the first
+	delta    delta            // From the state of parent to this one
+	keyframe bool
+	state    string // Full state, for keyframes only
+	depth    int    // Deltas since the nearest keyframe
+	name     string // Date and start of the state
+	date     time.Time
 }
 
-// newConcreteMemento creates a new Memento.
+// newConcreteMemento creates a new Memento for the state to, saved after
+// parent, whose state is from. Every interval saves, and for the first one,
+// the memento is a keyframe.
 // This function is usually package-private or used by the Originator.
-func newConcreteMemento(state string) *concreteMemento {
-	return &concreteMemento{
-		state: state,
-		date:  time.Now(),
+func newConcreteMemento(parent *concreteMemento, from, to string, interval int) *concreteMemento {
+	m := &concreteMemento{
+		parent: parent,
+		date:   time.Now(),
+	}
+	m.name = fmt.Sprintf("%s / (%s...)", m.date.Format(time.RFC3339), to[:min(10, len(to))])
+	if parent != nil {
+		m.delta = diff(from, to)
+		m.depth = parent.depth + 1
+	}
+	if parent == nil || m.depth >= interval {
+		m.keyframe = true
+		m.state = to
+		m.depth = 0
 	}
+	return m
 }
 
-// GetState returns the saved state.
+// GetState returns the saved state, rebuilt from the nearest keyframe.
 func (m *concreteMemento) GetState() string {
-	return m.state
+	if m.keyframe {
+		return m.state
+	}
+	var chain []delta
+	keyframe := m
+	for ; !keyframe.keyframe; keyframe = keyframe.parent {
+		chain = append(chain, keyframe.delta)
+	}
+	state := []byte(keyframe.state)
+	for i := len(chain) - 1; i >= 0; i-- {
+		d := chain[i]
+		state = slices.Replace(state, d.offset, d.offset+len(d.removed), []byte(d.inserted)...)
+	}
+	return string(state)
 }
 
 // GetName returns metadata about the memento (e.g., timestamp).
 func (m *concreteMemento) GetName() string {
-	return fmt.Sprintf("%s / (%s...)", m.date.Format(time.RFC3339), m.state[:min(10, len(m.state))])
+	return m.name
 }
 
 // GetDate returns the creation date of the memento.
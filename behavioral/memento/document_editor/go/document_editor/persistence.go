@@ -0,0 +1,346 @@
+// behavioral/memento/document_editor/go/document_editor/persistence.go
+package document_editor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// History files start with historyMagic and a version number, hold the
+// mementos, the undo and redo stacks and the document's content, and end
+// with a CRC-32 of everything before it.
+const (
+	historyMagic   = "DOCHIST"
+	historyVersion = 1
+)
+
+// ErrInvalidHistory is returned when loading a history file that is
+// truncated, corrupt or not a history file at all.
+var ErrInvalidHistory = errors.New("invalid history file")
+
+// ErrUnsupportedVersion is returned when loading a history file written in
+// a format this version does not know.
+var ErrUnsupportedVersion = errors.New("unsupported history file version")
+
+// SaveTo writes the undo and redo stacks, with the timestamp and name of
+// every memento, and the current content of the document to w. Mementos
+// keep their delta structure, so the file is about as large as the history
+// in memory.
+func (h *History) SaveTo(w io.Writer) error {
+	mementos, stacks, last := collectMementos(h.history, h.redoStack, h.document.last)
+	index := make(map[*concreteMemento]int, len(mementos))
+	for i, m := range mementos {
+		index[m] = i + 1
+	}
+
+	enc := newHistoryEncoder(w)
+	enc.writeString(historyMagic)
+	enc.writeUint(historyVersion)
+	enc.writeUint(uint64(len(mementos)))
+	for _, m := range mementos {
+		// Keyframes keep their delta too, for undo and redo across them
+		enc.writeUint(uint64(index[m.parent])) // 0 for no parent
+		if m.parent != nil {
+			enc.writeUint(uint64(m.delta.offset))
+			enc.writeString(m.delta.removed)
+			enc.writeString(m.delta.inserted)
+		}
+		if m.keyframe {
+			enc.writeUint(1)
+			enc.writeString(m.state)
+		} else {
+			enc.writeUint(0)
+		}
+		date, err := m.date.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		enc.writeString(string(date))
+		enc.writeString(m.name)
+	}
+	for _, stack := range stacks {
+		enc.writeUint(uint64(len(stack)))
+		for _, m := range stack {
+			enc.writeUint(uint64(index[m]))
+		}
+	}
+	enc.writeUint(uint64(index[last]))
+	enc.writeString(h.document.content)
+	if err := enc.close(); err != nil {
+		return err
+	}
+	fmt.Printf("History: Saved %d undo and %d redo states.\n", len(h.history), len(h.redoStack))
+	return nil
+}
+
+// LoadHistory reads a history written by SaveTo and restores doc to the
+// state it was in, including what can be undone and redone. Files that are
+// truncated or corrupt are rejected with ErrInvalidHistory, and doc is left
+// untouched.
+func LoadHistory(r io.Reader, doc *Document) (*History, error) {
+	dec := newHistoryDecoder(r)
+	if magic := dec.readString(); dec.err == nil && magic != historyMagic {
+		return nil, fmt.Errorf("%w: not a history file", ErrInvalidHistory)
+	}
+	if version := dec.readUint(); dec.err == nil && version != historyVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	// Mementos come after their parent, so index 0 can stand for none
+	count := dec.readUint()
+	mementos := []*concreteMemento{nil}
+	lengths := []int{0} // Length of the state of each memento
+	for i := uint64(0); i < count && dec.err == nil; i++ {
+		m := &concreteMemento{}
+		parent := dec.readIndex(len(mementos))
+		m.parent = mementos[parent]
+		length := 0
+		if m.parent != nil {
+			offset := dec.readUint()
+			m.delta.removed = dec.readString()
+			m.delta.inserted = dec.readString()
+			// Reject deltas that do not fit their parent, rather than panic later
+			if offset > uint64(lengths[parent]) || len(m.delta.removed) > lengths[parent]-int(offset) {
+				dec.fail("delta out of range")
+			} else {
+				m.delta.offset = int(offset)
+				length = lengths[parent] - len(m.delta.removed) + len(m.delta.inserted)
+			}
+		}
+		switch kind := dec.readUint(); {
+		case dec.err != nil:
+		case kind == 1:
+			m.keyframe = true
+			m.state = dec.readString()
+			if m.parent != nil && len(m.state) != length {
+				dec.fail("keyframe does not match its delta")
+			}
+			length = len(m.state)
+		case kind == 0 && m.parent != nil:
+			m.depth = m.parent.depth + 1
+		default:
+			dec.fail("unknown memento")
+		}
+		if err := m.date.UnmarshalBinary([]byte(dec.readString())); err != nil && dec.err == nil {
+			dec.fail(err.Error())
+		}
+		m.name = dec.readString()
+		mementos = append(mementos, m)
+		lengths = append(lengths, length)
+	}
+
+	var stacks [2][]IMemento
+	for s := range stacks {
+		n := dec.readUint()
+		stacks[s] = make([]IMemento, 0)
+		for i := uint64(0); i < n && dec.err == nil; i++ {
+			m := mementos[dec.readIndex(len(mementos))]
+			if m == nil && dec.err == nil {
+				dec.fail("missing memento")
+			}
+			stacks[s] = append(stacks[s], m)
+		}
+	}
+	last := mementos[dec.readIndex(len(mementos))]
+	content := dec.readString()
+	if err := dec.close(); err != nil {
+		return nil, err
+	}
+
+	doc.content = content
+	doc.last = last
+	doc.base = ""
+	if last != nil {
+		doc.base = last.GetState()
+	}
+	h := &History{document: doc, history: stacks[0], redoStack: stacks[1]}
+	fmt.Printf("History: Loaded %d undo and %d redo states.\n", len(h.history), len(h.redoStack))
+	fmt.Printf("Restoring state to: '%s'\n", doc.content)
+	return h, nil
+}
+
+// asConcrete returns the concreteMemento behind m. Mementos implemented
+// elsewhere become keyframes holding their state, date and name.
+func asConcrete(m IMemento) *concreteMemento {
+	if cm, ok := m.(*concreteMemento); ok {
+		return cm
+	}
+	return &concreteMemento{keyframe: true, state: m.GetState(), name: m.GetName(), date: m.GetDate()}
+}
+
+// collectMementos returns the mementos of the stacks and last with their
+// ancestors, parents first, and the stacks and last as concrete mementos.
+func collectMementos(history, redoStack []IMemento, last *concreteMemento) ([]*concreteMemento, [2][]*concreteMemento, *concreteMemento) {
+	var mementos []*concreteMemento
+	seen := make(map[*concreteMemento]bool)
+	var visit func(m *concreteMemento)
+	visit = func(m *concreteMemento) {
+		if m == nil || seen[m] {
+			return
+		}
+		visit(m.parent)
+		mementos = append(mementos, m)
+		seen[m] = true
+	}
+	var stacks [2][]*concreteMemento
+	for s, stack := range [][]IMemento{history, redoStack} {
+		for _, m := range stack {
+			cm := asConcrete(m)
+			stacks[s] = append(stacks[s], cm)
+			visit(cm)
+		}
+	}
+	visit(last)
+	return mementos, stacks, last
+}
+
+// historyEncoder writes the fields of a history file, keeping the first
+// error and a checksum of what it wrote.
+type historyEncoder struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func newHistoryEncoder(w io.Writer) *historyEncoder {
+	return &historyEncoder{w: bufio.NewWriter(w), crc: crc32.NewIEEE()}
+}
+
+func (e *historyEncoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	e.crc.Write(p)
+	_, e.err = e.w.Write(p)
+}
+
+func (e *historyEncoder) writeUint(v uint64) {
+	e.write(binary.AppendUvarint(e.buf[:0], v))
+}
+
+func (e *historyEncoder) writeString(s string) {
+	e.writeUint(uint64(len(s)))
+	if e.err == nil {
+		e.crc.Write([]byte(s))
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+// close writes the checksum and flushes
+func (e *historyEncoder) close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if _, err := e.w.Write(binary.BigEndian.AppendUint32(nil, e.crc.Sum32())); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// historyDecoder reads the fields of a history file, keeping the first
+// error and a checksum of what it read.
+type historyDecoder struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	err error
+}
+
+func newHistoryDecoder(r io.Reader) *historyDecoder {
+	return &historyDecoder{r: bufio.NewReader(r), crc: crc32.NewIEEE()}
+}
+
+// fail records a reason the file is invalid, unless one was found already
+func (d *historyDecoder) fail(reason string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrInvalidHistory, reason)
+	}
+}
+
+// failed records a read error; running out of data means the file is truncated
+func (d *historyDecoder) failed(err error) {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		d.fail("truncated")
+	} else if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *historyDecoder) readUint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	for shift := 0; ; shift += 7 {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			d.failed(err)
+			return 0
+		}
+		d.crc.Write([]byte{c})
+		if shift == 63 && c > 1 {
+			d.fail("number out of range")
+			return 0
+		}
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v
+		}
+	}
+}
+
+// readIndex reads a memento index, which must be below n
+func (d *historyDecoder) readIndex(n int) int {
+	i := d.readUint()
+	if i >= uint64(n) {
+		d.fail("memento index out of range")
+		return 0
+	}
+	return int(i)
+}
+
+func (d *historyDecoder) readString() string {
+	n := d.readUint()
+	if d.err != nil {
+		return ""
+	}
+	if n > 1<<62 {
+		d.fail("string too long")
+		return ""
+	}
+	// Grow with the data read, so a corrupt length cannot allocate it all
+	var b strings.Builder
+	if _, err := io.CopyN(io.MultiWriter(&b, d.crc), d.r, int64(n)); err != nil {
+		d.failed(err)
+		return ""
+	}
+	return b.String()
+}
+
+// close checks the checksum and that nothing follows it
+func (d *historyDecoder) close() error {
+	if d.err != nil {
+		return d.err
+	}
+	sum := d.crc.Sum32()
+	var stored [4]byte
+	if _, err := io.ReadFull(d.r, stored[:]); err != nil {
+		d.failed(err)
+		return d.err
+	}
+	if binary.BigEndian.Uint32(stored[:]) != sum {
+		d.fail("checksum mismatch")
+		return d.err
+	}
+	if _, err := d.r.ReadByte(); err != io.EOF {
+		d.fail("unexpected data after the checksum")
+		return d.err
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package document_editor
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// savedSession saves a history with keyframes, deltas, something to redo and
+// unsaved changes, and returns it with what SaveTo wrote.
+func savedSession(t *testing.T) (*History, []byte) {
+	t.Helper()
+	doc := NewDocument("Dear team,")
+	doc.SetKeyframeInterval(3)
+	history := NewHistory(doc)
+	for _, text := range []string{" the release", " is ready.", " Thanks!", " Bye."} {
+		doc.Write(text)
+		history.Save()
+	}
+	history.Undo()
+	history.Undo()
+	doc.Write(" (draft)") // Not saved
+
+	var buf bytes.Buffer
+	if err := history.SaveTo(&buf); err != nil {
+		t.Fatalf("Expected the history to be saved, got %v", err)
+	}
+	return history, buf.Bytes()
+}
+
+// sameMementos reports whether two stacks hold the same states, names and
+// dates.
+func sameMementos(a, b []IMemento) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].GetState() != b[i].GetState() || a[i].GetName() != b[i].GetName() || !a[i].GetDate().Equal(b[i].GetDate()) {
+			return false
+		}
+	}
+	return true
+}
+
+// TestHistoryRoundTrip checks that a loaded history is the one saved,
+// including what can be redone.
+func TestHistoryRoundTrip(t *testing.T) {
+	silence(t)
+	saved, data := savedSession(t)
+
+	doc := NewDocument("something else")
+	loaded, err := LoadHistory(bytes.NewReader(data), doc)
+	if err != nil {
+		t.Fatalf("Expected the history to load, got %v", err)
+	}
+	if doc.GetContent() != saved.document.GetContent() {
+		t.Errorf("Expected content %q, got %q", saved.document.GetContent(), doc.GetContent())
+	}
+	if !sameMementos(loaded.history, saved.history) {
+		t.Errorf("Expected the undo stack to be restored")
+	}
+	if !sameMementos(loaded.redoStack, saved.redoStack) {
+		t.Errorf("Expected the redo stack to be restored")
+	}
+
+	for range 3 {
+		saved.Redo()
+		loaded.Redo()
+		if doc.GetContent() != saved.document.GetContent() {
+			t.Errorf("Expected redo to give %q, got %q", saved.document.GetContent(), doc.GetContent())
+		}
+	}
+	for range 6 {
+		saved.Undo()
+		loaded.Undo()
+		if doc.GetContent() != saved.document.GetContent() {
+			t.Errorf("Expected undo to give %q, got %q", saved.document.GetContent(), doc.GetContent())
+		}
+	}
+
+	// Saving carries on from the loaded deltas
+	doc.Write(" Hello")
+	loaded.Save()
+	if state, _ := loaded.GetLastHistoryState(); state != "Dear team, Hello" {
+		t.Errorf("Expected a new save after loading, got %q", state)
+	}
+}
+
+// TestLoadHistoryRejectsCorruptFiles checks that truncated, altered and
+// extended files are refused without touching the document.
+func TestLoadHistoryRejectsCorruptFiles(t *testing.T) {
+	silence(t)
+	_, data := savedSession(t)
+	doc := NewDocument("untouched")
+
+	load := func(data []byte) error {
+		_, err := LoadHistory(bytes.NewReader(data), doc)
+		return err
+	}
+	for n := range len(data) {
+		if err := load(data[:n]); !errors.Is(err, ErrInvalidHistory) {
+			t.Fatalf("Expected a file truncated to %d bytes to be invalid, got %v", n, err)
+		}
+	}
+	for i := range data {
+		corrupt := bytes.Clone(data)
+		corrupt[i] ^= 0x20
+		if err := load(corrupt); !errors.Is(err, ErrInvalidHistory) && !errors.Is(err, ErrUnsupportedVersion) {
+			t.Fatalf("Expected a file with byte %d altered to be rejected, got %v", i, err)
+		}
+	}
+	if err := load(append(bytes.Clone(data), 0)); !errors.Is(err, ErrInvalidHistory) {
+		t.Errorf("Expected trailing data to be invalid, got %v", err)
+	}
+	if doc.GetContent() != "untouched" || doc.last != nil {
+		t.Errorf("Expected the document to be left alone, got %q", doc.GetContent())
+	}
+}
+
+// TestLoadHistoryVersion checks that files from another format version are
+// refused.
+func TestLoadHistoryVersion(t *testing.T) {
+	silence(t)
+	var buf bytes.Buffer
+	enc := newHistoryEncoder(&buf)
+	enc.writeString(historyMagic)
+	enc.writeUint(historyVersion + 1)
+	enc.close()
+	if _, err := LoadHistory(&buf, NewDocument("")); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	// Use the module path defined in go.mod
 	"memento_pattern_document_editor_go/document_editor"
@@ -65,5 +66,22 @@ func main() {
 	history.Undo()
 	history.PrintHistory()
 
+	// Save the history and reload it into a new document, as when reopening
+	// the editor; what was undone can still be redone
+	fmt.Println("\n--- Saving and reloading the history ---")
+	var file bytes.Buffer
+	if err := history.SaveTo(&file); err != nil {
+		fmt.Println("Error saving history:", err)
+		return
+	}
+	reopened := document_editor.NewDocument("")
+	history, err := document_editor.LoadHistory(&file, reopened)
+	if err != nil {
+		fmt.Println("Error loading history:", err)
+		return
+	}
+	history.Redo()
+	history.PrintHistory()
+
 	fmt.Println("\n--- Demo Complete ---")
 }
\ No newline at end of file